@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/joelmoss/workroom/internal/workroom"
+	"github.com/spf13/cobra"
+)
+
+var (
+	adoptAll  bool
+	adoptName string
+	adoptMove bool
+)
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt [PATH]",
+	Short: "Turn existing Git worktrees or JJ workspaces into workrooms",
+	Long:  "Register an existing Git worktree or JJ workspace of the current project as a workroom, so that it shows up in `workroom list` and can be deleted like any other. With --all, adopt every worktree or workspace that is not a workroom yet. With --move, also move adopted workrooms to the path given by the workrooms_layout setting.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if adoptAll == (len(args) == 1) {
+			return errors.New("give either a PATH or --all")
+		}
+		if adoptAll && adoptName != "" {
+			return errors.New("--name cannot be used with --all")
+		}
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+
+		var path string
+		if len(args) == 1 {
+			path = args[0]
+		}
+		return svc.Adopt(cwd, path, workroom.AdoptOptions{All: adoptAll, Name: adoptName, Move: adoptMove})
+	},
+}
+
+var releaseCmd = &cobra.Command{
+	Use:   "release NAME",
+	Short: "Stop managing a workroom without deleting it",
+	Long:  "Remove a workroom from the config, leaving its worktree or workspace, files and branch untouched. Use `workroom adopt` to manage it again.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.Release(cwd, args[0])
+	},
+}
+
+func init() {
+	adoptCmd.Flags().BoolVarP(&adoptAll, "all", "a", false, "Adopt every worktree or workspace of the current project")
+	adoptCmd.Flags().StringVar(&adoptName, "name", "", "Workroom name to adopt PATH as (default: its directory or workspace name)")
+	adoptCmd.Flags().BoolVar(&adoptMove, "move", false, "Move adopted workrooms into the configured workrooms directory")
+	rootCmd.AddCommand(adoptCmd)
+	rootCmd.AddCommand(releaseCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cdCmd = &cobra.Command{
+	Use:   "cd [NAME]",
+	Short: "Change into a workroom",
+	Long:  "Change the current shell's directory to a workroom. When no name is given, pick one interactively. Requires shell integration (see `workroom shell-init`); without it, the workroom path is printed instead.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		return svc.Cd(cwd, name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cdCmd)
+}
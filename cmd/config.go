@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joelmoss/workroom/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configShowResolved bool
+	configGlobal       bool
+	configProject      bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and change workroom configuration",
+	Long: `Inspect and change workroom configuration.
+
+Settings live in two places: the global config, which applies to every project, and the
+project config (.workroom.toml or .workroom.json in the project root), which is checked in
+and shared with everyone working on the project. Use --global or --project to choose one.
+
+Keys:
+` + settingsHelp(),
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the global config",
+	Long:  "Print the global config file. With --resolved, print the effective configuration for the current project instead, after merging the built-in defaults, the global config and the project's .workroom.toml or .workroom.json, in that order of precedence.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.ShowConfig(cwd, configShowResolved)
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Print the value of a setting",
+	Long:  "Print the value of a setting, one line per item for lists. Without --global or --project, print the effective value for the current project. Exits with status 1 when the setting is not set.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.ConfigGet(cwd, configScope(""), args[0])
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE...",
+	Short: "Change a setting",
+	Long:  "Change a setting in the global config, or in the project config with --project. List settings, such as copy, take any number of values and replace the whole list.",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.ConfigSet(cwd, configScope(config.ScopeGlobal), args[0], args[1:])
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset KEY",
+	Short: "Remove a setting",
+	Long:  "Remove a setting from the global config, or from the project config with --project.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.ConfigUnset(cwd, configScope(config.ScopeGlobal), args[0])
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List settings",
+	Long:  "List settings as KEY=VALUE lines. Without --global or --project, list the effective settings for the current project.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.ConfigList(cwd, configScope(""))
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open a config file in your editor",
+	Long:  "Open the global config, or the project config with --project, in $VISUAL or $EDITOR. The file is validated when the editor exits, and is only saved when it is valid.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.ConfigEdit(cwd, configScope(config.ScopeGlobal))
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the path of a config file",
+	Long:  "Print the path of the global config, or of the project config with --project. For a project without a config file, print the path it would be created at.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.ConfigPath(cwd, configScope(config.ScopeGlobal))
+	},
+}
+
+// configScope returns the scope chosen with --global or --project, or def when neither is given.
+func configScope(def config.Scope) config.Scope {
+	switch {
+	case configGlobal:
+		return config.ScopeGlobal
+	case configProject:
+		return config.ScopeProject
+	}
+	return def
+}
+
+func settingsHelp() string {
+	var b strings.Builder
+	for _, s := range config.Settings {
+		scopes := make([]string, len(s.Scopes))
+		for i, scope := range s.Scopes {
+			scopes[i] = string(scope)
+		}
+		fmt.Fprintf(&b, "  %-18s %s (%s)\n", s.Key, s.Desc, strings.Join(scopes, ", "))
+	}
+	return b.String()
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configShowResolved, "resolved", false, "Print the merged configuration for the current project")
+	configCmd.AddCommand(configShowCmd)
+
+	for _, c := range []*cobra.Command{configGetCmd, configSetCmd, configUnsetCmd, configListCmd, configEditCmd, configPathCmd} {
+		c.Flags().BoolVar(&configGlobal, "global", false, "Use the global config")
+		c.Flags().BoolVar(&configProject, "project", false, "Use the project's .workroom.toml or .workroom.json")
+		c.MarkFlagsMutuallyExclusive("global", "project")
+		configCmd.AddCommand(c)
+	}
+	rootCmd.AddCommand(configCmd)
+}
@@ -1,15 +1,23 @@
 package cmd
 
 import (
+	"github.com/joelmoss/workroom/internal/workroom"
 	"github.com/spf13/cobra"
 )
 
+var (
+	createFrom          string
+	createBase          string
+	createKeepOnFailure bool
+	createCd            bool
+)
+
 var createCmd = &cobra.Command{
-	Use:     "create",
+	Use:     "create [NAME]",
 	Aliases: []string{"c"},
 	Short:   "Create a new workroom",
-	Long:    "Create a new workroom at the same level as your main project directory, using JJ workspaces if available, otherwise falling back to git worktrees. A random friendly name is auto-generated.",
-	Args:    cobra.NoArgs,
+	Long:    "Create a new workroom at the same level as your main project directory, using JJ workspaces if available, otherwise falling back to git worktrees. When no name is given, a random friendly name is auto-generated.",
+	Args:    cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		svc, err := newService()
 		if err != nil {
@@ -19,10 +27,19 @@ var createCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return svc.Create(cwd)
+		opts := workroom.CreateOptions{From: createFrom, Base: createBase, KeepOnFailure: createKeepOnFailure, Cd: createCd}
+		if len(args) > 0 {
+			opts.Name = args[0]
+		}
+		return svc.Create(cwd, opts)
 	},
 }
 
 func init() {
+	createCmd.Flags().StringVar(&createFrom, "from", "", "Check out an existing branch, tag or commit (or JJ revset) instead of creating a new branch")
+	createCmd.Flags().StringVar(&createBase, "base", "", "Fork the new branch from this ref instead of HEAD")
+	createCmd.MarkFlagsMutuallyExclusive("from", "base")
+	createCmd.Flags().BoolVar(&createKeepOnFailure, "keep-on-failure", false, "Keep a partially created workroom when setup fails, instead of rolling it back")
+	createCmd.Flags().BoolVar(&createCd, "cd", false, "Change into the new workroom (requires shell integration, see `workroom shell-init`)")
 	rootCmd.AddCommand(createCmd)
 }
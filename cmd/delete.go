@@ -1,16 +1,22 @@
 package cmd
 
 import (
+	"github.com/joelmoss/workroom/internal/workroom"
 	"github.com/spf13/cobra"
 )
 
-var confirmFlag string
+var (
+	confirmFlag     string
+	keepBranchFlag  bool
+	forceBranchFlag bool
+	forceFlag       bool
+)
 
 var deleteCmd = &cobra.Command{
 	Use:     "delete [NAME]",
 	Aliases: []string{"d"},
 	Short:   "Delete an existing workroom",
-	Long:    "Delete an existing workroom. When run without a name, shows an interactive multi-select menu.",
+	Long:    "Delete an existing workroom. When run without a name, shows an interactive multi-select menu. Workrooms with uncommitted or unpushed work are not deleted unless --force is given. The workroom's Git branch is deleted too, as long as it is fully merged into trunk. For JJ, the workspace's working-copy commit is abandoned if it is empty.",
 	Args:    cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		svc, err := newService()
@@ -22,14 +28,24 @@ var deleteCmd = &cobra.Command{
 			return err
 		}
 
+		opts := workroom.DeleteOptions{
+			Confirm:     confirmFlag,
+			Force:       forceFlag,
+			KeepBranch:  keepBranchFlag,
+			ForceBranch: forceBranchFlag,
+		}
 		if len(args) == 0 {
-			return svc.InteractiveDelete(cwd)
+			return svc.InteractiveDelete(cwd, opts)
 		}
-		return svc.Delete(cwd, args[0], confirmFlag)
+		return svc.Delete(cwd, args[0], opts)
 	},
 }
 
 func init() {
 	deleteCmd.Flags().StringVar(&confirmFlag, "confirm", "", "Skip confirmation if value matches the workroom name")
+	deleteCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Delete even if the workroom has uncommitted or unpushed work")
+	deleteCmd.Flags().BoolVar(&keepBranchFlag, "keep-branch", false, "Keep the workroom's branch instead of deleting it")
+	deleteCmd.Flags().BoolVar(&forceBranchFlag, "force-branch", false, "Delete the workroom's branch even if it is not merged into trunk")
+	deleteCmd.MarkFlagsMutuallyExclusive("keep-branch", "force-branch")
 	rootCmd.AddCommand(deleteCmd)
 }
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Find and repair drift between the config, the VCS and the disk",
+	Long:  "Cross-check the workrooms in the config against each project's Git worktrees or JJ workspaces and the directories on disk. Reports stale config entries, orphan worktrees and workspaces, directories without VCS metadata and lingering workroom branches. With --fix, repair what can be repaired safely: prune or forget worktrees, clean up or re-register config entries, and delete merged branches. Combine --fix with --pretend to see the planned repairs. Exits with status 1 while problems remain.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.Doctor(cwd, doctorFix)
+	},
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Repair the problems that can be repaired automatically")
+	rootCmd.AddCommand(doctorCmd)
+}
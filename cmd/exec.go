@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/joelmoss/workroom/internal/workroom"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execAll  bool
+	execJobs int
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [NAME] -- COMMAND [ARGS...]",
+	Short: "Run a command inside a workroom",
+	Long:  "Run a command with the workroom as its working directory, and with WORKROOM_NAME and WORKROOM_PARENT_DIR set as for setup scripts. With --all, run it in every workroom of the current project in parallel, prefixing output with the workroom name.",
+	Example: `  workroom exec swift-meadow -- bundle install
+  workroom exec --all -- git pull --rebase`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 0 {
+			return errors.New("separate the command from workroom's arguments with `--`")
+		}
+
+		var name string
+		switch {
+		case execAll && dash != 0:
+			return errors.New("a workroom name cannot be given with --all")
+		case !execAll && dash != 1:
+			return errors.New("give the name of exactly one workroom, or pass --all")
+		case !execAll:
+			name = args[0]
+		}
+
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.Exec(cwd, name, args[dash:], workroom.ExecOptions{All: execAll, Jobs: execJobs})
+	},
+}
+
+func init() {
+	execCmd.Flags().BoolVarP(&execAll, "all", "a", false, "Run the command in every workroom of the current project")
+	execCmd.Flags().IntVarP(&execJobs, "jobs", "j", workroom.DefaultExecJobs, "Maximum number of workrooms to run the command in at once with --all")
+	rootCmd.AddCommand(execCmd)
+}
@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"strings"
+
+	"github.com/joelmoss/workroom/internal/workroom"
 	"github.com/spf13/cobra"
 )
 
+var (
+	listJSON    bool
+	listNDJSON  bool
+	listColumns []string
+)
+
 var listCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls", "l"},
@@ -18,10 +27,23 @@ var listCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return svc.List(cwd)
+		opts := workroom.ListOptions{Columns: listColumns}
+		switch {
+		case listJSON:
+			opts.Format = workroom.ListFormatJSON
+		case listNDJSON:
+			opts.Format = workroom.ListFormatNDJSON
+		}
+		return svc.List(cwd, opts)
 	},
 }
 
 func init() {
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "Print workrooms as JSON")
+	listCmd.Flags().BoolVar(&listNDJSON, "ndjson", false, "Print workrooms as newline-delimited JSON, one object per line")
+	listCmd.Flags().StringSliceVar(&listColumns, "columns", nil, "Comma-separated columns to show ("+strings.Join(workroom.ListColumns, ", ")+")")
+	listCmd.MarkFlagsMutuallyExclusive("json", "ndjson")
+	listCmd.MarkFlagsMutuallyExclusive("json", "columns")
+	listCmd.MarkFlagsMutuallyExclusive("ndjson", "columns")
 	rootCmd.AddCommand(listCmd)
 }
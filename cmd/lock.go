@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var lockReason string
+
+var lockCmd = &cobra.Command{
+	Use:   "lock NAME",
+	Short: "Lock a workroom so that it cannot be deleted",
+	Long:  "Lock a workroom, optionally with a reason. A locked workroom is not deleted by `workroom delete`, left out of the interactive delete menu, never pruned, and not moved by `workroom rename` or `workroom migrate`. For Git, its worktree is locked with `git worktree lock` too, so that `git worktree prune` also leaves it alone. Locking a locked workroom replaces its reason.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.Lock(cwd, args[0], lockReason)
+	},
+}
+
+var unlockCmd = &cobra.Command{
+	Use:   "unlock NAME",
+	Short: "Unlock a locked workroom",
+	Long:  "Unlock a workroom locked with `workroom lock`, and for Git, its worktree.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.Unlock(cwd, args[0])
+	},
+}
+
+func init() {
+	lockCmd.Flags().StringVar(&lockReason, "reason", "", "Why the workroom is locked, shown by list and when deleting it is refused")
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(unlockCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs NAME [HOOK]",
+	Short: "Show the output of a workroom's setup, teardown and other hooks",
+	Long:  "Show the output that the hooks of a workroom logged the last time they ran, such as its setup script when it was created, or its teardown script when it was deleted. Without a HOOK, the logs of every hook that has run are shown. Logs are kept after the workroom is deleted.",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		point := ""
+		if len(args) == 2 {
+			point = args[1]
+		}
+		return svc.Logs(cwd, args[0], point)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move existing workrooms into the configured directory layout",
+	Long:  "Move every workroom recorded in the config to the path given by the workrooms_layout setting, and update the config to match. Use --pretend to see what would be moved.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		return svc.Migrate()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
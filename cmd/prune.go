@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/joelmoss/workroom/internal/workroom"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneMerged      bool
+	pruneOlderThan   int
+	pruneMissing     bool
+	pruneGone        bool
+	pruneKeep        []string
+	pruneYes         bool
+	pruneForce       bool
+	pruneKeepBranch  bool
+	pruneForceBranch bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete stale or merged workrooms",
+	Long:  "Delete the workrooms of the current project that match any of the given filters: --merged for those whose branch is merged into trunk, --older-than for those without commits for a number of days, --missing for those whose directory is gone, and --gone for those whose upstream branch was deleted. Without filters, --merged, --missing and --gone are used. The matching workrooms are listed with the reasons they matched, and deleted after confirmation just as `workroom delete` would, running its hooks, then the on_prune hook runs. Workrooms with unsaved work or unmerged branches are skipped unless --force or --force-branch is given. Use --keep to protect workrooms by name, and --pretend to only see what would be pruned.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.Prune(cwd, workroom.PruneOptions{
+			Merged:      pruneMerged,
+			OlderThan:   pruneOlderThan,
+			Missing:     pruneMissing,
+			Gone:        pruneGone,
+			Keep:        pruneKeep,
+			Yes:         pruneYes,
+			Force:       pruneForce,
+			KeepBranch:  pruneKeepBranch,
+			ForceBranch: pruneForceBranch,
+		})
+	},
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneMerged, "merged", false, "Prune workrooms whose branch is merged into trunk")
+	pruneCmd.Flags().IntVar(&pruneOlderThan, "older-than", 0, "Prune workrooms without commits for this many days")
+	pruneCmd.Flags().BoolVar(&pruneMissing, "missing", false, "Prune workrooms whose directory is missing")
+	pruneCmd.Flags().BoolVar(&pruneGone, "gone", false, "Prune workrooms whose upstream branch was deleted (Git only)")
+	pruneCmd.Flags().StringSliceVar(&pruneKeep, "keep", nil, "Never prune workrooms whose name matches these glob patterns")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Skip the confirmation prompt")
+	pruneCmd.Flags().BoolVarP(&pruneForce, "force", "f", false, "Prune workrooms even if they have uncommitted or unpushed work")
+	pruneCmd.Flags().BoolVar(&pruneKeepBranch, "keep-branch", false, "Keep the branches of pruned workrooms")
+	pruneCmd.Flags().BoolVar(&pruneForceBranch, "force-branch", false, "Delete the branches of pruned workrooms even if they are not merged into trunk")
+	pruneCmd.MarkFlagsMutuallyExclusive("keep-branch", "force-branch")
+	rootCmd.AddCommand(pruneCmd)
+}
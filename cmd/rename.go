@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename OLD NEW",
+	Short: "Rename a workroom",
+	Long:  "Rename a workroom, along with its directory and its Git branch or JJ workspace, running the project's pre_rename hook before and its rename hook after. If any step fails, the steps before it are undone.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newService()
+		if err != nil {
+			return err
+		}
+		cwd, err := getCwd()
+		if err != nil {
+			return err
+		}
+		return svc.Rename(cwd, args[0], args[1])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/joelmoss/workroom/internal/config"
+	"github.com/joelmoss/workroom/internal/errs"
 	"github.com/joelmoss/workroom/internal/ui"
 	"github.com/joelmoss/workroom/internal/workroom"
 	"github.com/spf13/cobra"
@@ -12,6 +16,7 @@ import (
 var (
 	verbose    bool
 	pretend    bool
+	configPath string
 	versionStr = "dev"
 )
 
@@ -29,14 +34,42 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Print detailed and verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&pretend, "pretend", "p", false, "Run through the command without making changes (dry run)")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to the global config file (default $WORKROOM_CONFIG, or $XDG_CONFIG_HOME/workroom/config.json)")
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := interruptContext()
+	defer stop()
+	return rootCmd.ExecuteContext(ctx)
+}
+
+// interruptContext returns a context that is cancelled by the first SIGINT or SIGTERM, which stops
+// the running VCS commands and hooks so that what was done can be rolled back. A second signal
+// kills workroom right away.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			code := 130
+			if sig == syscall.SIGTERM {
+				code = 143
+			}
+			cancel(&errs.ExitError{Code: code, Err: errs.ErrInterrupted})
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
 }
 
 func newService() (*workroom.Service, error) {
-	cfg, err := config.New("")
+	cfg, err := config.New(configPath)
 	if err != nil {
 		return nil, err
 	}
@@ -47,5 +80,9 @@ func newService() (*workroom.Service, error) {
 		Pretend:   pretend,
 		PromptFn:  ui.MultiSelect,
 		ConfirmFn: ui.Confirm,
+		SelectFn:  ui.Select,
+		EditFn:    ui.Edit,
+		CdFile:    os.Getenv("WORKROOM_CD_FILE"),
+		Context:   rootCmd.Context(),
 	}, nil
 }
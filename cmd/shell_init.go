@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// The shell wrappers run workroom with WORKROOM_CD_FILE set to a temporary file. Commands that
+// change directory write the target path to that file, and the wrapper changes to it once
+// workroom exits. Normal output is untouched, so interactive prompts keep working.
+
+const posixShellInit = `workroom() {
+  local cd_file ret
+  cd_file="$(command mktemp -t workroom-cd.XXXXXX)" || return
+  WORKROOM_CD_FILE="$cd_file" command workroom "$@"
+  ret=$?
+  if [ -s "$cd_file" ]; then
+    builtin cd -- "$(command cat "$cd_file")" || ret=$?
+  fi
+  command rm -f -- "$cd_file"
+  return $ret
+}
+`
+
+const fishShellInit = `function workroom --wraps workroom --description 'Manage development workrooms'
+    set -l cd_file (command mktemp -t workroom-cd.XXXXXX); or return
+    env WORKROOM_CD_FILE=$cd_file workroom $argv
+    set -l ret $status
+    if test -s $cd_file
+        builtin cd (command cat $cd_file); or set ret $status
+    end
+    command rm -f $cd_file
+    return $ret
+end
+`
+
+var shellInitScripts = map[string]string{
+	"bash": posixShellInit,
+	"zsh":  posixShellInit,
+	"fish": fishShellInit,
+}
+
+var shellInitCmd = &cobra.Command{
+	Use:   "shell-init bash|zsh|fish",
+	Short: "Print shell integration for `workroom cd` and `create --cd`",
+	Long: `Print a shell function that wraps workroom, so that ` + "`workroom cd`" + ` and ` + "`workroom create --cd`" + ` can change the current shell's directory.
+
+Add one of the following to your shell's startup file:
+
+  bash (~/.bashrc):                  eval "$(workroom shell-init bash)"
+  zsh (~/.zshrc):                    eval "$(workroom shell-init zsh)"
+  fish (~/.config/fish/config.fish): workroom shell-init fish | source`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		script, ok := shellInitScripts[args[0]]
+		if !ok {
+			return fmt.Errorf("unsupported shell %q. Supported shells are bash, zsh and fish", args[0])
+		}
+		fmt.Fprint(cmd.OutOrStdout(), script)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shellInitCmd)
+}
@@ -1,28 +1,63 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/joelmoss/workroom/internal/errs"
+)
+
+const (
+	DefaultWorkroomsDir    = "~/workrooms"
+	DefaultWorkroomsLayout = "{workrooms_dir}/{project_id}/{name}"
 )
 
-const DefaultWorkroomsDir = "~/workrooms"
+var layoutPlaceholderRe = regexp.MustCompile(`\{([^{}]*)\}`)
 
-// Config manages the workroom configuration stored at ~/.config/workroom/config.json.
+// Config manages the global workroom configuration, stored at ~/.config/workroom/config.json by
+// default.
+//
+// Every read-modify-write holds an exclusive advisory lock on config.json.lock, so concurrent
+// workroom processes do not lose each other's changes. Writes go to a temporary file that is
+// renamed into place, and the last good config is kept in config.json.bak.
 type Config struct {
 	path string
+
+	// Warn receives warnings, such as when the config is restored from its backup. Defaults to
+	// os.Stderr.
+	Warn io.Writer
 }
 
-// New creates a Config. If configPath is empty, uses the default location.
+// New creates a Config. If configPath is empty, uses $WORKROOM_CONFIG, then
+// $XDG_CONFIG_HOME/workroom/config.json, then ~/.config/workroom/config.json.
 func New(configPath string) (*Config, error) {
 	if configPath == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("determine home directory: %w", err)
+		configPath = os.Getenv("WORKROOM_CONFIG")
+	}
+	if configPath == "" {
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		// The XDG spec says relative paths are invalid and should be ignored.
+		if !filepath.IsAbs(dir) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("determine home directory: %w", err)
+			}
+			dir = filepath.Join(home, ".config")
 		}
-		configPath = filepath.Join(home, ".config", "workroom", "config.json")
+		configPath = filepath.Join(dir, "workroom", "config.json")
+	}
+	configPath, err := expandPath(configPath)
+	if err != nil {
+		return nil, err
 	}
 	return &Config{path: configPath}, nil
 }
@@ -32,167 +67,411 @@ func (c *Config) Path() string {
 	return c.path
 }
 
-// Read returns the config data as a map, or an empty map if the file doesn't exist.
-func (c *Config) Read() (map[string]any, error) {
-	data, err := os.ReadFile(c.path)
+func (c *Config) backupPath() string {
+	return c.path + ".bak"
+}
+
+// Read returns the config file, or an empty one if the file doesn't exist. Config written by
+// older versions of workroom is migrated to the current schema. If the file cannot be parsed, it
+// is replaced by the last good config.
+func (c *Config) Read() (*File, error) {
+	return c.read(false)
+}
+
+func (c *Config) read(locked bool) (*File, error) {
+	f, err := readFile(c.path)
+	if err == nil || !errors.Is(err, errs.ErrInvalidConfig) {
+		return f, err
+	}
+	if restored, ok := c.restoreBackup(locked, err); ok {
+		return restored, nil
+	}
+	return nil, err
+}
+
+func readFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return map[string]any{}, nil
+			return newFile(), nil
 		}
-		return nil, fmt.Errorf("read config %s: %w", c.path, err)
+		return nil, fmt.Errorf("read config %s: %w", path, err)
 	}
-	var result map[string]any
-	if err := json.Unmarshal(data, &result); err != nil {
-		return nil, fmt.Errorf("parse config %s: %w", c.path, err)
+	f, err := parseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", errs.ErrInvalidConfig, path, err)
 	}
-	return result, nil
+	return f, nil
 }
 
-// Write persists the config data to disk, creating directories as needed.
-func (c *Config) Write(data map[string]any) error {
-	dir := filepath.Dir(c.path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return fmt.Errorf("create config directory %s: %w", dir, err)
+// restoreBackup replaces a config file that failed to parse with the last good config. The
+// unparseable file is kept as config.json.invalid.
+func (c *Config) restoreBackup(locked bool, cause error) (*File, bool) {
+	if !locked {
+		unlock, err := c.lock()
+		if err != nil {
+			return nil, false
+		}
+		defer unlock()
+
+		// Another process may have fixed the file while we waited for the lock.
+		if f, err := readFile(c.path); err == nil {
+			return f, true
+		}
+	}
+
+	data, err := os.ReadFile(c.backupPath())
+	if err != nil {
+		return nil, false
 	}
-	b, err := json.MarshalIndent(data, "", "  ")
+	f, err := parseFile(data)
+	if err != nil {
+		return nil, false
+	}
+
+	invalidPath := c.path + ".invalid"
+	if err := os.Rename(c.path, invalidPath); err != nil {
+		return nil, false
+	}
+	if err := writeAtomic(c.path, data); err != nil {
+		return nil, false
+	}
+
+	fmt.Fprintf(c.warnOutput(), "Warning: %v\nRestored the last good config from %s. The invalid config was saved to %s.\n", cause, c.backupPath(), invalidPath)
+	return f, true
+}
+
+func (c *Config) warnOutput() io.Writer {
+	if c.Warn != nil {
+		return c.Warn
+	}
+	return os.Stderr
+}
+
+// Write persists the config file to disk, creating directories as needed.
+func (c *Config) Write(f *File) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return c.write(f)
+}
+
+func (c *Config) write(f *File) error {
+	f.SchemaVersion = SchemaVersion
+	if f.Projects == nil {
+		f.Projects = map[string]*Project{}
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
-	if err := os.WriteFile(c.path, b, 0o644); err != nil {
+	if err := writeAtomic(c.path, b); err != nil {
 		return fmt.Errorf("write config %s: %w", c.path, err)
 	}
+	// What was just written is known to be good, so it becomes the backup.
+	if err := writeAtomic(c.backupPath(), b); err != nil {
+		return fmt.Errorf("write config backup %s: %w", c.backupPath(), err)
+	}
 	return nil
 }
 
-// AddWorkroom adds a workroom entry under the given parent project path.
-func (c *Config) AddWorkroom(parentPath, name, workroomPath, vcs string) error {
-	data, err := c.Read()
+// writeAtomic writes data to a temporary file in the same directory as path, and renames it into
+// place, so that readers never see a partially written file.
+func writeAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
 
-	project, ok := data[parentPath].(map[string]any)
-	if !ok {
-		project = map[string]any{"vcs": vcs, "workrooms": map[string]any{}}
-		data[parentPath] = project
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
 	}
-	project["vcs"] = vcs
-
-	workrooms, ok := project["workrooms"].(map[string]any)
-	if !ok {
-		workrooms = map[string]any{}
-		project["workrooms"] = workrooms
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
 	}
-	workrooms[name] = map[string]any{"path": workroomPath}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	return c.Write(data)
+// lock takes an exclusive advisory lock on config.json.lock, blocking until it is available. The
+// returned function releases it.
+func (c *Config) lock() (func(), error) {
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create config directory %s: %w", dir, err)
+	}
+	f, err := os.OpenFile(c.path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open config lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock config: %w", err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
 }
 
-// RemoveWorkroom removes a workroom entry. If the parent has no remaining workrooms, it is removed.
-func (c *Config) RemoveWorkroom(parentPath, name string) error {
-	data, err := c.Read()
+// update reads the config file, applies fn to it and writes it back, holding the config lock
+// throughout.
+func (c *Config) update(fn func(f *File) error) error {
+	unlock, err := c.lock()
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	project, ok := data[parentPath].(map[string]any)
-	if !ok {
-		return nil
+	f, err := c.read(true)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		return err
 	}
+	return c.write(f)
+}
+
+// AddWorkroom adds a workroom entry under the given parent project path. Re-adding an existing
+// workroom updates its path but keeps its creation time and branch.
+func (c *Config) AddWorkroom(parentPath, name, workroomPath, vcs string) error {
+	return c.AddWorkroomEntry(parentPath, vcs, name, WorkroomEntry{Path: workroomPath})
+}
+
+// AddWorkroomEntry adds a workroom entry under the given parent project path. The creation time
+// is set to now, unless the entry or an existing one has it. An empty branch keeps the existing
+// one.
+func (c *Config) AddWorkroomEntry(parentPath, vcs, name string, entry WorkroomEntry) error {
+	return c.update(func(f *File) error {
+		project, ok := f.Projects[parentPath]
+		if !ok {
+			project = &Project{Workrooms: map[string]*WorkroomEntry{}}
+			f.Projects[parentPath] = project
+		}
+		project.VCS = vcs
+
+		if existing, ok := project.Workrooms[name]; ok {
+			if entry.CreatedAt.IsZero() {
+				entry.CreatedAt = existing.CreatedAt
+			}
+			if entry.Branch == "" {
+				entry.Branch = existing.Branch
+			}
+			if entry.Lock == nil {
+				entry.Lock = existing.Lock
+			}
+		}
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now().UTC().Truncate(time.Second)
+		}
+		project.Workrooms[name] = &entry
+		return nil
+	})
+}
 
-	workrooms, ok := project["workrooms"].(map[string]any)
-	if !ok {
+// RenameWorkroom renames a workroom entry of the given parent project, and updates its path and
+// branch, in a single write. It keeps the entry's creation time.
+func (c *Config) RenameWorkroom(parentPath, oldName, newName, workroomPath, branch string) error {
+	return c.update(func(f *File) error {
+		project, ok := f.Projects[parentPath]
+		if !ok || project.Workrooms[oldName] == nil {
+			return fmt.Errorf("%w: '%s'", errs.ErrWorkroomNotFound, oldName)
+		}
+		if _, ok := project.Workrooms[newName]; ok {
+			return fmt.Errorf("workroom '%s' already exists", newName)
+		}
+
+		entry := project.Workrooms[oldName]
+		entry.Path = workroomPath
+		entry.Branch = branch
+		delete(project.Workrooms, oldName)
+		project.Workrooms[newName] = entry
 		return nil
-	}
+	})
+}
 
-	delete(workrooms, name)
+// SetLock locks a workroom entry of the given parent project with lock, or unlocks it when lock is
+// nil.
+func (c *Config) SetLock(parentPath, name string, lock *Lock) error {
+	return c.update(func(f *File) error {
+		project, ok := f.Projects[parentPath]
+		if !ok || project.Workrooms[name] == nil {
+			return fmt.Errorf("%w: '%s'", errs.ErrWorkroomNotFound, name)
+		}
+		project.Workrooms[name].Lock = lock
+		return nil
+	})
+}
 
-	if len(workrooms) == 0 {
-		delete(data, parentPath)
-	}
+// RemoveWorkroom removes a workroom entry. If the parent has no remaining workrooms, it is removed.
+func (c *Config) RemoveWorkroom(parentPath, name string) error {
+	return c.update(func(f *File) error {
+		project, ok := f.Projects[parentPath]
+		if !ok {
+			return nil
+		}
+
+		delete(project.Workrooms, name)
 
-	return c.Write(data)
+		if len(project.Workrooms) == 0 {
+			delete(f.Projects, parentPath)
+		}
+		return nil
+	})
 }
 
 // FindCurrentProject finds the project for the given directory. If cwd is a project path in the
 // config, returns it directly. Otherwise checks if cwd is a workroom path under any project.
-// Returns (projectPath, projectData, found).
-func (c *Config) FindCurrentProject(cwd string) (string, map[string]any, bool) {
-	data, err := c.Read()
+// Returns a nil project when neither matches.
+func (c *Config) FindCurrentProject(cwd string) (string, *Project, error) {
+	f, err := c.Read()
 	if err != nil {
-		return cwd, nil, false
+		return cwd, nil, err
 	}
 
-	if project, ok := data[cwd].(map[string]any); ok {
-		return cwd, project, true
+	if project, ok := f.Projects[cwd]; ok {
+		return cwd, project, nil
 	}
 
-	for projectPath, v := range data {
-		project, ok := v.(map[string]any)
-		if !ok {
-			continue
-		}
-		workrooms, ok := project["workrooms"].(map[string]any)
-		if !ok {
-			continue
-		}
-		for _, info := range workrooms {
-			infoMap, ok := info.(map[string]any)
-			if !ok {
-				continue
-			}
-			if infoMap["path"] == cwd {
-				return projectPath, project, true
+	for projectPath, project := range f.Projects {
+		for _, entry := range project.Workrooms {
+			if entry.Path == cwd {
+				return projectPath, project, nil
 			}
 		}
 	}
 
-	return cwd, nil, false
+	return cwd, nil, nil
 }
 
 // ProjectsWithWorkrooms returns all projects that have at least one workroom.
-func (c *Config) ProjectsWithWorkrooms() (map[string]map[string]any, error) {
-	data, err := c.Read()
+func (c *Config) ProjectsWithWorkrooms() (map[string]*Project, error) {
+	f, err := c.Read()
 	if err != nil {
 		return nil, err
 	}
 
-	result := map[string]map[string]any{}
-	for path, v := range data {
-		project, ok := v.(map[string]any)
-		if !ok {
-			continue
-		}
-		workrooms, ok := project["workrooms"].(map[string]any)
-		if !ok || len(workrooms) == 0 {
-			continue
+	result := map[string]*Project{}
+	for path, project := range f.Projects {
+		if len(project.Workrooms) > 0 {
+			result[path] = project
 		}
-		result[path] = project
 	}
 	return result, nil
 }
 
 // WorkroomsDir returns the configured workrooms directory, or the default ~/workrooms.
 func (c *Config) WorkroomsDir() (string, error) {
-	data, err := c.Read()
+	f, _, err := c.effective()
 	if err != nil {
-		return expandPath(DefaultWorkroomsDir)
+		return "", err
 	}
 
-	if dir, ok := data["workrooms_dir"].(string); ok && dir != "" {
-		return expandPath(dir)
+	if f.WorkroomsDir != "" {
+		return expandPath(f.WorkroomsDir)
 	}
 	return expandPath(DefaultWorkroomsDir)
 }
 
 // SetWorkroomsDir sets the workrooms_dir key in the config.
 func (c *Config) SetWorkroomsDir(path string) error {
-	data, err := c.Read()
-	if err != nil {
+	return c.update(func(f *File) error {
+		f.WorkroomsDir = path
+		return nil
+	})
+}
+
+// WorkroomsLayout returns the configured workrooms_layout template, or the default.
+func (c *Config) WorkroomsLayout() string {
+	f, _, err := c.effective()
+	if err != nil || f.WorkroomsLayout == "" {
+		return DefaultWorkroomsLayout
+	}
+	return f.WorkroomsLayout
+}
+
+// SetWorkroomsLayout sets the workrooms_layout key in the config.
+func (c *Config) SetWorkroomsLayout(layout string) error {
+	if err := validateLayout(layout); err != nil {
 		return err
 	}
-	data["workrooms_dir"] = path
-	return c.Write(data)
+	return c.update(func(f *File) error {
+		f.WorkroomsLayout = layout
+		return nil
+	})
+}
+
+// WorkroomPath returns the directory for the named workroom of the given project, by expanding
+// the workrooms_layout template. Supported placeholders are {workrooms_dir}, {project} (the base
+// name of the project directory), {project_id} (see ProjectID) and {name}.
+func (c *Config) WorkroomPath(projectPath, name string) (string, error) {
+	layout := c.WorkroomsLayout()
+	if err := validateLayout(layout); err != nil {
+		return "", err
+	}
+	dir, err := c.WorkroomsDir()
+	if err != nil {
+		return "", err
+	}
+	path := strings.NewReplacer(
+		"{workrooms_dir}", dir,
+		"{project_id}", ProjectID(projectPath),
+		"{project}", filepath.Base(projectPath),
+		"{name}", name,
+	).Replace(layout)
+	path, err = expandPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(filepath.FromSlash(path)), nil
+}
+
+// LogDir returns the directory that the output of the hooks of the named workroom of the given
+// project is logged to. Logs live beside the config file, and outlive the workroom, so that its
+// teardown can be looked at after it is deleted.
+func (c *Config) LogDir(projectPath, name string) string {
+	return filepath.Join(filepath.Dir(c.path), "logs", ProjectID(projectPath), name)
+}
+
+// ProjectID returns a directory name for the project at projectPath that is unique to where it
+// is: its base name followed by a short hash of its path, such as "myapp-1a2b3c4d". Projects of
+// the same name in different places get different IDs.
+func ProjectID(projectPath string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(projectPath)))
+	return filepath.Base(projectPath) + "-" + hex.EncodeToString(sum[:4])
+}
+
+// validateLayout checks that a layout template only uses known placeholders and includes {name}.
+func validateLayout(layout string) error {
+	hasName := false
+	for _, m := range layoutPlaceholderRe.FindAllStringSubmatch(layout, -1) {
+		switch m[1] {
+		case "name":
+			hasName = true
+		case "workrooms_dir", "project", "project_id":
+		default:
+			return fmt.Errorf("invalid workrooms_layout %q: unknown placeholder {%s}", layout, m[1])
+		}
+	}
+	if !hasName {
+		return fmt.Errorf("invalid workrooms_layout %q: must include {name}", layout)
+	}
+	return nil
 }
 
 // expandPath replaces a leading ~ with the user's home directory.
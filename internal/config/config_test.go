@@ -1,9 +1,19 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/joelmoss/workroom/internal/errs"
 )
 
 func newTestConfig(t *testing.T) *Config {
@@ -17,6 +27,8 @@ func newTestConfig(t *testing.T) *Config {
 }
 
 func TestConfigPath(t *testing.T) {
+	t.Setenv("WORKROOM_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
 	c, err := New("")
 	if err != nil {
 		t.Fatal(err)
@@ -28,14 +40,43 @@ func TestConfigPath(t *testing.T) {
 	}
 }
 
+func TestConfigPathXDGConfigHome(t *testing.T) {
+	t.Setenv("WORKROOM_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	c, _ := New("")
+	if c.Path() != filepath.Join("/xdg", "workroom", "config.json") {
+		t.Fatalf("expected config under XDG_CONFIG_HOME, got %s", c.Path())
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	c, _ = New("")
+	if strings.HasPrefix(c.Path(), "relative") {
+		t.Fatalf("expected relative XDG_CONFIG_HOME to be ignored, got %s", c.Path())
+	}
+}
+
+func TestConfigPathOverrides(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	t.Setenv("WORKROOM_CONFIG", "/ci/workroom.json")
+	c, _ := New("")
+	if c.Path() != "/ci/workroom.json" {
+		t.Fatalf("expected WORKROOM_CONFIG to win over XDG_CONFIG_HOME, got %s", c.Path())
+	}
+
+	c, _ = New("/flag/config.json")
+	if c.Path() != "/flag/config.json" {
+		t.Fatalf("expected an explicit path to win over WORKROOM_CONFIG, got %s", c.Path())
+	}
+}
+
 func TestReadEmpty(t *testing.T) {
 	c := newTestConfig(t)
-	data, err := c.Read()
+	f, err := c.Read()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(data) != 0 {
-		t.Fatalf("expected empty map, got %v", data)
+	if len(f.Projects) != 0 || f.SchemaVersion != SchemaVersion {
+		t.Fatalf("expected empty config, got %+v", f)
 	}
 }
 
@@ -46,20 +87,72 @@ func TestAddWorkroom(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	data, err := c.Read()
+	f, err := c.Read()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	project := data["/project"].(map[string]any)
-	if project["vcs"] != "jj" {
-		t.Fatalf("expected vcs jj, got %v", project["vcs"])
+	project := f.Projects["/project"]
+	if project.VCS != "jj" {
+		t.Fatalf("expected vcs jj, got %v", project.VCS)
+	}
+
+	foo := project.Workrooms["foo"]
+	if foo.Path != "/foo" {
+		t.Fatalf("expected path /foo, got %v", foo.Path)
+	}
+}
+
+func TestAddWorkroomRecordsCreatedAt(t *testing.T) {
+	c := newTestConfig(t)
+
+	if err := c.AddWorkroom("/project", "foo", "/foo", "jj"); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := c.Read()
+	foo := f.Projects["/project"].Workrooms["foo"]
+	if time.Since(foo.CreatedAt) > time.Minute {
+		t.Fatalf("expected recent created_at, got %v", foo.CreatedAt)
+	}
+
+	// Re-adding keeps the original creation time.
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	foo.CreatedAt = createdAt
+	c.Write(f)
+	if err := c.AddWorkroom("/project", "foo", "/moved/foo", "jj"); err != nil {
+		t.Fatal(err)
+	}
+	f, _ = c.Read()
+	foo = f.Projects["/project"].Workrooms["foo"]
+	if !foo.CreatedAt.Equal(createdAt) || foo.Path != "/moved/foo" {
+		t.Fatalf("expected path updated and created_at kept, got %+v", foo)
+	}
+}
+
+func TestSetLock(t *testing.T) {
+	c := newTestConfig(t)
+	c.AddWorkroom("/project", "foo", "/foo", "git")
+
+	if err := c.SetLock("/project", "foo", &Lock{Reason: "hotfix"}); err != nil {
+		t.Fatal(err)
+	}
+	// Re-adding, as migrate does, keeps the lock.
+	c.AddWorkroom("/project", "foo", "/moved/foo", "git")
+	f, _ := c.Read()
+	if lock := f.Projects["/project"].Workrooms["foo"].Lock; lock == nil || lock.Reason != "hotfix" {
+		t.Fatalf("expected lock to be kept, got %+v", lock)
+	}
+
+	if err := c.SetLock("/project", "foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	f, _ = c.Read()
+	if lock := f.Projects["/project"].Workrooms["foo"].Lock; lock != nil {
+		t.Fatalf("expected lock to be removed, got %+v", lock)
 	}
 
-	workrooms := project["workrooms"].(map[string]any)
-	foo := workrooms["foo"].(map[string]any)
-	if foo["path"] != "/foo" {
-		t.Fatalf("expected path /foo, got %v", foo["path"])
+	if err := c.SetLock("/project", "bar", nil); !errors.Is(err, errs.ErrWorkroomNotFound) {
+		t.Fatalf("expected ErrWorkroomNotFound, got %v", err)
 	}
 }
 
@@ -73,21 +166,17 @@ func TestAddMultipleWorkrooms(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	data, err := c.Read()
+	f, err := c.Read()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	project := data["/project"].(map[string]any)
-	workrooms := project["workrooms"].(map[string]any)
-
-	foo := workrooms["foo"].(map[string]any)
-	if foo["path"] != "/foo" {
-		t.Fatalf("expected /foo, got %v", foo["path"])
+	workrooms := f.Projects["/project"].Workrooms
+	if workrooms["foo"].Path != "/foo" {
+		t.Fatalf("expected /foo, got %v", workrooms["foo"].Path)
 	}
-	bar := workrooms["bar"].(map[string]any)
-	if bar["path"] != "/bar" {
-		t.Fatalf("expected /bar, got %v", bar["path"])
+	if workrooms["bar"].Path != "/bar" {
+		t.Fatalf("expected /bar, got %v", workrooms["bar"].Path)
 	}
 }
 
@@ -101,12 +190,12 @@ func TestRemoveWorkroomCleansUpEmptyParent(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	data, err := c.Read()
+	f, err := c.Read()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if _, ok := data["/project"]; ok {
+	if _, ok := f.Projects["/project"]; ok {
 		t.Fatal("expected /project to be removed")
 	}
 }
@@ -124,20 +213,17 @@ func TestRemoveWorkroomKeepsRemainingWorkrooms(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	data, err := c.Read()
+	f, err := c.Read()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	project := data["/project"].(map[string]any)
-	workrooms := project["workrooms"].(map[string]any)
-
+	workrooms := f.Projects["/project"].Workrooms
 	if _, ok := workrooms["foo"]; ok {
 		t.Fatal("expected foo to be removed")
 	}
-	bar := workrooms["bar"].(map[string]any)
-	if bar["path"] != "/bar" {
-		t.Fatalf("expected /bar, got %v", bar["path"])
+	if workrooms["bar"].Path != "/bar" {
+		t.Fatalf("expected /bar, got %v", workrooms["bar"].Path)
 	}
 }
 
@@ -148,12 +234,12 @@ func TestRemoveNonexistentParent(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	data, err := c.Read()
+	f, err := c.Read()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(data) != 0 {
-		t.Fatalf("expected empty config, got %v", data)
+	if len(f.Projects) != 0 {
+		t.Fatalf("expected empty config, got %v", f.Projects)
 	}
 }
 
@@ -200,21 +286,96 @@ func TestWorkroomsDirExpandsTilde(t *testing.T) {
 	}
 }
 
+func TestWorkroomPathDefaultLayout(t *testing.T) {
+	c := newTestConfig(t)
+	if err := c.SetWorkroomsDir("/custom/workrooms"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.WorkroomPath("/code/myapp", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/custom/workrooms/" + ProjectID("/code/myapp") + "/foo"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestWorkroomPathSeparatesProjectsOfTheSameName(t *testing.T) {
+	c := newTestConfig(t)
+	work, err := c.WorkroomPath("/home/me/work/app", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oss, err := c.WorkroomPath("/home/me/oss/app", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work == oss {
+		t.Fatalf("expected projects named app in different places to get different paths, both got %s", work)
+	}
+	if !strings.HasPrefix(filepath.Base(filepath.Dir(work)), "app-") {
+		t.Fatalf("expected the project's directory to be named after it, got %s", work)
+	}
+}
+
+func TestWorkroomPathConfiguredLayout(t *testing.T) {
+	c := newTestConfig(t)
+	if err := c.SetWorkroomsDir("/custom/workrooms"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetWorkroomsLayout("{workrooms_dir}/{project}-{name}"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.WorkroomPath("/code/myapp", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "/custom/workrooms/myapp-foo" {
+		t.Fatalf("expected /custom/workrooms/myapp-foo, got %s", got)
+	}
+}
+
+func TestWorkroomPathLayoutExpandsTilde(t *testing.T) {
+	c := newTestConfig(t)
+	if err := c.SetWorkroomsLayout("~/src/{project}/{name}"); err != nil {
+		t.Fatal(err)
+	}
+	home, _ := os.UserHomeDir()
+	expected := filepath.Join(home, "src", "myapp", "foo")
+	got, err := c.WorkroomPath("/code/myapp", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestSetWorkroomsLayoutRejectsInvalid(t *testing.T) {
+	c := newTestConfig(t)
+	if err := c.SetWorkroomsLayout("{workrooms_dir}/{project}"); err == nil {
+		t.Fatal("expected error for layout without {name}")
+	}
+	if err := c.SetWorkroomsLayout("{workrooms_dir}/{branch}/{name}"); err == nil {
+		t.Fatal("expected error for unknown placeholder")
+	}
+}
+
 func TestFindCurrentProjectAsProject(t *testing.T) {
 	c := newTestConfig(t)
 	if err := c.AddWorkroom("/project", "foo", "/foo", "jj"); err != nil {
 		t.Fatal(err)
 	}
 
-	path, project, found := c.FindCurrentProject("/project")
-	if !found {
+	path, project, err := c.FindCurrentProject("/project")
+	if err != nil || project == nil {
 		t.Fatal("expected to find project")
 	}
 	if path != "/project" {
 		t.Fatalf("expected /project, got %s", path)
 	}
-	if project["vcs"] != "jj" {
-		t.Fatalf("expected jj, got %v", project["vcs"])
+	if project.VCS != "jj" {
+		t.Fatalf("expected jj, got %v", project.VCS)
 	}
 }
 
@@ -224,24 +385,24 @@ func TestFindCurrentProjectAsWorkroom(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	path, project, found := c.FindCurrentProject("/workrooms/foo")
-	if !found {
+	path, project, err := c.FindCurrentProject("/workrooms/foo")
+	if err != nil || project == nil {
 		t.Fatal("expected to find project")
 	}
 	if path != "/project" {
 		t.Fatalf("expected /project, got %s", path)
 	}
-	if project["vcs"] != "jj" {
-		t.Fatalf("expected jj, got %v", project["vcs"])
+	if project.VCS != "jj" {
+		t.Fatalf("expected jj, got %v", project.VCS)
 	}
 }
 
 func TestFindCurrentProjectNotFound(t *testing.T) {
 	c := newTestConfig(t)
 
-	path, project, found := c.FindCurrentProject("/unknown")
-	if found {
-		t.Fatal("expected not found")
+	path, project, err := c.FindCurrentProject("/unknown")
+	if err != nil {
+		t.Fatal(err)
 	}
 	if path != "/unknown" {
 		t.Fatalf("expected /unknown, got %s", path)
@@ -284,3 +445,452 @@ func TestCreatesConfigDirOnWrite(t *testing.T) {
 		t.Fatalf("expected config file to exist: %v", err)
 	}
 }
+
+func writeConfigFile(t *testing.T, c *Config, contents string) {
+	t.Helper()
+	if err := os.WriteFile(c.Path(), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExternalBranchRoundTrips(t *testing.T) {
+	c := newTestConfig(t)
+	if err := c.AddWorkroomEntry("/project", "git", "foo", WorkroomEntry{Path: "/foo", Branch: "feature", ExternalBranch: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := c.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := f.Projects["/project"].Workrooms["foo"]; !entry.ExternalBranch || entry.Branch != "feature" {
+		t.Fatalf("expected the external branch to be kept, got %+v", entry)
+	}
+}
+
+func TestReadMigratesLegacyLayout(t *testing.T) {
+	c := newTestConfig(t)
+	writeConfigFile(t, c, `{
+  "workrooms_dir": "/custom/workrooms",
+  "trunk": "main",
+  "/project": {
+    "vcs": "git",
+    "workrooms": {
+      "foo": {"path": "/foo", "created_at": "2020-01-02T03:04:05Z"},
+      "bar": {"path": "/bar"}
+    }
+  }
+}`)
+
+	f, err := c.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.WorkroomsDir != "/custom/workrooms" || f.Trunk != "main" {
+		t.Fatalf("expected settings to be kept, got %+v", f)
+	}
+	project := f.Projects["/project"]
+	if project == nil || project.VCS != "git" || len(project.Workrooms) != 2 {
+		t.Fatalf("expected /project with 2 workrooms, got %+v", project)
+	}
+	if !project.Workrooms["foo"].CreatedAt.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("expected created_at to be kept, got %v", project.Workrooms["foo"].CreatedAt)
+	}
+
+	// The next write uses the current schema.
+	if err := c.AddWorkroom("/project", "baz", "/baz", "git"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(c.Path())
+	var raw map[string]any
+	json.Unmarshal(data, &raw)
+	if raw["schema_version"] != float64(SchemaVersion) {
+		t.Fatalf("expected schema_version %d, got %v", SchemaVersion, raw["schema_version"])
+	}
+	if _, ok := raw["/project"]; ok {
+		t.Fatalf("expected projects to move under \"projects\", got %s", data)
+	}
+	if _, ok := raw["projects"].(map[string]any)["/project"]; !ok {
+		t.Fatalf("expected /project under \"projects\", got %s", data)
+	}
+}
+
+func TestReadValidationErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantKey  string
+	}{
+		{"wrong type", `{"schema_version": 1, "workrooms_dir": 42, "projects": {}}`, "workrooms_dir"},
+		{"unknown key", `{"schema_version": 1, "workroom_dir": "/x", "projects": {}}`, "workroom_dir"},
+		{"missing path", `{"schema_version": 1, "projects": {"/p": {"vcs": "git", "workrooms": {"foo": {}}}}}`, `projects."/p".workrooms.foo.path`},
+		{"bad vcs", `{"schema_version": 1, "projects": {"/p": {"vcs": "svn", "workrooms": {}}}}`, `projects."/p".vcs`},
+		{"bad created_at", `{"schema_version": 1, "projects": {"/p": {"vcs": "jj", "workrooms": {"foo": {"path": "/foo", "created_at": "yesterday"}}}}}`, `projects."/p".workrooms.foo.created_at`},
+		{"unknown workroom key", `{"schema_version": 1, "projects": {"/p": {"vcs": "jj", "workrooms": {"foo": {"path": "/foo", "owner": "x"}}}}}`, `projects."/p".workrooms.foo.owner`},
+		{"legacy project not an object", `{"/p": "oops"}`, `"/p"`},
+		{"bad layout", `{"schema_version": 1, "workrooms_layout": "{project}", "projects": {}}`, "workrooms_layout"},
+		{"newer schema", `{"schema_version": 99, "projects": {}}`, "schema_version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfig(t)
+			writeConfigFile(t, c, tt.contents)
+
+			_, err := c.Read()
+			if !errors.Is(err, errs.ErrInvalidConfig) {
+				t.Fatalf("expected ErrInvalidConfig, got %v", err)
+			}
+			var vErr *ValidationError
+			if !errors.As(err, &vErr) || vErr.Key != tt.wantKey {
+				t.Fatalf("expected error for key %s, got %v", tt.wantKey, err)
+			}
+		})
+	}
+}
+
+func TestConcurrentAddWorkroomKeepsAllEntries(t *testing.T) {
+	c := newTestConfig(t)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			// Each goroutine uses its own Config, as separate processes would.
+			other, _ := New(c.Path())
+			name := fmt.Sprintf("wr%d", i)
+			if err := other.AddWorkroom("/project", name, "/"+name, "git"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	f, err := c.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(f.Projects["/project"].Workrooms); got != 20 {
+		t.Fatalf("expected 20 workrooms, got %d", got)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(c.Path()))
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Fatalf("expected no temporary files to be left, found %s", e.Name())
+		}
+	}
+}
+
+func TestReadRestoresBackupWhenParsingFails(t *testing.T) {
+	c := newTestConfig(t)
+	var warnings bytes.Buffer
+	c.Warn = &warnings
+
+	if err := c.AddWorkroom("/project", "foo", "/foo", "jj"); err != nil {
+		t.Fatal(err)
+	}
+	writeConfigFile(t, c, `{"schema_version": 1, "projects": {`)
+
+	f, err := c.Read()
+	if err != nil {
+		t.Fatalf("expected backup to be restored, got %v", err)
+	}
+	if _, ok := f.Projects["/project"].Workrooms["foo"]; !ok {
+		t.Fatalf("expected foo from backup, got %+v", f.Projects)
+	}
+	if !strings.Contains(warnings.String(), "Restored the last good config") {
+		t.Fatalf("expected restore warning, got %q", warnings.String())
+	}
+	if invalid, _ := os.ReadFile(c.Path() + ".invalid"); string(invalid) != `{"schema_version": 1, "projects": {` {
+		t.Fatalf("expected invalid config to be kept, got %q", invalid)
+	}
+	if _, err := readFile(c.Path()); err != nil {
+		t.Fatalf("expected restored config on disk, got %v", err)
+	}
+}
+
+func TestReadWithoutBackupReturnsParseError(t *testing.T) {
+	c := newTestConfig(t)
+	writeConfigFile(t, c, `not json`)
+
+	if _, err := c.Read(); !errors.Is(err, errs.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	c := newTestConfig(t)
+	writeConfigFile(t, c, `{
+  "schema_version": 1,
+  "trunk": "main",
+  "branch_prefix": "me/",
+  "name_style": "short",
+  "env": {"SHARED": "global", "GLOBAL_ONLY": "1"},
+  "projects": {}
+}`)
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, ".workroom.toml"), []byte(`
+trunk = "develop"
+copy = [".env"]
+
+[env]
+SHARED = "project"
+
+[hooks]
+setup = "bin/setup"
+`), 0o644)
+
+	r, err := c.Resolve(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Trunk != "develop" {
+		t.Errorf("expected project trunk to win, got %q", r.Trunk)
+	}
+	if r.BranchPrefix != "me/" || r.NameStyle != "short" {
+		t.Errorf("expected global branch_prefix and name_style to apply, got %q and %q", r.BranchPrefix, r.NameStyle)
+	}
+	if r.Env["SHARED"] != "project" || r.Env["GLOBAL_ONLY"] != "1" {
+		t.Errorf("expected env to be merged, got %v", r.Env)
+	}
+	if r.Hooks.Setup != "bin/setup" || !slices.Equal(r.Copy, []string{".env"}) {
+		t.Errorf("expected hooks and copy from project, got %+v", r)
+	}
+	if len(r.Sources) != 2 || r.Sources[1] != filepath.Join(project, ".workroom.toml") {
+		t.Errorf("expected both config files as sources, got %v", r.Sources)
+	}
+}
+
+func TestResolveDefaults(t *testing.T) {
+	c := newTestConfig(t)
+
+	r, err := c.Resolve(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BranchPrefix != DefaultBranchPrefix || r.NameStyle != "friendly" || r.Trunk != "" {
+		t.Fatalf("expected defaults, got %+v", r)
+	}
+}
+
+func TestLoadProjectConfigJSON(t *testing.T) {
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, ".workroom.json"), []byte(`{"branch_prefix": "wr/", "symlink": ["node_modules"]}`), 0o644)
+
+	pc, path, err := LoadProjectConfig(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc.BranchPrefix != "wr/" || !slices.Equal(pc.Symlink, []string{"node_modules"}) {
+		t.Fatalf("unexpected project config %+v", pc)
+	}
+	if path != filepath.Join(project, ".workroom.json") {
+		t.Fatalf("unexpected path %s", path)
+	}
+}
+
+func TestLoadProjectConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"unknown toml key", ".workroom.toml", "trunk = \"main\"\n[hooks]\nsetpu = \"x\"\n", "hooks.setpu: unknown key"},
+		{"unknown json key", ".workroom.json", `{"hooks": {"setpu": "x"}}`, "hooks.setpu: unknown key"},
+		{"bad name style", ".workroom.toml", `name_style = "emoji"`, "name_style: must be one of"},
+		{"absolute copy path", ".workroom.json", `{"copy": ["/etc/passwd"]}`, "copy[0]:"},
+		{"escaping symlink path", ".workroom.json", `{"symlink": ["../secrets"]}`, "symlink[0]:"},
+		{"bad env name", ".workroom.toml", "[env]\n\"NOT-VALID\" = \"x\"\n", "env.NOT-VALID:"},
+		{"bad hook timeout", ".workroom.toml", "[hooks.timeout]\nsetup = \"soon\"\n", "hooks.timeout.setup:"},
+		{"timeout of unknown hook", ".workroom.json", `{"hooks": {"timeout": {"setpu": "1m"}}}`, "hooks.timeout.setpu: unknown hook"},
+		{"empty hook interpreter", ".workroom.toml", "[hooks.interpreter]\nsetup = \" \"\n", "hooks.interpreter.setup: must not be empty"},
+		{"unknown include mode", ".workroom.toml", `include = ["hardlinks:.env"]`, "include[0]: unknown mode \"hardlinks\""},
+		{"escaping include pattern", ".workroom.json", `{"include": ["symlink:../*"]}`, "include[0]:"},
+		{"bad include pattern", ".workroom.toml", `include = [".env", "config/[a-"]`, "include[1]:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := t.TempDir()
+			os.WriteFile(filepath.Join(project, tt.file), []byte(tt.content), 0o644)
+
+			_, _, err := LoadProjectConfig(project)
+			if !errors.Is(err, errs.ErrInvalidConfig) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected invalid config error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadProjectConfigRejectsBothFiles(t *testing.T) {
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, ".workroom.toml"), []byte(""), 0o644)
+	os.WriteFile(filepath.Join(project, ".workroom.json"), []byte("{}"), 0o644)
+
+	if _, _, err := LoadProjectConfig(project); !errors.Is(err, errs.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestSetValueGlobal(t *testing.T) {
+	c := newTestConfig(t)
+	c.AddWorkroom("/projects/app", "wr", "/workrooms/app/wr", "git")
+
+	if err := c.SetValue(ScopeGlobal, "", "trunk", []string{"main"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetValue(ScopeGlobal, "", "env.RAILS_ENV", []string{"development"}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := c.Read()
+	if f.Trunk != "main" || f.Env["RAILS_ENV"] != "development" {
+		t.Fatalf("expected settings to be written, got %+v", f)
+	}
+	if _, ok := f.Projects["/projects/app"].Workrooms["wr"]; !ok {
+		t.Fatal("expected projects to be preserved")
+	}
+
+	if err := c.UnsetValue(ScopeGlobal, "", "env.RAILS_ENV"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := c.Values(ScopeGlobal, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values["trunk"] != "main" {
+		t.Fatalf("expected only trunk to remain, got %v", values)
+	}
+}
+
+func TestSetValueProject(t *testing.T) {
+	c := newTestConfig(t)
+	project := t.TempDir()
+
+	if err := c.SetValue(ScopeProject, project, "copy", []string{".env", "config/master.key"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetValue(ScopeProject, project, "hooks.setup", []string{"bin/setup"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, path, err := LoadProjectConfig(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(project, ".workroom.toml") {
+		t.Fatalf("expected a new .workroom.toml, got %s", path)
+	}
+	if !slices.Equal(pc.Copy, []string{".env", "config/master.key"}) || pc.Hooks.Setup != "bin/setup" {
+		t.Fatalf("unexpected project config %+v", pc)
+	}
+
+	values, err := c.Values("", project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["hooks.setup"] != "bin/setup" || values["branch_prefix"] != DefaultBranchPrefix {
+		t.Fatalf("expected resolved values, got %v", values)
+	}
+}
+
+func TestSetValueKeepsProjectJSON(t *testing.T) {
+	c := newTestConfig(t)
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, ".workroom.json"), []byte(`{"trunk": "main"}`), 0o644)
+
+	if err := c.SetValue(ScopeProject, project, "name_style", []string{"short"}); err != nil {
+		t.Fatal(err)
+	}
+	pc, path, err := LoadProjectConfig(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != ".workroom.json" || pc.Trunk != "main" || pc.NameStyle != "short" {
+		t.Fatalf("unexpected project config %s: %+v", path, pc)
+	}
+}
+
+func TestSetValueErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		scope  Scope
+		key    string
+		values []string
+		want   string
+	}{
+		{"unknown key", ScopeGlobal, "trunks", []string{"main"}, `unknown key "trunks"`},
+		{"wrong scope", ScopeProject, "workrooms_dir", []string{"~/wr"}, "workrooms_dir is not a project setting"},
+		{"project only", ScopeGlobal, "hooks.setup", []string{"bin/setup"}, "hooks.setup is not a global setting"},
+		{"too many values", ScopeGlobal, "trunk", []string{"main", "develop"}, "trunk takes a single value"},
+		{"invalid value", ScopeGlobal, "name_style", []string{"emoji"}, "name_style: must be one of"},
+		{"invalid layout", ScopeGlobal, "workrooms_layout", []string{"{nope}"}, "workrooms_layout:"},
+		{"invalid env name", ScopeProject, "env.NOT-VALID", []string{"x"}, "env.NOT-VALID: is not a valid environment variable name"},
+		{"invalid path", ScopeProject, "symlink", []string{"../secrets"}, "symlink[0]:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfig(t)
+			err := c.SetValue(tt.scope, t.TempDir(), tt.key, tt.values)
+			if !errors.Is(err, errs.ErrInvalidConfig) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected invalid config error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestWriteScopeRejectsInvalid(t *testing.T) {
+	c := newTestConfig(t)
+	c.SetValue(ScopeGlobal, "", "trunk", []string{"main"})
+
+	err := c.WriteScope(ScopeGlobal, "", []byte(`{"schema_version": 1, "trunk": 1}`))
+	if !errors.Is(err, errs.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	if f, _ := c.Read(); f.Trunk != "main" {
+		t.Fatalf("expected config to be unchanged, got %+v", f)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	c := newTestConfig(t)
+	c.SetValue(ScopeGlobal, "", "workrooms_dir", []string{"/from/config"})
+	c.SetValue(ScopeGlobal, "", "trunk", []string{"main"})
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, ".workroom.toml"), []byte(`trunk = "develop"`), 0o644)
+	t.Setenv("WORKROOM_WORKROOMS_DIR", "/from/env")
+	t.Setenv("WORKROOM_TRUNK", "release")
+
+	dir, err := c.WorkroomsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "/from/env" {
+		t.Errorf("expected WORKROOM_WORKROOMS_DIR to win, got %s", dir)
+	}
+	r, err := c.Resolve(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Trunk != "release" {
+		t.Errorf("expected WORKROOM_TRUNK to win over the project config, got %s", r.Trunk)
+	}
+	if !slices.Contains(r.Sources, "$WORKROOM_TRUNK") {
+		t.Errorf("expected overrides in sources, got %v", r.Sources)
+	}
+
+	// Overrides are never written back.
+	c.SetValue(ScopeGlobal, "", "name_style", []string{"short"})
+	if f, _ := c.Read(); f.WorkroomsDir != "/from/config" || f.Trunk != "main" {
+		t.Errorf("expected config file to keep its own values, got %+v", f)
+	}
+}
+
+func TestEnvOverrideValidation(t *testing.T) {
+	c := newTestConfig(t)
+	t.Setenv("WORKROOM_NAME_STYLE", "emoji")
+
+	_, err := c.Resolve(t.TempDir())
+	if !errors.Is(err, errs.ErrInvalidConfig) || !strings.Contains(err.Error(), "WORKROOM_NAME_STYLE: must be one of") {
+		t.Fatalf("expected invalid WORKROOM_NAME_STYLE, got %v", err)
+	}
+}
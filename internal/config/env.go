@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joelmoss/workroom/internal/errs"
+)
+
+// envSettings are the settings that can be overridden with a WORKROOM_<KEY> environment variable,
+// such as WORKROOM_WORKROOMS_DIR. Overrides take precedence over both the global and project
+// config, and are never written to either.
+var envSettings = []string{"workrooms_dir", "workrooms_layout", "trunk", "branch_prefix", "name_style"}
+
+// EnvVar returns the environment variable that overrides the setting key.
+func EnvVar(key string) string {
+	return "WORKROOM_" + strings.ToUpper(key)
+}
+
+// envOverrides returns the settings overridden by the environment, keyed by setting key. Empty
+// variables are ignored.
+func envOverrides() (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, key := range envSettings {
+		if v := os.Getenv(EnvVar(key)); v != "" {
+			overrides[key] = v
+		}
+	}
+
+	f := &File{}
+	applyEnv(f, overrides)
+	if err := validateSettings(f); err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			validationErr.Key = EnvVar(validationErr.Key)
+		}
+		return nil, fmt.Errorf("%w: %w", errs.ErrInvalidConfig, err)
+	}
+	return overrides, nil
+}
+
+func applyEnv(f *File, overrides map[string]string) {
+	for key, v := range overrides {
+		switch key {
+		case "workrooms_dir":
+			f.WorkroomsDir = v
+		case "workrooms_layout":
+			f.WorkroomsLayout = v
+		case "trunk":
+			f.Trunk = v
+		case "branch_prefix":
+			f.BranchPrefix = v
+		case "name_style":
+			f.NameStyle = v
+		}
+	}
+}
+
+// effective returns the global config with any environment overrides applied. It must not be
+// written back.
+func (c *Config) effective() (*File, map[string]string, error) {
+	f, err := c.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	overrides, err := envOverrides()
+	if err != nil {
+		return nil, nil, err
+	}
+	applyEnv(f, overrides)
+	return f, overrides, nil
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joelmoss/workroom/internal/namegen"
+)
+
+// SchemaVersion is the version of the config file schema written by this version of workroom.
+// Files without a schema_version use the original layout, where projects sat at the top level
+// alongside settings, and are migrated when read.
+const SchemaVersion = 1
+
+// File is the contents of the config file.
+type File struct {
+	SchemaVersion   int                 `json:"schema_version"`
+	WorkroomsDir    string              `json:"workrooms_dir,omitempty"`
+	WorkroomsLayout string              `json:"workrooms_layout,omitempty"`
+	Trunk           string              `json:"trunk,omitempty"`
+	BranchPrefix    string              `json:"branch_prefix,omitempty"`
+	NameStyle       string              `json:"name_style,omitempty"`
+	Env             map[string]string   `json:"env,omitempty"`
+	Projects        map[string]*Project `json:"projects"`
+}
+
+// Project is a parent project and the workrooms created from it, keyed by name.
+type Project struct {
+	VCS       string                    `json:"vcs"`
+	Workrooms map[string]*WorkroomEntry `json:"workrooms"`
+}
+
+// WorkroomEntry is a single workroom of a project.
+type WorkroomEntry struct {
+	Path           string    `json:"path"`
+	Branch         string    `json:"branch,omitempty"`          // Git branch or JJ workspace name
+	ExternalBranch bool      `json:"external_branch,omitempty"` // Branch was not created by workroom, so is kept
+	CreatedAt      time.Time `json:"created_at,omitzero"`
+	Lock           *Lock     `json:"lock,omitempty"` // nil unless the workroom is locked
+}
+
+// Lock protects a workroom from being deleted, pruned or moved.
+type Lock struct {
+	Reason   string    `json:"reason,omitempty"`
+	LockedAt time.Time `json:"locked_at,omitzero"`
+}
+
+func newFile() *File {
+	return &File{SchemaVersion: SchemaVersion, Projects: map[string]*Project{}}
+}
+
+// ValidationError reports an invalid value in the config file. Key is the dotted path to the
+// value, with project paths quoted.
+type ValidationError struct {
+	Key string
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Msg)
+}
+
+// parseFile decodes and validates a config file, migrating it from the original layout if needed.
+func parseFile(data []byte) (*File, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, err
+	}
+	if top == nil {
+		return nil, &ValidationError{Key: "(root)", Msg: "expected an object"}
+	}
+
+	f := newFile()
+	if raw, ok := top["schema_version"]; ok {
+		if err := decodeStrict(raw, &f.SchemaVersion, "schema_version"); err != nil {
+			return nil, err
+		}
+		if f.SchemaVersion > SchemaVersion {
+			return nil, &ValidationError{Key: "schema_version", Msg: fmt.Sprintf("version %d was written by a newer version of workroom. Please upgrade", f.SchemaVersion)}
+		}
+		if f.SchemaVersion < 1 {
+			return nil, &ValidationError{Key: "schema_version", Msg: fmt.Sprintf("unsupported version %d", f.SchemaVersion)}
+		}
+		return f, parseCurrent(top, f)
+	}
+	return f, parseLegacy(top, f)
+}
+
+// parseSetting decodes a top-level setting into f, reporting whether key is a setting.
+func parseSetting(key string, raw json.RawMessage, f *File) (bool, error) {
+	switch key {
+	case "workrooms_dir":
+		return true, decodeStrict(raw, &f.WorkroomsDir, key)
+	case "workrooms_layout":
+		return true, decodeStrict(raw, &f.WorkroomsLayout, key)
+	case "trunk":
+		return true, decodeStrict(raw, &f.Trunk, key)
+	case "branch_prefix":
+		return true, decodeStrict(raw, &f.BranchPrefix, key)
+	case "name_style":
+		return true, decodeStrict(raw, &f.NameStyle, key)
+	case "env":
+		return true, decodeStrict(raw, &f.Env, key)
+	}
+	return false, nil
+}
+
+func parseCurrent(top map[string]json.RawMessage, f *File) error {
+	for key, raw := range top {
+		if ok, err := parseSetting(key, raw, f); ok {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var err error
+		switch key {
+		case "schema_version":
+		case "projects":
+			var projects map[string]json.RawMessage
+			if err = decodeStrict(raw, &projects, key); err != nil {
+				break
+			}
+			for path, raw := range projects {
+				if f.Projects[path], err = parseProject(raw, "projects."+strconv.Quote(path)); err != nil {
+					break
+				}
+			}
+		default:
+			err = &ValidationError{Key: key, Msg: "unknown key"}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return validateSettings(f)
+}
+
+// parseLegacy reads the original layout, where every top-level key that is not a setting is a
+// project path.
+func parseLegacy(top map[string]json.RawMessage, f *File) error {
+	for key, raw := range top {
+		if ok, err := parseSetting(key, raw, f); ok {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var err error
+		if f.Projects[key], err = parseProject(raw, strconv.Quote(key)); err != nil {
+			return err
+		}
+	}
+	return validateSettings(f)
+}
+
+func parseProject(raw json.RawMessage, key string) (*Project, error) {
+	var p struct {
+		VCS       string                     `json:"vcs"`
+		Workrooms map[string]json.RawMessage `json:"workrooms"`
+	}
+	if err := decodeStrict(raw, &p, key); err != nil {
+		return nil, err
+	}
+	switch p.VCS {
+	case "git", "jj":
+	case "":
+		return nil, &ValidationError{Key: key + ".vcs", Msg: "is required"}
+	default:
+		return nil, &ValidationError{Key: key + ".vcs", Msg: fmt.Sprintf("must be \"git\" or \"jj\", got %q", p.VCS)}
+	}
+
+	project := &Project{VCS: p.VCS, Workrooms: map[string]*WorkroomEntry{}}
+	for name, raw := range p.Workrooms {
+		entryKey := key + ".workrooms." + name
+		var e struct {
+			Path      string `json:"path"`
+			Branch    string `json:"branch"`
+			CreatedAt string `json:"created_at"`
+			External  bool   `json:"external_branch"`
+			Lock      *struct {
+				Reason   string `json:"reason"`
+				LockedAt string `json:"locked_at"`
+			} `json:"lock"`
+		}
+		if err := decodeStrict(raw, &e, entryKey); err != nil {
+			return nil, err
+		}
+		if e.Path == "" {
+			return nil, &ValidationError{Key: entryKey + ".path", Msg: "is required"}
+		}
+		entry := &WorkroomEntry{Path: e.Path, Branch: e.Branch, ExternalBranch: e.External}
+		var err error
+		if entry.CreatedAt, err = parseTimestamp(e.CreatedAt, entryKey+".created_at"); err != nil {
+			return nil, err
+		}
+		if e.Lock != nil {
+			entry.Lock = &Lock{Reason: e.Lock.Reason}
+			if entry.Lock.LockedAt, err = parseTimestamp(e.Lock.LockedAt, entryKey+".lock.locked_at"); err != nil {
+				return nil, err
+			}
+		}
+		project.Workrooms[name] = entry
+	}
+	return project, nil
+}
+
+// parseTimestamp parses an optional RFC 3339 timestamp at key, returning the zero time when v is
+// empty.
+func parseTimestamp(v, key string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, &ValidationError{Key: key, Msg: fmt.Sprintf("must be an RFC 3339 timestamp, got %q", v)}
+	}
+	return t, nil
+}
+
+func validateSettings(f *File) error {
+	if f.WorkroomsLayout != "" {
+		if err := validateLayout(f.WorkroomsLayout); err != nil {
+			return &ValidationError{Key: "workrooms_layout", Msg: err.Error()}
+		}
+	}
+	return validateShared(f.BranchPrefix, f.NameStyle, f.Env)
+}
+
+var (
+	branchPrefixRe = regexp.MustCompile(`^[A-Za-z0-9._/-]*$`)
+	envNameRe      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// validateShared validates the settings that can be given in both the global and project config.
+func validateShared(branchPrefix, nameStyle string, env map[string]string) error {
+	if !branchPrefixRe.MatchString(branchPrefix) || strings.HasPrefix(branchPrefix, "-") || strings.Contains(branchPrefix, "..") {
+		return &ValidationError{Key: "branch_prefix", Msg: fmt.Sprintf("%q is not a valid branch name prefix", branchPrefix)}
+	}
+	if nameStyle != "" && !slices.Contains(namegen.Styles, nameStyle) {
+		return &ValidationError{Key: "name_style", Msg: fmt.Sprintf("must be one of %s, got %q", strings.Join(namegen.Styles, ", "), nameStyle)}
+	}
+	for name := range env {
+		if !envNameRe.MatchString(name) {
+			return &ValidationError{Key: "env." + name, Msg: "is not a valid environment variable name"}
+		}
+	}
+	return nil
+}
+
+// decodeStrict decodes raw into v, rejecting unknown keys. Errors are reported against key, which
+// is empty for the root of the document.
+func decodeStrict(raw json.RawMessage, v any, key string) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field != "" {
+			key = joinKey(key, typeErr.Field)
+		}
+		return &ValidationError{Key: key, Msg: fmt.Sprintf("expected %s, got %s", jsonType(typeErr.Type.Kind().String()), typeErr.Value)}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		unquoted, _ := strconv.Unquote(field)
+		return &ValidationError{Key: joinKey(key, unquoted), Msg: "unknown key"}
+	}
+	return &ValidationError{Key: cmp.Or(key, "(root)"), Msg: err.Error()}
+}
+
+func joinKey(key, field string) string {
+	if key == "" {
+		return field
+	}
+	return key + "." + field
+}
+
+// jsonType names a Go kind as its JSON type.
+func jsonType(kind string) string {
+	switch kind {
+	case "map", "struct":
+		return "object"
+	case "slice", "array":
+		return "array"
+	case "int", "int64", "float64":
+		return "number"
+	case "bool":
+		return "boolean"
+	}
+	return kind
+}
@@ -0,0 +1,335 @@
+package config
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joelmoss/workroom/internal/errs"
+	"github.com/joelmoss/workroom/internal/namegen"
+)
+
+// DefaultBranchPrefix is prepended to the workroom name to form its Git branch or JJ workspace
+// name.
+const DefaultBranchPrefix = "workroom/"
+
+// ProjectConfigFiles are the names of the repo-level config file, checked into the root of a
+// project. Only one of them may exist.
+var ProjectConfigFiles = []string{".workroom.toml", ".workroom.json"}
+
+// ProjectConfig is the repo-level config shared by everyone working on a project.
+type ProjectConfig struct {
+	Trunk        string            `toml:"trunk,omitempty" json:"trunk,omitempty"`
+	BranchPrefix string            `toml:"branch_prefix,omitempty" json:"branch_prefix,omitempty"`
+	NameStyle    string            `toml:"name_style,omitempty" json:"name_style,omitempty"`
+	Env          map[string]string `toml:"env,omitempty" json:"env,omitempty"`
+	Hooks        Hooks             `toml:"hooks,omitempty" json:"hooks,omitzero"`
+	Copy         []string          `toml:"copy,omitempty" json:"copy,omitempty"`
+	Symlink      []string          `toml:"symlink,omitempty" json:"symlink,omitempty"`
+	Include      []string          `toml:"include,omitempty" json:"include,omitempty"`
+}
+
+// IncludeModes are the ways that the files matched by an include entry are brought into a new
+// workroom. The first is the default.
+var IncludeModes = []string{"copy", "symlink", "hardlink", "reflink"}
+
+var includeModeRe = regexp.MustCompile(`^([a-z]+):(.*)$`)
+
+// ParseInclude splits an include entry, such as "symlink:node_modules", into its mode and its glob
+// pattern. Entries without a mode are copied. A pattern that itself contains a colon after a
+// word, such as "a:b", must be given a mode.
+func ParseInclude(entry string) (mode, pattern string, err error) {
+	m := includeModeRe.FindStringSubmatch(entry)
+	if m == nil {
+		return IncludeModes[0], entry, nil
+	}
+	if !slices.Contains(IncludeModes, m[1]) {
+		return "", "", fmt.Errorf("unknown mode %q. Must be one of: %s", m[1], strings.Join(IncludeModes, ", "))
+	}
+	return m[1], m[2], nil
+}
+
+// Hooks are run at points in a workroom's life. Each is a shell command, which may be the path of
+// a script, or the path of a directory whose scripts are run in lexical order.
+type Hooks struct {
+	Setup      string `toml:"setup,omitempty" json:"setup,omitempty"`
+	Teardown   string `toml:"teardown,omitempty" json:"teardown,omitempty"`
+	Rename     string `toml:"rename,omitempty" json:"rename,omitempty"`
+	PreCreate  string `toml:"pre_create,omitempty" json:"pre_create,omitempty"`
+	PostCreate string `toml:"post_create,omitempty" json:"post_create,omitempty"`
+	PreDelete  string `toml:"pre_delete,omitempty" json:"pre_delete,omitempty"`
+	PostDelete string `toml:"post_delete,omitempty" json:"post_delete,omitempty"`
+	PreRename  string `toml:"pre_rename,omitempty" json:"pre_rename,omitempty"`
+	OnEnter    string `toml:"on_enter,omitempty" json:"on_enter,omitempty"`
+	OnPrune    string `toml:"on_prune,omitempty" json:"on_prune,omitempty"`
+
+	// Timeout is how long each hook may run, such as "10m", keyed by hook name. A hook still
+	// running after its timeout is killed, along with everything it started. Hooks without one
+	// run until they finish.
+	Timeout map[string]string `toml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// Interpreter runs each hook, keyed by hook name. A configured command is passed to it as its
+	// last argument, so "sh -c" is the default and "node -e" runs JavaScript. Scripts are passed
+	// to it as their path, so "bash" runs them with bash, whatever their #! line says.
+	Interpreter map[string]string `toml:"interpreter,omitempty" json:"interpreter,omitempty"`
+}
+
+// HookPoints are the names of the hooks, in the order they run in a workroom's life.
+var HookPoints = []string{
+	"pre_create", "setup", "post_create", "on_enter", "pre_rename", "rename",
+	"pre_delete", "teardown", "post_delete", "on_prune",
+}
+
+// Command returns the configured command of the hook named point, or "" if it has none.
+func (h Hooks) Command(point string) string {
+	switch point {
+	case "setup":
+		return h.Setup
+	case "teardown":
+		return h.Teardown
+	case "rename":
+		return h.Rename
+	case "pre_create":
+		return h.PreCreate
+	case "post_create":
+		return h.PostCreate
+	case "pre_delete":
+		return h.PreDelete
+	case "post_delete":
+		return h.PostDelete
+	case "pre_rename":
+		return h.PreRename
+	case "on_enter":
+		return h.OnEnter
+	case "on_prune":
+		return h.OnPrune
+	}
+	return ""
+}
+
+// TimeoutOf returns how long the hook named point may run, or zero if it has no timeout. The
+// timeouts are validated when the config is read.
+func (h Hooks) TimeoutOf(point string) time.Duration {
+	d, _ := time.ParseDuration(h.Timeout[point])
+	return d
+}
+
+// LoadProjectConfig reads the repo-level config of the project at projectPath. It returns a nil
+// config and an empty path when the project has none.
+func LoadProjectConfig(projectPath string) (*ProjectConfig, string, error) {
+	var found []string
+	for _, name := range ProjectConfigFiles {
+		if _, err := os.Stat(filepath.Join(projectPath, name)); err == nil {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, "", nil
+	case 1:
+	default:
+		return nil, "", fmt.Errorf("%w: found both %s in %s. Keep only one", errs.ErrInvalidConfig, strings.Join(found, " and "), projectPath)
+	}
+
+	path := filepath.Join(projectPath, found[0])
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("read project config %s: %w", path, err)
+	}
+	pc, err := parseProjectConfig(path, data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w %s: %w", errs.ErrInvalidConfig, path, err)
+	}
+	return pc, path, nil
+}
+
+func parseProjectConfig(path string, data []byte) (*ProjectConfig, error) {
+	var pc ProjectConfig
+	if filepath.Ext(path) == ".toml" {
+		md, err := toml.Decode(string(data), &pc)
+		if err != nil {
+			var parseErr toml.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, errors.New(parseErr.ErrorWithPosition())
+			}
+			return nil, err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, &ValidationError{Key: undecoded[0].String(), Msg: "unknown key"}
+		}
+	} else if err := parseProjectJSON(data, &pc); err != nil {
+		return nil, err
+	}
+
+	if err := validateShared(pc.BranchPrefix, pc.NameStyle, pc.Env); err != nil {
+		return nil, err
+	}
+	for point, timeout := range pc.Hooks.Timeout {
+		if err := validateHookPoint("hooks.timeout", point); err != nil {
+			return nil, err
+		}
+		if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+			return nil, &ValidationError{Key: "hooks.timeout." + point, Msg: fmt.Sprintf("%q must be a positive duration, such as 90s or 10m", timeout)}
+		}
+	}
+	for point, interpreter := range pc.Hooks.Interpreter {
+		if err := validateHookPoint("hooks.interpreter", point); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(interpreter) == "" {
+			return nil, &ValidationError{Key: "hooks.interpreter." + point, Msg: "must not be empty"}
+		}
+	}
+	for key, paths := range map[string][]string{"copy": pc.Copy, "symlink": pc.Symlink} {
+		for i, p := range paths {
+			if p == "" || filepath.IsAbs(p) || strings.HasPrefix(filepath.Clean(p), "..") {
+				return nil, &ValidationError{Key: fmt.Sprintf("%s[%d]", key, i), Msg: fmt.Sprintf("%q must be a path relative to the project root", p)}
+			}
+		}
+	}
+	for i, entry := range pc.Include {
+		key := fmt.Sprintf("include[%d]", i)
+		_, pattern, err := ParseInclude(entry)
+		if err != nil {
+			return nil, &ValidationError{Key: key, Msg: err.Error()}
+		}
+		if pattern == "" || filepath.IsAbs(pattern) || strings.HasPrefix(filepath.Clean(pattern), "..") {
+			return nil, &ValidationError{Key: key, Msg: fmt.Sprintf("%q must be a glob pattern relative to the project root", pattern)}
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, &ValidationError{Key: key, Msg: fmt.Sprintf("%q is not a valid glob pattern", pattern)}
+		}
+	}
+	return &pc, nil
+}
+
+// validateHookPoint checks that point, a key of the hook map at key, is the name of a hook.
+func validateHookPoint(key, point string) error {
+	if !slices.Contains(HookPoints, point) {
+		return &ValidationError{Key: key + "." + point, Msg: fmt.Sprintf("unknown hook. Must be one of: %s", strings.Join(HookPoints, ", "))}
+	}
+	return nil
+}
+
+// parseProjectJSON decodes .workroom.json one top-level key at a time, so that errors name the
+// full key.
+func parseProjectJSON(data []byte, pc *ProjectConfig) error {
+	var top map[string]json.RawMessage
+	if err := decodeStrict(data, &top, ""); err != nil {
+		return err
+	}
+	for key, raw := range top {
+		var err error
+		switch key {
+		case "trunk":
+			err = decodeStrict(raw, &pc.Trunk, key)
+		case "branch_prefix":
+			err = decodeStrict(raw, &pc.BranchPrefix, key)
+		case "name_style":
+			err = decodeStrict(raw, &pc.NameStyle, key)
+		case "env":
+			err = decodeStrict(raw, &pc.Env, key)
+		case "hooks":
+			err = decodeStrict(raw, &pc.Hooks, key)
+		case "copy":
+			err = decodeStrict(raw, &pc.Copy, key)
+		case "symlink":
+			err = decodeStrict(raw, &pc.Symlink, key)
+		case "include":
+			err = decodeStrict(raw, &pc.Include, key)
+		default:
+			err = &ValidationError{Key: key, Msg: "unknown key"}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolved is the effective configuration for a project, after merging the built-in defaults,
+// the global config, the project's repo-level config and WORKROOM_<KEY> environment variables,
+// in increasing order of precedence. Env is merged variable by variable.
+type Resolved struct {
+	WorkroomsDir    string            `json:"workrooms_dir"`
+	WorkroomsLayout string            `json:"workrooms_layout"`
+	Trunk           string            `json:"trunk"`
+	BranchPrefix    string            `json:"branch_prefix"`
+	NameStyle       string            `json:"name_style"`
+	Env             map[string]string `json:"env"`
+	Hooks           Hooks             `json:"hooks"`
+	Copy            []string          `json:"copy"`
+	Symlink         []string          `json:"symlink"`
+	Include         []string          `json:"include"`
+
+	// Sources are the config files that were merged, lowest precedence first, followed by any
+	// overriding environment variables.
+	Sources []string `json:"sources"`
+}
+
+// Resolve returns the effective configuration for the project at projectPath. Settings
+// overridden by WORKROOM_<KEY> environment variables take precedence over both config files.
+func (c *Config) Resolve(projectPath string) (*Resolved, error) {
+	f, overrides, err := c.effective()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := c.WorkroomsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Resolved{
+		WorkroomsDir:    dir,
+		WorkroomsLayout: c.WorkroomsLayout(),
+		Trunk:           f.Trunk,
+		BranchPrefix:    cmp.Or(f.BranchPrefix, DefaultBranchPrefix),
+		NameStyle:       cmp.Or(f.NameStyle, namegen.StyleFriendly),
+		Env:             maps.Clone(f.Env),
+		Copy:            []string{},
+		Symlink:         []string{},
+		Include:         []string{},
+		Sources:         []string{},
+	}
+	if r.Env == nil {
+		r.Env = map[string]string{}
+	}
+	if _, err := os.Stat(c.path); err == nil {
+		r.Sources = append(r.Sources, c.path)
+	}
+
+	pc, path, err := LoadProjectConfig(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if pc != nil {
+		r.Trunk = cmp.Or(pc.Trunk, r.Trunk)
+		r.BranchPrefix = cmp.Or(pc.BranchPrefix, r.BranchPrefix)
+		r.NameStyle = cmp.Or(pc.NameStyle, r.NameStyle)
+		maps.Copy(r.Env, pc.Env)
+		r.Hooks = pc.Hooks
+		r.Copy = append(r.Copy, pc.Copy...)
+		r.Symlink = append(r.Symlink, pc.Symlink...)
+		r.Include = append(r.Include, pc.Include...)
+		r.Sources = append(r.Sources, path)
+	}
+
+	r.Trunk = cmp.Or(overrides["trunk"], r.Trunk)
+	r.BranchPrefix = cmp.Or(overrides["branch_prefix"], r.BranchPrefix)
+	r.NameStyle = cmp.Or(overrides["name_style"], r.NameStyle)
+	for _, key := range envSettings {
+		if _, ok := overrides[key]; ok {
+			r.Sources = append(r.Sources, "$"+EnvVar(key))
+		}
+	}
+	return r, nil
+}
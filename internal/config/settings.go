@@ -0,0 +1,339 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joelmoss/workroom/internal/errs"
+)
+
+// Scope is a config file that settings are read from and written to.
+type Scope string
+
+const (
+	// ScopeGlobal is the user's global config file.
+	ScopeGlobal Scope = "global"
+	// ScopeProject is the repo-level .workroom.toml or .workroom.json of a project.
+	ScopeProject Scope = "project"
+)
+
+// Setting is a key that can be read and written with `workroom config`.
+type Setting struct {
+	// Key is the dotted key. A key ending in ".*" stands for a map, such as env.RAILS_ENV.
+	Key    string
+	List   bool
+	Scopes []Scope
+	Desc   string
+}
+
+var bothScopes = []Scope{ScopeGlobal, ScopeProject}
+
+// Settings are the keys that can be read and written with `workroom config`.
+var Settings = []Setting{
+	{Key: "workrooms_dir", Scopes: []Scope{ScopeGlobal}, Desc: "Directory that workrooms are created in"},
+	{Key: "workrooms_layout", Scopes: []Scope{ScopeGlobal}, Desc: "Path template for new workrooms"},
+	{Key: "trunk", Scopes: bothScopes, Desc: "Branch that workrooms are checked against for merges"},
+	{Key: "branch_prefix", Scopes: bothScopes, Desc: "Prefix of workroom branch and workspace names"},
+	{Key: "name_style", Scopes: bothScopes, Desc: "Style of generated workroom names"},
+	{Key: "env.*", Scopes: bothScopes, Desc: "Environment variables for scripts and hooks"},
+	{Key: "hooks.pre_create", Scopes: []Scope{ScopeProject}, Desc: "Command run before a workroom is created"},
+	{Key: "hooks.setup", Scopes: []Scope{ScopeProject}, Desc: "Command run after a workroom is created"},
+	{Key: "hooks.post_create", Scopes: []Scope{ScopeProject}, Desc: "Command run after a workroom is set up"},
+	{Key: "hooks.pre_delete", Scopes: []Scope{ScopeProject}, Desc: "Command run before a workroom is deleted"},
+	{Key: "hooks.teardown", Scopes: []Scope{ScopeProject}, Desc: "Command run before a workroom is deleted, after pre_delete"},
+	{Key: "hooks.post_delete", Scopes: []Scope{ScopeProject}, Desc: "Command run after a workroom is deleted"},
+	{Key: "hooks.pre_rename", Scopes: []Scope{ScopeProject}, Desc: "Command run before a workroom is renamed"},
+	{Key: "hooks.rename", Scopes: []Scope{ScopeProject}, Desc: "Command run after a workroom is renamed"},
+	{Key: "hooks.on_enter", Scopes: []Scope{ScopeProject}, Desc: "Command run when changing into a workroom"},
+	{Key: "hooks.on_prune", Scopes: []Scope{ScopeProject}, Desc: "Command run after workrooms are pruned"},
+	{Key: "hooks.timeout.*", Scopes: []Scope{ScopeProject}, Desc: "How long a hook may run, such as 10m, before it is killed"},
+	{Key: "hooks.interpreter.*", Scopes: []Scope{ScopeProject}, Desc: "Interpreter that runs a hook, such as bash or sh -c"},
+	{Key: "copy", List: true, Scopes: []Scope{ScopeProject}, Desc: "Files copied into new workrooms"},
+	{Key: "symlink", List: true, Scopes: []Scope{ScopeProject}, Desc: "Files symlinked into new workrooms"},
+	{Key: "include", List: true, Scopes: []Scope{ScopeProject}, Desc: "Glob patterns of files brought into new workrooms, as [MODE:]PATTERN"},
+}
+
+// LookupSetting returns the setting for a dotted key, checking that it can be used in scope. An
+// empty scope accepts a key from any scope.
+func LookupSetting(key string, scope Scope) (*Setting, error) {
+	for i, s := range Settings {
+		if !s.matches(key) {
+			continue
+		}
+		if scope != "" && !slices.Contains(s.Scopes, scope) {
+			return nil, fmt.Errorf("%w: %s is not a %s setting", errs.ErrInvalidConfig, key, scope)
+		}
+		return &Settings[i], nil
+	}
+
+	var keys []string
+	for _, s := range Settings {
+		keys = append(keys, s.Key)
+	}
+	return nil, fmt.Errorf("%w: unknown key %q. Valid keys are %s", errs.ErrInvalidConfig, key, strings.Join(keys, ", "))
+}
+
+func (s Setting) matches(key string) bool {
+	if prefix, ok := strings.CutSuffix(s.Key, "*"); ok {
+		name, found := strings.CutPrefix(key, prefix)
+		return found && name != "" && !strings.Contains(name, ".")
+	}
+	return key == s.Key
+}
+
+// ProjectConfigPath returns the path of the repo-level config file of the project at
+// projectPath, or the .workroom.toml it would be created at.
+func ProjectConfigPath(projectPath string) string {
+	for _, name := range ProjectConfigFiles {
+		path := filepath.Join(projectPath, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(projectPath, ProjectConfigFiles[0])
+}
+
+// ScopePath returns the path of the config file for scope.
+func (c *Config) ScopePath(scope Scope, projectPath string) string {
+	if scope == ScopeProject {
+		return ProjectConfigPath(projectPath)
+	}
+	return c.path
+}
+
+// Values returns the settings in the config file for scope, keyed by dotted key. With an empty
+// scope, it returns the resolved settings of the project at projectPath. Values are strings, or
+// string slices for list settings; unset settings are left out.
+func (c *Config) Values(scope Scope, projectPath string) (map[string]any, error) {
+	var v any
+	switch scope {
+	case ScopeGlobal:
+		f, err := c.Read()
+		if err != nil {
+			return nil, err
+		}
+		v = settingsOf(f)
+	case ScopeProject:
+		pc, _, err := LoadProjectConfig(projectPath)
+		if err != nil {
+			return nil, err
+		}
+		if pc == nil {
+			pc = &ProjectConfig{}
+		}
+		v = pc
+	default:
+		r, err := c.Resolve(projectPath)
+		if err != nil {
+			return nil, err
+		}
+		r.Sources = nil
+		v = r
+	}
+
+	m, err := toMap(v)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{}
+	flatten("", m, values)
+	return values, nil
+}
+
+// SetValue sets a dotted key in the config file for scope. List settings take any number of
+// values; others take exactly one. The changed file is validated before it is written.
+func (c *Config) SetValue(scope Scope, projectPath, key string, values []string) error {
+	setting, err := LookupSetting(key, scope)
+	if err != nil {
+		return err
+	}
+	var value any
+	if setting.List {
+		value = values
+	} else if len(values) != 1 {
+		return fmt.Errorf("%w: %s takes a single value", errs.ErrInvalidConfig, key)
+	} else {
+		value = values[0]
+	}
+
+	return c.editValues(scope, projectPath, func(m map[string]any) {
+		setPath(m, strings.Split(key, "."), value)
+	})
+}
+
+// UnsetValue removes a dotted key from the config file for scope.
+func (c *Config) UnsetValue(scope Scope, projectPath, key string) error {
+	if _, err := LookupSetting(key, scope); err != nil {
+		return err
+	}
+	return c.editValues(scope, projectPath, func(m map[string]any) {
+		deletePath(m, strings.Split(key, "."))
+	})
+}
+
+// editValues applies fn to the config file for scope as a generic map, and writes it back once
+// the result passes the same validation as when the file is read.
+func (c *Config) editValues(scope Scope, projectPath string, fn func(m map[string]any)) error {
+	if scope == ScopeProject {
+		pc, path, err := LoadProjectConfig(projectPath)
+		if err != nil {
+			return err
+		}
+		if pc == nil {
+			pc, path = &ProjectConfig{}, ProjectConfigPath(projectPath)
+		}
+		m, err := toMap(pc)
+		if err != nil {
+			return err
+		}
+		fn(m)
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if pc, err = parseProjectConfig(".workroom.json", data); err != nil {
+			return fmt.Errorf("%w %s: %w", errs.ErrInvalidConfig, path, err)
+		}
+		if data, err = marshalProjectConfig(path, pc); err != nil {
+			return err
+		}
+		if err := writeAtomic(path, data); err != nil {
+			return fmt.Errorf("write project config %s: %w", path, err)
+		}
+		return nil
+	}
+
+	return c.update(func(f *File) error {
+		m, err := toMap(f)
+		if err != nil {
+			return err
+		}
+		fn(m)
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		updated, err := parseFile(data)
+		if err != nil {
+			return fmt.Errorf("%w %s: %w", errs.ErrInvalidConfig, c.path, err)
+		}
+		*f = *updated
+		return nil
+	})
+}
+
+// WriteScope validates data as the config file for scope, and replaces the file with it. It is
+// used to save a config file edited by hand, keeping its formatting.
+func (c *Config) WriteScope(scope Scope, projectPath string, data []byte) error {
+	if scope == ScopeProject {
+		path := ProjectConfigPath(projectPath)
+		if _, err := parseProjectConfig(path, data); err != nil {
+			return fmt.Errorf("%w %s: %w", errs.ErrInvalidConfig, path, err)
+		}
+		return writeAtomic(path, data)
+	}
+
+	if _, err := parseFile(data); err != nil {
+		return fmt.Errorf("%w %s: %w", errs.ErrInvalidConfig, c.path, err)
+	}
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := writeAtomic(c.path, data); err != nil {
+		return fmt.Errorf("write config %s: %w", c.path, err)
+	}
+	return writeAtomic(c.backupPath(), data)
+}
+
+// settingsOf returns f without its projects, which are managed by workroom itself.
+func settingsOf(f *File) *File {
+	settings := *f
+	settings.Projects = nil
+	return &settings
+}
+
+func marshalProjectConfig(path string, pc *ProjectConfig) ([]byte, error) {
+	if filepath.Ext(path) == ".json" {
+		data, err := json.MarshalIndent(pc, "", "  ")
+		return append(data, '\n'), err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(pc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func toMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{}
+	return m, json.Unmarshal(data, &m)
+}
+
+// flatten copies the settings in m into values under their dotted keys, skipping empty values and
+// the schema version.
+func flatten(prefix string, m map[string]any, values map[string]any) {
+	for k, v := range m {
+		key := joinKey(prefix, k)
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(key, v, values)
+		case []any:
+			if len(v) == 0 {
+				continue
+			}
+			list := make([]string, len(v))
+			for i, item := range v {
+				list[i] = fmt.Sprint(item)
+			}
+			values[key] = list
+		case string:
+			if v != "" {
+				values[key] = v
+			}
+		case nil:
+		default:
+			if key != "schema_version" {
+				values[key] = fmt.Sprint(v)
+			}
+		}
+	}
+}
+
+func setPath(m map[string]any, path []string, value any) {
+	for _, k := range path[:len(path)-1] {
+		child, ok := m[k].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			m[k] = child
+		}
+		m = child
+	}
+	m[path[len(path)-1]] = value
+}
+
+func deletePath(m map[string]any, path []string) {
+	if len(path) == 1 {
+		delete(m, path[0])
+		return
+	}
+	child, ok := m[path[0]].(map[string]any)
+	if !ok {
+		return
+	}
+	deletePath(child, path[1:])
+	if len(child) == 0 {
+		delete(m, path[0])
+	}
+}
@@ -1,6 +1,9 @@
 package errs
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrInWorkroom          = errors.New("looks like you are already in a workroom. Run this command from the root of your main development directory, not from within an existing workroom")
@@ -11,6 +14,34 @@ var (
 	ErrGitWorktreeExists   = errors.New("Git worktree already exists")
 	ErrJJWorkspaceNotFound = errors.New("JJ workspace does not exist")
 	ErrGitWorktreeNotFound = errors.New("Git worktree does not exist")
+	ErrFromAndBase         = errors.New("--from and --base cannot be used together")
+	ErrBranchNotMerged     = errors.New("branch is not merged into trunk")
+	ErrUnsavedChanges      = errors.New("workroom has uncommitted or unpushed work")
+	ErrWorkroomNotFound    = errors.New("workroom not found")
+	ErrWorkroomLocked      = errors.New("workroom is locked")
+	ErrInvalidConfig       = errors.New("invalid config")
 	ErrSetup               = errors.New("setup script failed")
 	ErrTeardown            = errors.New("teardown script failed")
+	ErrHook                = errors.New("hook failed")
+	ErrHookTimeout         = errors.New("hook timed out")
+	ErrInterrupted         = errors.New("interrupted")
 )
+
+// ExitError asks for the process to exit with Code, after Err has been reported.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// NewExitError returns an ExitError with the given code and formatted message.
+func NewExitError(code int, format string, args ...any) *ExitError {
+	return &ExitError{Code: code, Err: fmt.Errorf(format, args...)}
+}
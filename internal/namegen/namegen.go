@@ -3,6 +3,7 @@ package namegen
 import (
 	"fmt"
 	"math/rand/v2"
+	"time"
 )
 
 var adjectives = []string{
@@ -49,3 +50,27 @@ func Generate() string {
 	noun := nouns[rand.IntN(len(nouns))]
 	return fmt.Sprintf("%s-%s", adj, noun)
 }
+
+// Name generation styles.
+const (
+	StyleFriendly  = "friendly"  // adjective-noun, e.g. swift-meadow
+	StyleShort     = "short"     // a single noun, e.g. meadow
+	StyleTimestamp = "timestamp" // creation time, e.g. 20260301-101500
+)
+
+// Styles are the supported name generation styles.
+var Styles = []string{StyleFriendly, StyleShort, StyleTimestamp}
+
+// GenerateStyle generates a name in the given style. An empty style means StyleFriendly.
+func GenerateStyle(style string) (string, error) {
+	switch style {
+	case "", StyleFriendly:
+		return Generate(), nil
+	case StyleShort:
+		return nouns[rand.IntN(len(nouns))], nil
+	case StyleTimestamp:
+		return time.Now().Format("20060102-150405"), nil
+	default:
+		return "", fmt.Errorf("unknown name style %q", style)
+	}
+}
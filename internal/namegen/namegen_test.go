@@ -66,3 +66,31 @@ func TestGenerate(t *testing.T) {
 		}
 	})
 }
+
+func TestGenerateStyle(t *testing.T) {
+	t.Run("short is a single noun", func(t *testing.T) {
+		name, err := GenerateStyle(StyleShort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(name, "-") {
+			t.Fatalf("expected a single noun, got %q", name)
+		}
+	})
+
+	t.Run("timestamp is the current time", func(t *testing.T) {
+		name, err := GenerateStyle(StyleTimestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(name) != len("20060102-150405") {
+			t.Fatalf("expected timestamp, got %q", name)
+		}
+	})
+
+	t.Run("unknown style", func(t *testing.T) {
+		if _, err := GenerateStyle("emoji"); err == nil {
+			t.Fatal("expected error for unknown style")
+		}
+	})
+}
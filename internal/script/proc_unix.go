@@ -0,0 +1,24 @@
+//go:build !windows
+
+package script
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes cmd run in a process group of its own, so that it can be stopped along
+// with everything it starts.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateProcessGroup asks cmd and everything it started to stop, with SIGTERM.
+func terminateProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// killProcessGroup kills cmd and everything it started.
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
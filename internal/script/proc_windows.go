@@ -0,0 +1,18 @@
+//go:build windows
+
+package script
+
+import "os/exec"
+
+// setProcessGroup does nothing on Windows, where only the script itself can be stopped.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup kills cmd, as Windows has no signal that asks it to stop.
+func terminateProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// killProcessGroup kills cmd.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
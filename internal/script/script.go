@@ -1,39 +1,243 @@
 package script
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/joelmoss/workroom/internal/errs"
 )
 
-// Run executes a user script in the given workroom directory with environment variables set.
-// Returns the combined stdout+stderr output and any error.
-func Run(scriptType string, scriptPath, workroomDir, name, parentDir string) (string, error) {
-	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
+// waitDelay is how long a script's output is waited for once it has exited or been killed, in
+// case something it started in the background still holds on to it.
+const waitDelay = time.Second
+
+// killGrace is how long a script that is stopped, and everything it started, are given to exit
+// after SIGTERM before they are killed.
+var killGrace = 5 * time.Second
+
+// Run executes a user script in the given workroom directory with env as its environment. Its
+// output is streamed to out as it runs, unless out is nil. When ctx is done, the script and
+// everything it started are stopped. Returns the combined stdout+stderr output and any error.
+//
+// The script is run by interpreter, such as "bash", when one is given. Otherwise it is executed
+// directly, or if it is not executable, by the interpreter named on its #! line.
+func Run(ctx context.Context, scriptType string, scriptPath, interpreter, workroomDir string, env []string, out io.Writer) (string, error) {
+	info, err := os.Stat(scriptPath)
+	if os.IsNotExist(err) {
 		return "", nil
 	}
+	if err != nil {
+		return "", err
+	}
+
+	var args []string
+	switch {
+	case interpreter != "":
+		args = append(fileInterpreter(interpreter), scriptPath)
+	case info.Mode()&0o111 != 0:
+		args = []string{scriptPath}
+	default:
+		shebang, err := readShebang(scriptPath)
+		if err != nil {
+			return "", err
+		}
+		if shebang == nil {
+			return "", fmt.Errorf("%w: %s is not executable, and has no #! line naming its interpreter. Make it executable with `chmod +x %s`, or set hooks.interpreter.%s in the project config", scriptError(scriptType), scriptPath, scriptPath, scriptType)
+		}
+		args = append(shebang, scriptPath)
+	}
+	return run(ctx, scriptType, scriptPath, exec.Command(args[0], args[1:]...), workroomDir, env, out)
+}
+
+// fileInterpreter returns the fields of interpreter to run a script file with. A trailing -c, as
+// in "sh -c", would make the shell run the script's path as a command, which fails just as
+// executing it does, so it is dropped for the shell to read the script instead: "sh -c" runs
+// "sh script" and "bash -ec" runs "bash -e script".
+func fileInterpreter(interpreter string) []string {
+	args := strings.Fields(interpreter)
+	if n := len(args); n > 1 && shellCommandFlagRe.MatchString(args[n-1]) {
+		if flags := strings.TrimSuffix(args[n-1], "c"); flags != "-" {
+			args[n-1] = flags
+		} else {
+			args = args[:n-1]
+		}
+	}
+	return args
+}
 
-	cmd := exec.Command(scriptPath)
+var shellCommandFlagRe = regexp.MustCompile(`^-[a-zA-Z]*c$`)
+
+// readShebang returns the interpreter and optional argument on the #! line of the script at path,
+// or nil if it has none.
+func readShebang(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	line, ok := strings.CutPrefix(strings.TrimSpace(line), "#!")
+	if !ok {
+		return nil, nil
+	}
+	// Like the kernel, everything after the interpreter is a single argument.
+	interp, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if interp == "" {
+		return nil, nil
+	}
+	if arg = strings.TrimSpace(arg); arg != "" {
+		return []string{interp, arg}, nil
+	}
+	return []string{interp}, nil
+}
+
+// RunDir executes every script in scriptDir in lexical order, like Run, stopping at the first
+// that fails. Hidden files and subdirectories are skipped. Returns the combined output of the
+// scripts that ran, and any error.
+func RunDir(ctx context.Context, scriptType string, scriptDir, interpreter, workroomDir string, env []string, out io.Writer) (string, error) {
+	entries, err := os.ReadDir(scriptDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	var output strings.Builder
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		scriptOutput, err := Run(ctx, scriptType, filepath.Join(scriptDir, e.Name()), interpreter, workroomDir, env, out)
+		output.WriteString(scriptOutput)
+		if err != nil {
+			return output.String(), err
+		}
+	}
+	return output.String(), nil
+}
+
+// RunCommand executes an inline command, such as a hook from the project config, in the given
+// workroom directory with env as its environment, streaming its output to out like Run. The
+// command is passed as the last argument of interpreter, which defaults to "sh -c". Returns the
+// combined stdout+stderr output and any error.
+func RunCommand(ctx context.Context, scriptType string, command, interpreter, workroomDir string, env []string, out io.Writer) (string, error) {
+	args := strings.Fields(interpreter)
+	if len(args) == 0 {
+		args = []string{"sh", "-c"}
+	}
+	args = append(args, command)
+	return run(ctx, scriptType, command, exec.Command(args[0], args[1:]...), workroomDir, env, out)
+}
+
+func run(ctx context.Context, scriptType, desc string, cmd *exec.Cmd, workroomDir string, env []string, out io.Writer) (string, error) {
 	cmd.Dir = workroomDir
-	cmd.Env = append(os.Environ(),
-		"WORKROOM_NAME="+name,
-		"WORKROOM_PARENT_DIR="+parentDir,
-	)
+	cmd.Env = env
+	cmd.WaitDelay = waitDelay
+	setProcessGroup(cmd)
 
-	out, err := cmd.CombinedOutput()
-	output := string(out)
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	if out != nil {
+		w = io.MultiWriter(&buf, out)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	err := cmd.Start()
+	if err == nil {
+		waited := stopWhenDone(ctx, cmd)
+		err = cmd.Wait()
+		waited()
+	}
+	output := buf.String()
+	// A script that exited successfully is not failed by what it left running in the background.
+	if errors.Is(err, exec.ErrWaitDelay) && ctx.Err() == nil {
+		err = nil
+	}
 
 	if err != nil {
-		var sentinel error
-		if scriptType == "setup" {
-			sentinel = errs.ErrSetup
-		} else {
-			sentinel = errs.ErrTeardown
+		sentinel := scriptError(scriptType)
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return output, fmt.Errorf("%w: cannot run %s: %s was not found. Check its #! line or hooks.interpreter.%s", sentinel, desc, cmd.Args[0], scriptType)
+		}
+		if ctx.Err() != nil {
+			return output, fmt.Errorf("%w: %s was stopped: %w", sentinel, desc, context.Cause(ctx))
+		}
+		// Output that was streamed has been seen already.
+		if out != nil {
+			return output, fmt.Errorf("%w: %s returned a non-zero exit code.", sentinel, desc)
 		}
-		return output, fmt.Errorf("%w: %s returned a non-zero exit code.\n%s", sentinel, scriptPath, output)
+		return output, fmt.Errorf("%w: %s returned a non-zero exit code.\n%s", sentinel, desc, output)
 	}
 
 	return output, nil
 }
+
+// stopWhenDone stops the started cmd, and everything it started, when ctx is done: they are sent
+// SIGTERM, and killed if cmd has not exited after killGrace. The returned func must be called once
+// cmd has been waited for. It kills whatever a stopped cmd left behind.
+func stopWhenDone(ctx context.Context, cmd *exec.Cmd) (waited func()) {
+	exited, grace := make(chan struct{}), killGrace
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-ctx.Done():
+		}
+		terminateProcessGroup(cmd)
+		select {
+		case <-exited:
+		case <-time.After(grace):
+			killProcessGroup(cmd)
+		}
+	}()
+	return func() {
+		close(exited)
+		if ctx.Err() != nil {
+			killProcessGroup(cmd)
+		}
+	}
+}
+
+// scriptError returns the error that the errors of a failed script of scriptType wrap.
+func scriptError(scriptType string) error {
+	switch scriptType {
+	case "setup":
+		return errs.ErrSetup
+	case "teardown":
+		return errs.ErrTeardown
+	}
+	return errs.ErrHook
+}
+
+// Env returns the current environment with the variables that describe a workroom added, followed
+// by extra, which may override them. Every command workroom runs inside a workroom gets this
+// environment.
+func Env(name, parentDir string, extra map[string]string) []string {
+	env := append(os.Environ(),
+		"WORKROOM_NAME="+name,
+		"WORKROOM_PARENT_DIR="+parentDir,
+	)
+	for _, k := range slices.Sorted(maps.Keys(extra)) {
+		env = append(env, k+"="+extra[k])
+	}
+	return env
+}
@@ -1,11 +1,13 @@
 package script
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/joelmoss/workroom/internal/errs"
 )
@@ -19,7 +21,7 @@ func TestRunSetupSuccess(t *testing.T) {
 	dir := t.TempDir()
 	scriptPath := filepath.Join(fixturesDir(), "setup")
 
-	output, err := Run("setup", scriptPath, dir, "test-workroom", "/parent")
+	output, err := Run(context.Background(), "setup", scriptPath, "", dir, Env("test-workroom", "/parent", nil), nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -32,7 +34,7 @@ func TestRunSetupFailure(t *testing.T) {
 	dir := t.TempDir()
 	scriptPath := filepath.Join(fixturesDir(), "failed_setup")
 
-	output, err := Run("setup", scriptPath, dir, "test-workroom", "/parent")
+	output, err := Run(context.Background(), "setup", scriptPath, "", dir, Env("test-workroom", "/parent", nil), nil)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -48,7 +50,7 @@ func TestRunTeardownSuccess(t *testing.T) {
 	dir := t.TempDir()
 	scriptPath := filepath.Join(fixturesDir(), "teardown")
 
-	output, err := Run("teardown", scriptPath, dir, "test-workroom", "/parent")
+	output, err := Run(context.Background(), "teardown", scriptPath, "", dir, Env("test-workroom", "/parent", nil), nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -61,7 +63,7 @@ func TestRunTeardownFailure(t *testing.T) {
 	dir := t.TempDir()
 	scriptPath := filepath.Join(fixturesDir(), "failed_teardown")
 
-	output, err := Run("teardown", scriptPath, dir, "test-workroom", "/parent")
+	output, err := Run(context.Background(), "teardown", scriptPath, "", dir, Env("test-workroom", "/parent", nil), nil)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -77,7 +79,7 @@ func TestRunMissingScript(t *testing.T) {
 	dir := t.TempDir()
 	scriptPath := filepath.Join(dir, "nonexistent")
 
-	output, err := Run("setup", scriptPath, dir, "test-workroom", "/parent")
+	output, err := Run(context.Background(), "setup", scriptPath, "", dir, Env("test-workroom", "/parent", nil), nil)
 	if err != nil {
 		t.Fatalf("expected no error for missing script, got %v", err)
 	}
@@ -91,7 +93,7 @@ func TestRunSetsEnvVars(t *testing.T) {
 	scriptPath := filepath.Join(dir, "env_check")
 	os.WriteFile(scriptPath, []byte("#!/usr/bin/env bash\necho \"NAME=$WORKROOM_NAME\"\necho \"PARENT=$WORKROOM_PARENT_DIR\"\n"), 0o755)
 
-	output, err := Run("setup", scriptPath, dir, "my-workroom", "/parent/dir")
+	output, err := Run(context.Background(), "setup", scriptPath, "", dir, Env("my-workroom", "/parent/dir", nil), nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -102,3 +104,201 @@ func TestRunSetsEnvVars(t *testing.T) {
 		t.Fatalf("expected WORKROOM_PARENT_DIR in output, got %q", output)
 	}
 }
+
+func TestRunFallsBackToShebang(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "setup")
+	os.WriteFile(scriptPath, []byte("#!/bin/sh -e\necho \"shebang $WORKROOM_NAME\"\n"), 0o644)
+
+	output, err := Run(context.Background(), "setup", scriptPath, "", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "shebang my-workroom\n" {
+		t.Fatalf("expected the script to run by its #! interpreter, got %q", output)
+	}
+}
+
+func TestRunNonExecutableWithoutShebang(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "teardown")
+	os.WriteFile(scriptPath, []byte("echo hello\n"), 0o644)
+
+	_, err := Run(context.Background(), "teardown", scriptPath, "", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrTeardown) {
+		t.Fatalf("expected ErrTeardown, got %v", err)
+	}
+	for _, want := range []string{"is not executable", "chmod +x " + scriptPath, "hooks.interpreter.teardown"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestRunWithInterpreter(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "setup")
+	os.WriteFile(scriptPath, []byte("echo \"args: $0\"\n"), 0o644)
+
+	output, err := Run(context.Background(), "setup", scriptPath, "sh", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "args: "+scriptPath+"\n" {
+		t.Fatalf("expected the script to run by the interpreter, got %q", output)
+	}
+
+	_, err = Run(context.Background(), "setup", scriptPath, "no-such-interpreter", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrSetup) || !strings.Contains(err.Error(), "no-such-interpreter was not found") {
+		t.Fatalf("expected a missing interpreter error, got %v", err)
+	}
+}
+
+func TestRunWithCommandFlagInterpreter(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "setup")
+	os.WriteFile(scriptPath, []byte("echo \"ran $WORKROOM_NAME\"\n"), 0o644)
+
+	output, err := Run(context.Background(), "setup", scriptPath, "sh -c", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "ran my-workroom\n" {
+		t.Fatalf("expected sh -c to run the script file, got %q", output)
+	}
+
+	os.WriteFile(scriptPath, []byte("false\necho unreachable\n"), 0o644)
+	output, err = Run(context.Background(), "setup", scriptPath, "sh -ec", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrSetup) || strings.Contains(output, "unreachable") {
+		t.Fatalf("expected the script to run by sh -e, got %v and %q", err, output)
+	}
+}
+
+func TestRunCommandWithInterpreter(t *testing.T) {
+	output, err := RunCommand(context.Background(), "setup", "false; echo unreachable", "sh -ec", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrSetup) || strings.Contains(output, "unreachable") {
+		t.Fatalf("expected the command to run by sh -e, got %v and %q", err, output)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	output, err := RunCommand(context.Background(), "setup", `echo "$WORKROOM_NAME $GREETING" && pwd`, "", dir, Env("my-workroom", "/parent", map[string]string{"GREETING": "hello"}), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "my-workroom hello") {
+		t.Fatalf("expected env in output, got %q", output)
+	}
+	if !strings.Contains(output, filepath.Base(dir)) {
+		t.Fatalf("expected command to run in workroom, got %q", output)
+	}
+}
+
+func TestRunCommandFailure(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := RunCommand(context.Background(), "teardown", "exit 2", "", dir, Env("my-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrTeardown) {
+		t.Fatalf("expected ErrTeardown, got %v", err)
+	}
+}
+
+func TestRunCommandStreamsOutput(t *testing.T) {
+	var streamed strings.Builder
+	output, err := RunCommand(context.Background(), "setup", "echo out && echo err >&2 && exit 1", "", t.TempDir(), Env("my-workroom", "/parent", nil), &streamed)
+	if !errors.Is(err, errs.ErrSetup) {
+		t.Fatalf("expected ErrSetup, got %v", err)
+	}
+	if streamed.String() != "out\nerr\n" || output != streamed.String() {
+		t.Fatalf("expected stdout and stderr to be streamed and captured, got %q and %q", streamed.String(), output)
+	}
+	if strings.Contains(err.Error(), "\n") {
+		t.Fatalf("expected streamed output not to be repeated in the error, got %v", err)
+	}
+}
+
+func TestRunCommandKillsProcessGroupWhenCancelled(t *testing.T) {
+	dir := t.TempDir()
+	cause := errors.New("too slow")
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 200*time.Millisecond, cause)
+	defer cancel()
+
+	start := time.Now()
+	_, err := RunCommand(ctx, "setup", "(sleep 0.5; touch child-survived) & sleep 5", "", dir, Env("my-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrSetup) || !errors.Is(err, cause) {
+		t.Fatalf("expected ErrSetup caused by the timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected the script to be killed, but it ran for %s", elapsed)
+	}
+	time.Sleep(time.Second)
+	if _, err := os.Stat(filepath.Join(dir, "child-survived")); err == nil {
+		t.Fatal("expected the script's children to be killed")
+	}
+}
+
+func TestRunCommandLetsStoppedScriptCleanUp(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err := RunCommand(ctx, "setup", "trap 'touch cleaned-up; exit 1' TERM; sleep 5 & wait", "", dir, Env("my-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrSetup) {
+		t.Fatalf("expected ErrSetup, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cleaned-up")); err != nil {
+		t.Fatal("expected the script to be sent SIGTERM before it is killed")
+	}
+}
+
+func TestRunCommandKillsScriptThatIgnoresSIGTERM(t *testing.T) {
+	grace := killGrace
+	killGrace = 300 * time.Millisecond
+	t.Cleanup(func() { killGrace = grace })
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := RunCommand(ctx, "setup", "trap '' TERM; sleep 5", "", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrSetup) {
+		t.Fatalf("expected ErrSetup, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("expected the script to be killed after the grace period, but it ran for %s", elapsed)
+	}
+}
+
+func TestRunCommandIgnoresBackgroundedProcesses(t *testing.T) {
+	_, err := RunCommand(context.Background(), "setup", "sleep 5 & echo started", "", t.TempDir(), Env("my-workroom", "/parent", nil), nil)
+	if err != nil {
+		t.Fatalf("expected a script that backgrounds a process to succeed, got %v", err)
+	}
+}
+
+func TestRunDirRunsScriptsInOrder(t *testing.T) {
+	scriptDir := t.TempDir()
+	os.WriteFile(filepath.Join(scriptDir, "20-second"), []byte("#!/bin/sh\necho second\n"), 0o755)
+	os.WriteFile(filepath.Join(scriptDir, "10-first"), []byte("#!/bin/sh\necho first\n"), 0o755)
+	os.WriteFile(filepath.Join(scriptDir, ".hidden"), []byte("#!/bin/sh\necho hidden\n"), 0o755)
+	os.Mkdir(filepath.Join(scriptDir, "lib"), 0o755)
+
+	output, err := RunDir(context.Background(), "setup", scriptDir, "", t.TempDir(), Env("test-workroom", "/parent", nil), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "first\nsecond\n" {
+		t.Fatalf("expected scripts to run in lexical order, got %q", output)
+	}
+}
+
+func TestRunDirStopsAtFailure(t *testing.T) {
+	scriptDir := t.TempDir()
+	os.WriteFile(filepath.Join(scriptDir, "10-fail"), []byte("#!/bin/sh\necho failing\nexit 1\n"), 0o755)
+	os.WriteFile(filepath.Join(scriptDir, "20-never"), []byte("#!/bin/sh\necho never\n"), 0o755)
+
+	output, err := RunDir(context.Background(), "pre_create", scriptDir, "", t.TempDir(), Env("test-workroom", "/parent", nil), nil)
+	if !errors.Is(err, errs.ErrHook) {
+		t.Fatalf("expected ErrHook, got %v", err)
+	}
+	if strings.Contains(output, "never") {
+		t.Fatalf("expected later scripts not to run, got %q", output)
+	}
+}
@@ -0,0 +1,26 @@
+package ui
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Edit opens path in the user's editor, given by $VISUAL or $EDITOR and falling back to vi, and
+// waits for it to exit. The editor command may include arguments, such as "code --wait".
+func Edit(path string) error {
+	fields := strings.Fields(cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR")))
+	if len(fields) == 0 {
+		fields = []string{"vi"}
+	}
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", fields[0], err)
+	}
+	return nil
+}
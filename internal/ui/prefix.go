@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// PrefixWriter writes each line written to it to an underlying writer, preceded by a prefix.
+// Several PrefixWriters can share an underlying writer and mutex, so that lines from concurrent
+// commands are not interleaved mid-line.
+type PrefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	buf    []byte
+}
+
+// NewPrefixWriter returns a PrefixWriter that writes to w while holding mu.
+func NewPrefixWriter(w io.Writer, mu *sync.Mutex, prefix string) *PrefixWriter {
+	return &PrefixWriter{w: w, mu: mu, prefix: prefix}
+}
+
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes any trailing partial line.
+func (p *PrefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *PrefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := io.WriteString(p.w, p.prefix)
+	if err == nil {
+		_, err = p.w.Write(line)
+	}
+	return err
+}
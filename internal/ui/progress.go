@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// IsTerminal reports whether w is a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// Progress writes the output of a long-running command to an underlying writer as it is written
+// to it, each line preceded by a prefix. On a terminal, it also keeps a spinner with the label
+// and the time elapsed on the line below the output, until it is stopped.
+type Progress struct {
+	w      io.Writer
+	prefix string
+	label  string
+	start  time.Time
+
+	mu    sync.Mutex
+	buf   []byte
+	frame int
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewProgress returns a Progress writing to w. The spinner is only shown when tty is true.
+func NewProgress(w io.Writer, prefix, label string, tty bool) *Progress {
+	p := &Progress{w: w, prefix: prefix, label: label, start: time.Now()}
+	if tty {
+		p.done = make(chan struct{})
+		p.render()
+		p.wg.Add(1)
+		go p.spin()
+	}
+	return p
+}
+
+func (p *Progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	i := bytes.LastIndexByte(p.buf, '\n')
+	if i < 0 {
+		return len(b), nil
+	}
+	lines := p.buf[:i+1]
+	p.buf = p.buf[i+1:]
+	return len(b), p.writeLines(lines)
+}
+
+// Stop writes any trailing partial line, removes the spinner and returns the time elapsed since
+// the Progress was created.
+func (p *Progress) Stop() time.Duration {
+	if p.done != nil {
+		close(p.done)
+		p.wg.Wait()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) > 0 {
+		p.writeLines(append(p.buf, '\n'))
+		p.buf = nil
+	}
+	if p.done != nil {
+		io.WriteString(p.w, "\r\033[K")
+	}
+	return time.Since(p.start)
+}
+
+// writeLines writes complete lines, clearing the spinner before them and drawing it again after.
+// p.mu must be held.
+func (p *Progress) writeLines(lines []byte) error {
+	var out bytes.Buffer
+	if p.done != nil {
+		out.WriteString("\r\033[K")
+	}
+	for line := range bytes.Lines(lines) {
+		out.WriteString(p.prefix)
+		out.Write(line)
+	}
+	if _, err := p.w.Write(out.Bytes()); err != nil {
+		return err
+	}
+	if p.done != nil {
+		p.render()
+	}
+	return nil
+}
+
+func (p *Progress) spin() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.frame = (p.frame + 1) % len(spinnerFrames)
+			io.WriteString(p.w, "\r\033[K")
+			p.render()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// render draws the spinner line, without a trailing newline.
+func (p *Progress) render() {
+	elapsed := time.Since(p.start).Truncate(time.Second)
+	fmt.Fprintf(p.w, "%s %s %s", Blue(spinnerFrames[p.frame]), p.label, Dim(elapsed.String()))
+}
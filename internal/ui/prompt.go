@@ -24,6 +24,26 @@ func MultiSelect(message string, options []string) ([]string, error) {
 	return selected, err
 }
 
+// Select shows an interactive single-select prompt and returns the selected item.
+func Select(message string, options []string) (string, error) {
+	var selected string
+	opts := make([]huh.Option[string], len(options))
+	for i, o := range options {
+		opts[i] = huh.NewOption(o, o)
+	}
+
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title(message).
+				Options(opts...).
+				Value(&selected),
+		),
+	).Run()
+
+	return selected, err
+}
+
 // Confirm shows a yes/no confirmation prompt.
 func Confirm(message string) (bool, error) {
 	var confirmed bool
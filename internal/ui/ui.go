@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
 )
@@ -38,9 +39,8 @@ func PrintTable(w io.Writer, rows [][]string, indent int) {
 	widths := make([]int, maxCols)
 	for _, row := range rows {
 		for i, cell := range row {
-			plain := stripAnsi(cell)
-			if len(plain) > widths[i] {
-				widths[i] = len(plain)
+			if n := utf8.RuneCountInString(stripAnsi(cell)); n > widths[i] {
+				widths[i] = n
 			}
 		}
 	}
@@ -52,8 +52,7 @@ func PrintTable(w io.Writer, rows [][]string, indent int) {
 			if i > 0 {
 				fmt.Fprint(w, "  ")
 			}
-			plain := stripAnsi(cell)
-			padding := widths[i] - len(plain)
+			padding := widths[i] - utf8.RuneCountInString(stripAnsi(cell))
 			fmt.Fprint(w, cell)
 			if i < len(row)-1 && padding > 0 {
 				fmt.Fprint(w, strings.Repeat(" ", padding))
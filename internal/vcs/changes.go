@@ -0,0 +1,44 @@
+package vcs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Changes describes work in a workroom that would be lost if it were deleted.
+type Changes struct {
+	Modified  []string // files with uncommitted changes
+	Untracked []string // untracked files (git only)
+	Unpushed  []string // git commits not on any remote, or jj changes not on any remote bookmark
+}
+
+// Empty reports whether there is nothing that would be lost.
+func (c Changes) Empty() bool {
+	return len(c.Modified) == 0 && len(c.Untracked) == 0 && len(c.Unpushed) == 0
+}
+
+// Summary returns a short description such as "2 modified, 1 untracked, 3 unpushed".
+func (c Changes) Summary() string {
+	var parts []string
+	if n := len(c.Modified); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d modified", n))
+	}
+	if n := len(c.Untracked); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d untracked", n))
+	}
+	if n := len(c.Unpushed); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d unpushed", n))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// nonEmptyLines splits output into lines, dropping blank ones.
+func nonEmptyLines(output string) []string {
+	var result []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
@@ -1,23 +1,40 @@
 package vcs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os/exec"
 	"strings"
 )
 
 // CommandExecutor abstracts shell command execution for testability.
 type CommandExecutor interface {
-	Run(dir string, name string, args ...string) (string, error)
+	Run(ctx context.Context, dir string, name string, args ...string) (string, error)
 }
 
-// RealExecutor runs actual shell commands.
+// RealExecutor runs actual shell commands, returning their output without its trailing newlines.
+// Leading whitespace is kept, as it is significant in output such as `git status --porcelain`. A
+// command still running when its context is done is killed, and the context's cause is returned.
 type RealExecutor struct{}
 
-func (r *RealExecutor) Run(dir string, name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
+func (r *RealExecutor) Run(ctx context.Context, dir string, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
 	if dir != "" {
 		cmd.Dir = dir
 	}
 	out, err := cmd.CombinedOutput()
-	return strings.TrimSpace(string(out)), err
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%s %s was stopped: %w", name, strings.Join(args, " "), context.Cause(ctx))
+	}
+	return strings.TrimRight(string(out), "\r\n"), err
+}
+
+// exitCode returns the exit code of a command that ran but failed, or -1 for any other error.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
 }
@@ -1,7 +1,10 @@
 package vcs
 
 import (
+	"context"
+	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -13,29 +16,174 @@ type Git struct {
 func (g *Git) Type() Type    { return TypeGit }
 func (g *Git) Label() string { return "Git worktree" }
 
-func (g *Git) WorkroomExists(dir, name string) (bool, error) {
-	worktrees, err := g.listWorktreePaths(dir)
+// WorkroomExists reports whether the repo at dir has a worktree at path. Worktrees are matched by
+// their full path, as those of other projects or layouts may share the workroom's name.
+func (g *Git) WorkroomExists(ctx context.Context, dir, path, _ string) (bool, error) {
+	worktrees, err := g.listWorktreePaths(ctx, dir)
 	if err != nil {
 		return false, err
 	}
-	for _, path := range worktrees {
-		if filepath.Base(path) == name {
+	for _, w := range worktrees {
+		if SamePath(w, path) {
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-func (g *Git) Create(dir, vcsName, path string) (string, error) {
-	return g.Executor.Run(dir, "git", "worktree", "add", "-b", vcsName, path)
+// Create adds a worktree at path. With opts.From, the existing ref is checked out; otherwise a new
+// branch named vcsName is forked from opts.Base (or HEAD).
+func (g *Git) Create(ctx context.Context, dir, vcsName, path string, opts CreateOptions) (string, error) {
+	if opts.From != "" {
+		return g.Executor.Run(ctx, dir, "git", "worktree", "add", path, opts.From)
+	}
+	args := []string{"worktree", "add", "-b", vcsName, path}
+	if opts.Base != "" {
+		args = append(args, opts.Base)
+	}
+	return g.Executor.Run(ctx, dir, "git", args...)
+}
+
+func (g *Git) Delete(ctx context.Context, dir, vcsName, path string, opts DeleteOptions) (string, error) {
+	out, err := g.Executor.Run(ctx, dir, "git", "worktree", "remove", path, "--force")
+	if err != nil || !opts.DeleteBranch {
+		return out, err
+	}
+	exists, err := g.HasBranch(ctx, dir, vcsName)
+	if err != nil || !exists {
+		return out, err
+	}
+	return g.Executor.Run(ctx, dir, "git", "branch", "-D", vcsName)
+}
+
+// BranchMerged reports whether the branch can be deleted without losing commits: it either does
+// not exist, or is an ancestor of trunk. An empty trunk means origin's default branch, falling
+// back to HEAD.
+func (g *Git) BranchMerged(ctx context.Context, dir, vcsName, trunk string) (bool, error) {
+	exists, err := g.HasBranch(ctx, dir, vcsName)
+	if err != nil || !exists {
+		return true, err
+	}
+	if trunk == "" {
+		trunk = g.defaultTrunk(ctx, dir)
+	}
+	out, err := g.Executor.Run(ctx, dir, "git", "merge-base", "--is-ancestor", vcsName, trunk)
+	if err != nil {
+		if exitCode(err) == 1 {
+			return false, nil
+		}
+		return false, fmt.Errorf("compare %s with %s: %s", vcsName, trunk, out)
+	}
+	return true, nil
 }
 
-func (g *Git) Delete(dir, _, path string) (string, error) {
-	return g.Executor.Run(dir, "git", "worktree", "remove", path, "--force")
+// Changes inspects the worktree at path for uncommitted and untracked files, and for commits that
+// are not on any remote. Unpushed commits are not reported for repos without remotes.
+func (g *Git) Changes(ctx context.Context, path string) (Changes, error) {
+	var changes Changes
+
+	out, err := g.Executor.Run(ctx, path, "git", "status", "--porcelain")
+	if err != nil {
+		return changes, fmt.Errorf("git status in %s: %s", path, out)
+	}
+	changes.Modified, changes.Untracked = parseGitStatus(out)
+
+	remotes, err := g.Executor.Run(ctx, path, "git", "remote")
+	if err != nil || remotes == "" {
+		return changes, nil
+	}
+	out, err = g.Executor.Run(ctx, path, "git", "log", "--format=%h %s", "HEAD", "--not", "--remotes")
+	if err != nil {
+		return changes, fmt.Errorf("git log in %s: %s", path, out)
+	}
+	changes.Unpushed = nonEmptyLines(out)
+
+	return changes, nil
 }
 
-func (g *Git) ListWorkrooms(dir string) ([]string, error) {
-	paths, err := g.listWorktreePaths(dir)
+// Status reports the branch, dirty files, last commit and position relative to trunk of the
+// worktree at path. An empty trunk means origin's default branch, falling back to HEAD of the
+// worktree, in which case ahead and behind are always zero.
+func (g *Git) Status(ctx context.Context, path, trunk string) (Status, error) {
+	var status Status
+
+	out, err := g.Executor.Run(ctx, path, "git", "status", "--porcelain", "--branch")
+	if err != nil {
+		return status, fmt.Errorf("git status in %s: %s", path, out)
+	}
+	status.Branch = parseGitStatusBranch(out)
+	modified, untracked := parseGitStatus(out)
+	status.DirtyFiles = append(modified, untracked...)
+
+	out, err = g.Executor.Run(ctx, path, "git", "log", "-1", "--format=%h"+fieldSep+"%s"+fieldSep+"%ct")
+	if err == nil {
+		hash, rest, _ := strings.Cut(out, fieldSep)
+		status.CommitSubject, status.CommitTime = parseCommitLine(rest)
+		if status.Branch == "" {
+			status.Branch = hash
+		}
+	}
+
+	if trunk == "" {
+		trunk = g.defaultTrunk(ctx, path)
+	}
+	out, err = g.Executor.Run(ctx, path, "git", "rev-list", "--left-right", "--count", trunk+"...HEAD")
+	if err == nil {
+		if fields := strings.Fields(out); len(fields) == 2 {
+			status.Behind, _ = strconv.Atoi(fields[0])
+			status.Ahead, _ = strconv.Atoi(fields[1])
+		}
+	}
+
+	return status, nil
+}
+
+// HasBranch reports whether the local branch exists.
+func (g *Git) HasBranch(ctx context.Context, dir, vcsName string) (bool, error) {
+	_, err := g.Executor.Run(ctx, dir, "git", "show-ref", "--verify", "--quiet", "refs/heads/"+vcsName)
+	if err != nil {
+		if exitCode(err) == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// UpstreamGone reports whether the branch tracks an upstream branch that no longer exists, as
+// when it was deleted on the remote after being merged. Git only notices once the remote is
+// fetched with --prune.
+func (g *Git) UpstreamGone(ctx context.Context, dir, vcsName string) (bool, error) {
+	out, err := g.Executor.Run(ctx, dir, "git", "for-each-ref", "--format=%(upstream:track)", "refs/heads/"+vcsName)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out) == "[gone]", nil
+}
+
+func (g *Git) defaultTrunk(ctx context.Context, dir string) string {
+	out, err := g.Executor.Run(ctx, dir, "git", "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+	if err != nil || out == "" || strings.ContainsAny(out, " \t\n") {
+		return "HEAD"
+	}
+	return out
+}
+
+func (g *Git) Move(ctx context.Context, dir, _, oldPath, newPath string) (string, error) {
+	return g.Executor.Run(ctx, dir, "git", "worktree", "move", oldPath, newPath)
+}
+
+// Rename renames the worktree's branch, if it has one.
+func (g *Git) Rename(ctx context.Context, dir, _, oldVCSName, newVCSName string) (string, error) {
+	exists, err := g.HasBranch(ctx, dir, oldVCSName)
+	if err != nil || !exists {
+		return "", err
+	}
+	return g.Executor.Run(ctx, dir, "git", "branch", "-m", oldVCSName, newVCSName)
+}
+
+func (g *Git) ListWorkrooms(ctx context.Context, dir string) ([]string, error) {
+	paths, err := g.listWorktreePaths(ctx, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -46,29 +194,166 @@ func (g *Git) ListWorkrooms(dir string) ([]string, error) {
 	return names, nil
 }
 
-func (g *Git) listWorktreePaths(dir string) ([]string, error) {
-	out, err := g.Executor.Run(dir, "git", "worktree", "list", "--porcelain")
+func (g *Git) listWorktreePaths(ctx context.Context, dir string) ([]string, error) {
+	out, err := g.Executor.Run(ctx, dir, "git", "worktree", "list", "--porcelain")
 	if err != nil {
 		return nil, err
 	}
 	return parseGitWorktrees(out, dir), nil
 }
 
+// Workspaces returns the worktrees of the repo at dir, other than dir itself.
+func (g *Git) Workspaces(ctx context.Context, dir string) ([]Workspace, error) {
+	out, err := g.Executor.Run(ctx, dir, "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	return parseGitWorktreeEntries(out, dir), nil
+}
+
+// WorkspaceAt returns the worktree of the repo at dir whose directory is path, or nil if there is
+// none.
+func (g *Git) WorkspaceAt(ctx context.Context, dir, path string) (*Workspace, error) {
+	workspaces, err := g.Workspaces(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range workspaces {
+		if SamePath(w.Path, path) {
+			return &w, nil
+		}
+	}
+	return nil, nil
+}
+
+// Forget prunes the administrative files of worktrees whose directories no longer exist. Git
+// cannot forget a single worktree, so vcsName is unused.
+func (g *Git) Forget(ctx context.Context, dir, _ string) (string, error) {
+	return g.Executor.Run(ctx, dir, "git", "worktree", "prune")
+}
+
+// Branches returns the local branches whose names start with prefix.
+func (g *Git) Branches(ctx context.Context, dir, prefix string) ([]string, error) {
+	out, err := g.Executor.Run(ctx, dir, "git", "for-each-ref", "--format=%(refname)", "refs/heads/"+prefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("list branches: %s", out)
+	}
+	var branches []string
+	for _, ref := range nonEmptyLines(out) {
+		branches = append(branches, strings.TrimPrefix(ref, "refs/heads/"))
+	}
+	return branches, nil
+}
+
+// DeleteBranch deletes the branch, whether or not it is merged. A branch that does not exist is
+// ignored.
+func (g *Git) DeleteBranch(ctx context.Context, dir, vcsName string) (string, error) {
+	exists, err := g.HasBranch(ctx, dir, vcsName)
+	if err != nil || !exists {
+		return "", err
+	}
+	return g.Executor.Run(ctx, dir, "git", "branch", "-D", vcsName)
+}
+
+// Lock locks the worktree at path, so that `git worktree prune`, `move` and `remove` leave it
+// alone. A worktree that is already locked is locked again with the new reason.
+func (g *Git) Lock(ctx context.Context, dir, path, reason string) (string, error) {
+	args := []string{"worktree", "lock", path}
+	if reason != "" {
+		args = append(args, "--reason", reason)
+	}
+	out, err := g.Executor.Run(ctx, dir, "git", args...)
+	if err != nil && strings.Contains(out, "already locked") {
+		if out, err := g.Executor.Run(ctx, dir, "git", "worktree", "unlock", path); err != nil {
+			return out, err
+		}
+		return g.Executor.Run(ctx, dir, "git", args...)
+	}
+	return out, err
+}
+
+// Unlock unlocks the worktree at path. A worktree that is not locked is left alone.
+func (g *Git) Unlock(ctx context.Context, dir, path string) (string, error) {
+	out, err := g.Executor.Run(ctx, dir, "git", "worktree", "unlock", path)
+	if err != nil && strings.Contains(out, "is not locked") {
+		return out, nil
+	}
+	return out, err
+}
+
 func parseGitWorktrees(output, cwd string) []string {
 	var result []string
-	var directory string
+	for _, w := range parseGitWorktreeEntries(output, cwd) {
+		result = append(result, w.Path)
+	}
+	return result
+}
+
+// parseGitWorktreeEntries parses `git worktree list --porcelain` output, leaving out bare repos,
+// the worktree at cwd, and the main worktree. Git always lists the main worktree first, which
+// identifies it even when cwd reaches it through a symlink.
+func parseGitWorktreeEntries(output, cwd string) []Workspace {
+	var result []Workspace
+	var current *Workspace
+	first := true
 	for _, line := range strings.Split(output, "\n") {
-		if strings.HasPrefix(line, "worktree ") {
-			directory = strings.TrimPrefix(line, "worktree ")
+		if path, ok := strings.CutPrefix(line, "worktree "); ok {
+			current = nil
+			main := first
+			first = false
+			if !main && !SamePath(path, cwd) {
+				result = append(result, Workspace{Path: path})
+				current = &result[len(result)-1]
+			}
 			continue
 		}
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
+		if current == nil {
 			continue
 		}
-		if fields[0] == "HEAD" && directory != cwd {
-			result = append(result, directory)
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "bare":
+			result = result[:len(result)-1]
+			current = nil
+		case "branch":
+			current.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case "prunable":
+			current.Prunable = true
 		}
 	}
 	return result
 }
+
+// parseGitStatus splits `git status --porcelain` output into modified and untracked paths.
+func parseGitStatus(output string) (modified, untracked []string) {
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 4 || line[2] != ' ' || line[:2] == "##" {
+			continue
+		}
+		if line[:2] == "??" {
+			untracked = append(untracked, line[3:])
+		} else {
+			modified = append(modified, line[3:])
+		}
+	}
+	return modified, untracked
+}
+
+// parseGitStatusBranch returns the branch from the "## branch...upstream" header of
+// `git status --porcelain --branch` output, or "" when HEAD is detached.
+func parseGitStatusBranch(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		header, ok := strings.CutPrefix(line, "## ")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(header, "HEAD (no branch)") {
+			return ""
+		}
+		header = strings.TrimPrefix(header, "No commits yet on ")
+		branch, _, _ := strings.Cut(header, "...")
+		branch, _, _ = strings.Cut(branch, " ")
+		return branch
+	}
+	return ""
+}
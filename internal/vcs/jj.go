@@ -1,7 +1,16 @@
 package vcs
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 )
 
 // JJ implements VCS for Jujutsu workspaces.
@@ -12,12 +21,13 @@ type JJ struct {
 func (j *JJ) Type() Type    { return TypeJJ }
 func (j *JJ) Label() string { return "JJ workspace" }
 
-func (j *JJ) WorkroomExists(dir, name string) (bool, error) {
-	workrooms, err := j.ListWorkrooms(dir)
+// WorkroomExists reports whether the repo at dir has a workspace named vcsName. Workspace names
+// are unique within a repo, so path is unused.
+func (j *JJ) WorkroomExists(ctx context.Context, dir, _, vcsName string) (bool, error) {
+	workrooms, err := j.ListWorkrooms(ctx, dir)
 	if err != nil {
 		return false, err
 	}
-	vcsName := "workroom/" + name
 	for _, w := range workrooms {
 		if w == vcsName {
 			return true, nil
@@ -26,22 +36,242 @@ func (j *JJ) WorkroomExists(dir, name string) (bool, error) {
 	return false, nil
 }
 
-func (j *JJ) Create(dir, vcsName, path string) (string, error) {
-	return j.Executor.Run(dir, "jj", "workspace", "add", path, "--name", vcsName)
+// Create adds a workspace at path. JJ has no separate notion of checking out versus forking, so
+// both opts.From and opts.Base select the revision the new working-copy commit is based on.
+func (j *JJ) Create(ctx context.Context, dir, vcsName, path string, opts CreateOptions) (string, error) {
+	args := []string{"workspace", "add", path, "--name", vcsName}
+	if rev := cmp.Or(opts.From, opts.Base); rev != "" {
+		args = append(args, "--revision", rev)
+	}
+	return j.Executor.Run(ctx, dir, "jj", args...)
+}
+
+func (j *JJ) Delete(ctx context.Context, dir, vcsName, _ string, opts DeleteOptions) (string, error) {
+	// The working-copy commit can only be addressed as <name>@ while the workspace exists.
+	if opts.DeleteBranch {
+		revset := vcsName + "@ & empty()"
+		if opts.Force {
+			revset = vcsName + "@"
+		}
+		if out, err := j.Executor.Run(ctx, dir, "jj", "abandon", revset); err != nil {
+			return out, err
+		}
+	}
+	return j.Executor.Run(ctx, dir, "jj", "workspace", "forget", vcsName)
+}
+
+// BranchMerged always reports true for JJ. A working-copy commit with changes is only abandoned
+// when forced, and otherwise stays in the repo after the workspace is forgotten, so no work is lost.
+func (j *JJ) BranchMerged(ctx context.Context, _, _, _ string) (bool, error) {
+	return true, nil
+}
+
+// Move relocates the workspace directory. The workspace keeps its pointer to the parent repo, so
+// no jj command is needed. A directory that cannot be renamed because newPath is on another
+// filesystem is copied there and then removed.
+func (j *JJ) Move(ctx context.Context, _, _, oldPath, newPath string) (string, error) {
+	err := os.Rename(oldPath, newPath)
+	if !errors.Is(err, syscall.EXDEV) {
+		return "", err
+	}
+	if err := copyDir(oldPath, newPath); err != nil {
+		os.RemoveAll(newPath)
+		return "", fmt.Errorf("copy %s to another filesystem: %w", oldPath, err)
+	}
+	return "", os.RemoveAll(oldPath)
+}
+
+// copyDir copies the directory tree at src to dst, which must not exist, keeping file modes and
+// symlinks.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, info.Mode().Perm()|0o700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			in, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, in); err != nil {
+				out.Close()
+				return err
+			}
+			return out.Close()
+		}
+	})
+}
+
+// jjUnpushedRevset selects the changes that only the current workspace has, and no remote.
+const jjUnpushedRevset = "(::@ & mutable()) ~ ::remote_bookmarks() ~ ::(working_copies() ~ @) ~ empty() ~ @"
+
+// Rename renames the workspace at path. JJ can only rename the workspace it is run in.
+func (j *JJ) Rename(ctx context.Context, _, path, _, newVCSName string) (string, error) {
+	return j.Executor.Run(ctx, path, "jj", "workspace", "rename", newVCSName)
 }
 
-func (j *JJ) Delete(dir, vcsName, _ string) (string, error) {
-	return j.Executor.Run(dir, "jj", "workspace", "forget", vcsName)
+// Changes inspects the workspace at path for files changed in its working-copy commit, and for
+// non-empty changes that are not on any remote bookmark. Changes that other workspaces, such as
+// the project's default one, are built on are left out, as forgetting this workspace keeps them
+// all the same. Running jj in the workspace snapshots its working copy first, so the result is
+// current.
+func (j *JJ) Changes(ctx context.Context, path string) (Changes, error) {
+	var changes Changes
+
+	out, err := j.Executor.Run(ctx, path, "jj", "diff", "--summary", "--color", "never")
+	if err != nil {
+		return changes, fmt.Errorf("jj diff in %s: %s", path, out)
+	}
+	changes.Modified = parseJJDiffSummary(out)
+
+	out, err = j.Executor.Run(ctx, path, "jj", "log", "--no-graph", "--color", "never",
+		"-r", jjUnpushedRevset,
+		"-T", `change_id.short() ++ " " ++ description.first_line() ++ "\n"`)
+	if err != nil {
+		return changes, fmt.Errorf("jj log in %s: %s", path, out)
+	}
+	changes.Unpushed = nonEmptyLines(out)
+
+	return changes, nil
 }
 
-func (j *JJ) ListWorkrooms(dir string) ([]string, error) {
-	out, err := j.Executor.Run(dir, "jj", "workspace", "list", "--color", "never")
+// Status reports the working-copy change id, changed files, last non-empty change and position
+// relative to trunk of the workspace at path. An empty trunk means jj's trunk() revset.
+func (j *JJ) Status(ctx context.Context, path, trunk string) (Status, error) {
+	var status Status
+
+	out, err := j.Executor.Run(ctx, path, "jj", "log", "--no-graph", "--color", "never", "-r", "@", "-T", "change_id.short()")
+	if err != nil {
+		return status, fmt.Errorf("jj log in %s: %s", path, out)
+	}
+	status.Branch = strings.TrimSpace(out)
+
+	out, err = j.Executor.Run(ctx, path, "jj", "diff", "--summary", "--color", "never")
+	if err == nil {
+		status.DirtyFiles = parseJJDiffSummary(out)
+	}
+
+	out, err = j.Executor.Run(ctx, path, "jj", "log", "--no-graph", "--color", "never",
+		"-r", "latest(::@ ~ empty())",
+		"-T", `description.first_line() ++ "`+fieldSep+`" ++ committer.timestamp().format("%s")`)
+	if err == nil {
+		status.CommitSubject, status.CommitTime = parseCommitLine(out)
+	}
+
+	trunkRev := "trunk()"
+	if trunk != "" {
+		trunkRev = fmt.Sprintf("%q", trunk)
+	}
+	status.Ahead = j.countRevisions(ctx, path, fmt.Sprintf("(%s..@) ~ empty()", trunkRev))
+	status.Behind = j.countRevisions(ctx, path, fmt.Sprintf("@..%s", trunkRev))
+
+	return status, nil
+}
+
+// countRevisions returns the number of revisions in revset, or zero if it cannot be evaluated.
+func (j *JJ) countRevisions(ctx context.Context, path, revset string) int {
+	out, err := j.Executor.Run(ctx, path, "jj", "log", "--no-graph", "--color", "never", "-r", revset, "-T", `"x\n"`)
+	if err != nil {
+		return 0
+	}
+	return len(nonEmptyLines(out))
+}
+
+func (j *JJ) ListWorkrooms(ctx context.Context, dir string) ([]string, error) {
+	out, err := j.Executor.Run(ctx, dir, "jj", "workspace", "list", "--color", "never")
 	if err != nil {
 		return nil, err
 	}
 	return parseJJWorkspaces(out), nil
 }
 
+// Workspaces returns the workspaces of the repo at dir, other than the default one.
+func (j *JJ) Workspaces(ctx context.Context, dir string) ([]Workspace, error) {
+	names, err := j.ListWorkrooms(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	workspaces := make([]Workspace, len(names))
+	for i, name := range names {
+		workspaces[i] = Workspace{Name: name}
+	}
+	return workspaces, nil
+}
+
+// WorkspaceAt returns the workspace of the repo at dir whose directory is path, or nil if there is
+// none. JJ does not list workspace directories, so the workspace is found by its working-copy
+// change.
+func (j *JJ) WorkspaceAt(ctx context.Context, dir, path string) (*Workspace, error) {
+	changeID, err := j.Executor.Run(ctx, path, "jj", "log", "--no-graph", "--color", "never", "-r", "@", "-T", "change_id")
+	if err != nil || changeID == "" {
+		return nil, nil
+	}
+	out, err := j.Executor.Run(ctx, dir, "jj", "workspace", "list", "--color", "never")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		name, rest, ok := strings.Cut(strings.TrimSpace(line), ":")
+		fields := strings.Fields(rest)
+		if !ok || name == "default" || len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(changeID), fields[0]) {
+			return &Workspace{Name: name, Path: path}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Forget stops tracking the named workspace, leaving its directory alone.
+func (j *JJ) Forget(ctx context.Context, dir, vcsName string) (string, error) {
+	return j.Executor.Run(ctx, dir, "jj", "workspace", "forget", vcsName)
+}
+
+// Branches always returns nothing for JJ, whose workrooms have no branch of their own.
+func (j *JJ) Branches(ctx context.Context, _, _ string) ([]string, error) {
+	return nil, nil
+}
+
+// DeleteBranch is a no-op for JJ, whose workrooms have no branch of their own.
+func (j *JJ) DeleteBranch(ctx context.Context, _, _ string) (string, error) {
+	return "", nil
+}
+
+// Lock is a no-op for JJ, which cannot lock workspaces.
+func (j *JJ) Lock(ctx context.Context, _, _, _ string) (string, error) {
+	return "", nil
+}
+
+// Unlock is a no-op for JJ, which cannot lock workspaces.
+func (j *JJ) Unlock(ctx context.Context, _, _ string) (string, error) {
+	return "", nil
+}
+
 func parseJJWorkspaces(output string) []string {
 	var result []string
 	for _, line := range strings.Split(output, "\n") {
@@ -58,3 +288,16 @@ func parseJJWorkspaces(output string) []string {
 	}
 	return result
 }
+
+// parseJJDiffSummary extracts the paths from `jj diff --summary` output, whose lines look like
+// "M path/to/file".
+func parseJJDiffSummary(output string) []string {
+	var result []string
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 3 || line[1] != ' ' || !strings.ContainsRune("MADRC", rune(line[0])) {
+			continue
+		}
+		result = append(result, line[2:])
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package vcs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status describes the state of a workroom's working copy, for display in `workroom list`.
+type Status struct {
+	Branch        string    // current git branch (short hash when detached), or jj change id
+	DirtyFiles    []string  // files changed or untracked in the working copy
+	Ahead         int       // commits on the workroom but not on trunk
+	Behind        int       // commits on trunk but not on the workroom
+	CommitSubject string    // subject of the most recent commit
+	CommitTime    time.Time // committer time of the most recent commit
+}
+
+// fieldSep separates fields in templated VCS output.
+const fieldSep = "\x1f"
+
+// parseCommitLine parses "subject<sep>unix-seconds" into a subject and time.
+func parseCommitLine(line string) (string, time.Time) {
+	subject, ts, _ := strings.Cut(strings.TrimSpace(line), fieldSep)
+	secs, err := strconv.ParseInt(strings.TrimSpace(ts), 10, 64)
+	if err != nil {
+		return subject, time.Time{}
+	}
+	return subject, time.Unix(secs, 0)
+}
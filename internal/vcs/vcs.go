@@ -1,6 +1,7 @@
 package vcs
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 
@@ -15,14 +16,58 @@ const (
 	TypeGit Type = "git"
 )
 
+// CreateOptions configures how a workroom's workspace is created.
+type CreateOptions struct {
+	// From is an existing git branch, tag or commit, or a jj revset, to check out in the new
+	// workspace instead of creating a new branch.
+	From string
+	// Base is the ref to fork the new workroom from. Defaults to HEAD for git, and to the
+	// parent's working-copy parent for jj.
+	Base string
+}
+
+// DeleteOptions configures how a workroom's workspace is deleted.
+type DeleteOptions struct {
+	// DeleteBranch also deletes the workroom's git branch, or abandons its jj working-copy commit
+	// if it is empty.
+	DeleteBranch bool
+	// Force abandons the jj working-copy commit even when it is not empty.
+	Force bool
+}
+
+// Workspace is a Git worktree or JJ workspace of a repo, other than the one the repo was listed
+// from.
+type Workspace struct {
+	// Name is the JJ workspace name. Git worktrees have no name, and are identified by Path.
+	Name string
+	// Path is the worktree directory. JJ does not report it, so it is empty for JJ.
+	Path string
+	// Branch is the branch checked out in a Git worktree, if any.
+	Branch string
+	// Prunable is set when Git reports that the worktree's directory no longer exists.
+	Prunable bool
+}
+
 // VCS defines the interface for version control operations on workrooms.
 type VCS interface {
 	Type() Type
 	Label() string
-	WorkroomExists(dir, name string) (bool, error)
-	Create(dir, vcsName, path string) (string, error)
-	Delete(dir, vcsName, path string) (string, error)
-	ListWorkrooms(dir string) ([]string, error)
+	WorkroomExists(ctx context.Context, dir, path, vcsName string) (bool, error)
+	Create(ctx context.Context, dir, vcsName, path string, opts CreateOptions) (string, error)
+	Delete(ctx context.Context, dir, vcsName, path string, opts DeleteOptions) (string, error)
+	Move(ctx context.Context, dir, vcsName, oldPath, newPath string) (string, error)
+	Rename(ctx context.Context, dir, path, oldVCSName, newVCSName string) (string, error)
+	BranchMerged(ctx context.Context, dir, vcsName, trunk string) (bool, error)
+	Changes(ctx context.Context, path string) (Changes, error)
+	Status(ctx context.Context, path, trunk string) (Status, error)
+	ListWorkrooms(ctx context.Context, dir string) ([]string, error)
+	Workspaces(ctx context.Context, dir string) ([]Workspace, error)
+	WorkspaceAt(ctx context.Context, dir, path string) (*Workspace, error)
+	Forget(ctx context.Context, dir, vcsName string) (string, error)
+	Branches(ctx context.Context, dir, prefix string) ([]string, error)
+	DeleteBranch(ctx context.Context, dir, vcsName string) (string, error)
+	Lock(ctx context.Context, dir, path, reason string) (string, error)
+	Unlock(ctx context.Context, dir, path string) (string, error)
 }
 
 // Detect determines the VCS type by checking for .jj then .git directories.
@@ -36,3 +81,14 @@ func Detect(dir string) (VCS, error) {
 	}
 	return nil, errs.ErrUnsupportedVCS
 }
+
+// SamePath reports whether a and b are the same directory, resolving symlinks where possible. Git
+// reports the real paths of worktrees, which differ from the configured ones behind a symlink.
+func SamePath(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && ra == rb
+}
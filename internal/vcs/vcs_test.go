@@ -1,10 +1,14 @@
 package vcs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/joelmoss/workroom/internal/errs"
@@ -17,12 +21,21 @@ type MockExecutor struct {
 	Calls  [][]string
 }
 
-func (m *MockExecutor) Run(dir string, name string, args ...string) (string, error) {
+func (m *MockExecutor) Run(_ context.Context, dir string, name string, args ...string) (string, error) {
 	call := append([]string{name}, args...)
 	m.Calls = append(m.Calls, call)
 	return m.Output, m.Err
 }
 
+// funcExecutor delegates to fn, for tests that need different results per command.
+type funcExecutor struct {
+	fn func(name string, args []string) (string, error)
+}
+
+func (f *funcExecutor) Run(_ context.Context, _ string, name string, args ...string) (string, error) {
+	return f.fn(name, args)
+}
+
 func TestDetectJJ(t *testing.T) {
 	dir := t.TempDir()
 	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
@@ -93,7 +106,7 @@ func TestJJListWorkrooms(t *testing.T) {
 	}
 	jj := &JJ{Executor: mock}
 
-	workrooms, err := jj.ListWorkrooms("/project")
+	workrooms, err := jj.ListWorkrooms(t.Context(), "/project")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -114,7 +127,7 @@ func TestJJWorkroomExists(t *testing.T) {
 	}
 	jj := &JJ{Executor: mock}
 
-	exists, err := jj.WorkroomExists("/project", "foo")
+	exists, err := jj.WorkroomExists(t.Context(), "/project", "/workrooms/foo", "workroom/foo")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -122,7 +135,7 @@ func TestJJWorkroomExists(t *testing.T) {
 		t.Fatal("expected workspace to exist")
 	}
 
-	exists, err = jj.WorkroomExists("/project", "bar")
+	exists, err = jj.WorkroomExists(t.Context(), "/project", "/workrooms/bar", "workroom/bar")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -135,7 +148,7 @@ func TestJJCreate(t *testing.T) {
 	mock := &MockExecutor{}
 	jj := &JJ{Executor: mock}
 
-	_, err := jj.Create("/project", "workroom/foo", "/workrooms/foo")
+	_, err := jj.Create(t.Context(), "/project", "workroom/foo", "/workrooms/foo", CreateOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -154,7 +167,7 @@ func TestJJDelete(t *testing.T) {
 	mock := &MockExecutor{}
 	jj := &JJ{Executor: mock}
 
-	_, err := jj.Delete("/project", "workroom/foo", "/workrooms/foo")
+	_, err := jj.Delete(t.Context(), "/project", "workroom/foo", "/workrooms/foo", DeleteOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -172,7 +185,7 @@ func TestGitListWorktrees(t *testing.T) {
 	}
 	git := &Git{Executor: mock}
 
-	workrooms, err := git.ListWorkrooms("/project")
+	workrooms, err := git.ListWorkrooms(t.Context(), "/project")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -193,7 +206,7 @@ func TestGitWorktreeExists(t *testing.T) {
 	}
 	git := &Git{Executor: mock}
 
-	exists, err := git.WorkroomExists("/project", "foo")
+	exists, err := git.WorkroomExists(t.Context(), "/project", "/workrooms/foo", "workroom/foo")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -201,7 +214,7 @@ func TestGitWorktreeExists(t *testing.T) {
 		t.Fatal("expected worktree to exist")
 	}
 
-	exists, err = git.WorkroomExists("/project", "bar")
+	exists, err = git.WorkroomExists(t.Context(), "/project", "/workrooms/bar", "workroom/bar")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -210,11 +223,26 @@ func TestGitWorktreeExists(t *testing.T) {
 	}
 }
 
+func TestGitWorktreeExistsMatchesFullPath(t *testing.T) {
+	mock := &MockExecutor{
+		Output: "worktree /foo\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree /workrooms/other/foo\nHEAD abc123\nbranch refs/heads/workroom/foo\n",
+	}
+	git := &Git{Executor: mock}
+
+	exists, err := git.WorkroomExists(t.Context(), "/foo", "/workrooms/foo/foo", "workroom/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("expected worktrees named foo at other paths not to count")
+	}
+}
+
 func TestGitCreate(t *testing.T) {
 	mock := &MockExecutor{}
 	git := &Git{Executor: mock}
 
-	_, err := git.Create("/project", "workroom/foo", "/workrooms/foo")
+	_, err := git.Create(t.Context(), "/project", "workroom/foo", "/workrooms/foo", CreateOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -226,11 +254,68 @@ func TestGitCreate(t *testing.T) {
 	}
 }
 
+func TestGitCreateFrom(t *testing.T) {
+	mock := &MockExecutor{}
+	git := &Git{Executor: mock}
+
+	_, err := git.Create(t.Context(), "/project", "workroom/foo", "/workrooms/foo", CreateOptions{From: "feature/login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"git", "worktree", "add", "/workrooms/foo", "feature/login"}
+	if len(mock.Calls[0]) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, mock.Calls[0])
+	}
+	for i, v := range expected {
+		if mock.Calls[0][i] != v {
+			t.Fatalf("expected %s at position %d, got %s", v, i, mock.Calls[0][i])
+		}
+	}
+}
+
+func TestGitCreateBase(t *testing.T) {
+	mock := &MockExecutor{}
+	git := &Git{Executor: mock}
+
+	_, err := git.Create(t.Context(), "/project", "workroom/foo", "/workrooms/foo", CreateOptions{Base: "origin/main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"git", "worktree", "add", "-b", "workroom/foo", "/workrooms/foo", "origin/main"}
+	if len(mock.Calls[0]) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, mock.Calls[0])
+	}
+	for i, v := range expected {
+		if mock.Calls[0][i] != v {
+			t.Fatalf("expected %s at position %d, got %s", v, i, mock.Calls[0][i])
+		}
+	}
+}
+
+func TestJJCreateFrom(t *testing.T) {
+	mock := &MockExecutor{}
+	jj := &JJ{Executor: mock}
+
+	_, err := jj.Create(t.Context(), "/project", "workroom/foo", "/workrooms/foo", CreateOptions{From: "main@origin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"jj", "workspace", "add", "/workrooms/foo", "--name", "workroom/foo", "--revision", "main@origin"}
+	if len(mock.Calls[0]) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, mock.Calls[0])
+	}
+	for i, v := range expected {
+		if mock.Calls[0][i] != v {
+			t.Fatalf("expected %s at position %d, got %s", v, i, mock.Calls[0][i])
+		}
+	}
+}
+
 func TestGitDelete(t *testing.T) {
 	mock := &MockExecutor{}
 	git := &Git{Executor: mock}
 
-	_, err := git.Delete("/project", "workroom/foo", "/workrooms/foo")
+	_, err := git.Delete(t.Context(), "/project", "workroom/foo", "/workrooms/foo", DeleteOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -242,13 +327,193 @@ func TestGitDelete(t *testing.T) {
 	}
 }
 
+func TestGitDeleteBranch(t *testing.T) {
+	mock := &MockExecutor{}
+	git := &Git{Executor: mock}
+
+	_, err := git.Delete(t.Context(), "/project", "workroom/foo", "/workrooms/foo", DeleteOptions{DeleteBranch: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.Calls) != 3 {
+		t.Fatalf("expected 3 calls, got %v", mock.Calls)
+	}
+	expected := []string{"git", "branch", "-D", "workroom/foo"}
+	for i, v := range expected {
+		if mock.Calls[2][i] != v {
+			t.Fatalf("expected %s at position %d, got %s", v, i, mock.Calls[2][i])
+		}
+	}
+}
+
+func TestJJDeleteForceAbandonsNonEmptyWorkingCopy(t *testing.T) {
+	mock := &MockExecutor{}
+	jj := &JJ{Executor: mock}
+
+	_, err := jj.Delete(t.Context(), "/project", "workroom/foo", "/workrooms/foo", DeleteOptions{DeleteBranch: true, Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mock.Calls[0][2] != "workroom/foo@" {
+		t.Fatalf("expected to abandon workroom/foo@, got %v", mock.Calls[0])
+	}
+}
+
+func TestGitBranchMerged(t *testing.T) {
+	mock := &MockExecutor{}
+	git := &Git{Executor: mock}
+
+	merged, err := git.BranchMerged(t.Context(), "/project", "workroom/foo", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !merged {
+		t.Fatal("expected branch to be merged")
+	}
+	expected := []string{"git", "merge-base", "--is-ancestor", "workroom/foo", "main"}
+	last := mock.Calls[len(mock.Calls)-1]
+	for i, v := range expected {
+		if last[i] != v {
+			t.Fatalf("expected %s at position %d, got %s", v, i, last[i])
+		}
+	}
+}
+
+func TestGitBranchNotMerged(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 1").Run()
+	git := &Git{Executor: &funcExecutor{fn: func(name string, args []string) (string, error) {
+		if args[0] == "merge-base" {
+			return "", exitErr
+		}
+		return "", nil
+	}}}
+
+	merged, err := git.BranchMerged(t.Context(), "/project", "workroom/foo", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged {
+		t.Fatal("expected branch to not be merged")
+	}
+}
+
+func TestGitBranchMergedWhenBranchMissing(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 1").Run()
+	mock := &MockExecutor{Err: exitErr}
+	git := &Git{Executor: mock}
+
+	merged, err := git.BranchMerged(t.Context(), "/project", "workroom/foo", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !merged {
+		t.Fatal("expected missing branch to count as merged")
+	}
+	if len(mock.Calls) != 1 {
+		t.Fatalf("expected only the show-ref call, got %v", mock.Calls)
+	}
+}
+
+func TestGitBranchMergedDefaultTrunk(t *testing.T) {
+	mock := &MockExecutor{Output: "origin/main"}
+	git := &Git{Executor: mock}
+
+	if _, err := git.BranchMerged(t.Context(), "/project", "workroom/foo", ""); err != nil {
+		t.Fatal(err)
+	}
+	last := mock.Calls[len(mock.Calls)-1]
+	if last[len(last)-1] != "origin/main" {
+		t.Fatalf("expected trunk origin/main, got %v", last)
+	}
+}
+
+func TestJJDeleteAbandonsWorkingCopy(t *testing.T) {
+	mock := &MockExecutor{}
+	jj := &JJ{Executor: mock}
+
+	_, err := jj.Delete(t.Context(), "/project", "workroom/foo", "/workrooms/foo", DeleteOptions{DeleteBranch: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.Calls) != 2 {
+		t.Fatalf("expected 2 calls, got %v", mock.Calls)
+	}
+	expected := []string{"jj", "abandon", "workroom/foo@ & empty()"}
+	for i, v := range expected {
+		if mock.Calls[0][i] != v {
+			t.Fatalf("expected %s at position %d, got %s", v, i, mock.Calls[0][i])
+		}
+	}
+	if mock.Calls[1][1] != "workspace" || mock.Calls[1][2] != "forget" {
+		t.Fatalf("expected workspace forget after abandon, got %v", mock.Calls[1])
+	}
+}
+
+func TestGitMove(t *testing.T) {
+	mock := &MockExecutor{}
+	git := &Git{Executor: mock}
+
+	_, err := git.Move(t.Context(), "/project", "workroom/foo", "/workrooms/foo", "/workrooms/project/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"git", "worktree", "move", "/workrooms/foo", "/workrooms/project/foo"}
+	for i, v := range expected {
+		if mock.Calls[0][i] != v {
+			t.Fatalf("expected %s at position %d, got %s", v, i, mock.Calls[0][i])
+		}
+	}
+}
+
+func TestJJMove(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "foo")
+	newPath := filepath.Join(dir, "project-foo")
+	os.MkdirAll(oldPath, 0o755)
+
+	mock := &MockExecutor{}
+	jj := &JJ{Executor: mock}
+
+	if _, err := jj.Move(t.Context(), "/project", "workroom/foo", oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(newPath); err != nil {
+		t.Fatalf("expected workspace at new path: %v", err)
+	}
+	if len(mock.Calls) != 0 {
+		t.Fatalf("expected no jj calls, got %v", mock.Calls)
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "foo")
+	os.MkdirAll(filepath.Join(src, ".jj", "repo"), 0o755)
+	os.WriteFile(filepath.Join(src, ".jj", "repo", "pointer"), []byte("/project/.jj/repo"), 0o644)
+	os.WriteFile(filepath.Join(src, "run.sh"), []byte("#!/bin/sh\n"), 0o755)
+	os.Symlink("run.sh", filepath.Join(src, "link"))
+	dst := filepath.Join(t.TempDir(), "foo")
+
+	if err := copyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dst, ".jj", "repo", "pointer")); string(got) != "/project/.jj/repo" {
+		t.Fatalf("expected nested file to be copied, got %q", got)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "run.sh")); err != nil || info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected mode to be kept, got %v (%v)", info, err)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "run.sh" {
+		t.Fatalf("expected symlink to be kept, got %q (%v)", target, err)
+	}
+}
+
 func TestGitExcludesCurrentDir(t *testing.T) {
 	mock := &MockExecutor{
 		Output: "worktree /project\nHEAD cbace1f\nbranch refs/heads/master\n",
 	}
 	git := &Git{Executor: mock}
 
-	workrooms, err := git.ListWorkrooms("/project")
+	workrooms, err := git.ListWorkrooms(t.Context(), "/project")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -277,13 +542,21 @@ branch refs/heads/master
 	}
 }
 
+func TestGitExcludesMainWorktreeAtAnotherPath(t *testing.T) {
+	output := "worktree /real/project\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree /workrooms/foo\nHEAD abc123\nbranch refs/heads/workroom/foo\n"
+	result := parseGitWorktreeEntries(output, "/link/project")
+	if len(result) != 1 || result[0].Path != "/workrooms/foo" {
+		t.Fatalf("expected only the linked worktree, got %v", result)
+	}
+}
+
 func TestGitWorktreePathsWithSpaces(t *testing.T) {
 	mock := &MockExecutor{
 		Output: "worktree /Users/foo/my project\nHEAD cbace1f043eee2836c7b8494797dfe49f6985716\nbranch refs/heads/master\n\nworktree /Users/foo/my workrooms/feature one\nHEAD abc123\nbranch refs/heads/workroom/feature-one\n",
 	}
 	git := &Git{Executor: mock}
 
-	workrooms, err := git.ListWorkrooms("/Users/foo/my project")
+	workrooms, err := git.ListWorkrooms(t.Context(), "/Users/foo/my project")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -301,8 +574,318 @@ func TestJJListError(t *testing.T) {
 	}
 	jj := &JJ{Executor: mock}
 
-	_, err := jj.ListWorkrooms("/project")
+	_, err := jj.ListWorkrooms(t.Context(), "/project")
 	if err == nil {
 		t.Fatal("expected error")
 	}
 }
+
+func TestJJChangesIgnoresChangesOfOtherWorkspaces(t *testing.T) {
+	var revset string
+	jj := &JJ{Executor: &funcExecutor{fn: func(name string, args []string) (string, error) {
+		if args[0] == "log" {
+			revset = args[slices.Index(args, "-r")+1]
+		}
+		return "", nil
+	}}}
+
+	if _, err := jj.Changes(t.Context(), "/workrooms/foo"); err != nil {
+		t.Fatal(err)
+	}
+	// Unpushed changes in the project, which the workspace was created on, are not its own.
+	if !strings.Contains(revset, "~ ::(working_copies() ~ @)") {
+		t.Fatalf("expected changes of other workspaces to be excluded, got %q", revset)
+	}
+}
+
+func TestParseJJDiffSummary(t *testing.T) {
+	output := "M src/main.rs\nA src/new.rs\nD old.txt\n"
+	files := parseJJDiffSummary(output)
+	if len(files) != 3 || files[0] != "src/main.rs" || files[2] != "old.txt" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+// newGitRepo returns a new Git repo with one commit of the file f, skipping the test when git is
+// not installed.
+func newGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "f"), []byte("one\n"), 0o644)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "f"},
+		{"-c", "user.name=a", "-c", "user.email=a@b", "commit", "-qm", "Initial commit"},
+	} {
+		runGit(t, dir, args...)
+	}
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := (&RealExecutor{}).Run(t.Context(), dir, "git", args...)
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+func TestGitChanges(t *testing.T) {
+	remote := t.TempDir()
+	runGit(t, remote, "init", "-q", "--bare")
+	dir := newGitRepo(t)
+	runGit(t, dir, "remote", "add", "origin", remote)
+	runGit(t, dir, "push", "-q", "origin", "HEAD")
+
+	os.WriteFile(filepath.Join(dir, "g"), []byte("new\n"), 0o644)
+	runGit(t, dir, "add", "g")
+	runGit(t, dir, "-c", "user.name=a", "-c", "user.email=a@b", "commit", "-qm", "Add g")
+	// An unstaged edit sorts first, so its status line starts with a space.
+	os.WriteFile(filepath.Join(dir, "f"), []byte("two\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "h"), []byte("staged\n"), 0o644)
+	runGit(t, dir, "add", "h")
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("scratch\n"), 0o644)
+
+	git := &Git{Executor: &RealExecutor{}}
+	changes, err := git.Changes(t.Context(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changes.Modified, []string{"f", "h"}) || !slices.Equal(changes.Untracked, []string{"notes.txt"}) {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	if got := changes.Summary(); got != "2 modified, 1 untracked, 1 unpushed" {
+		t.Fatalf("unexpected summary %q", got)
+	}
+}
+
+func TestGitChangesUnstagedEditOnly(t *testing.T) {
+	dir := newGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "f"), []byte("two\n"), 0o644)
+
+	git := &Git{Executor: &RealExecutor{}}
+	changes, err := git.Changes(t.Context(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Empty() || !slices.Equal(changes.Modified, []string{"f"}) {
+		t.Fatalf("expected the unstaged edit to be reported, got %+v", changes)
+	}
+}
+
+func TestGitChangesWithoutRemotes(t *testing.T) {
+	git := &Git{Executor: &funcExecutor{fn: func(name string, args []string) (string, error) {
+		if args[0] == "log" {
+			t.Fatal("expected no log call for a repo without remotes")
+		}
+		return "", nil
+	}}}
+
+	changes, err := git.Changes(t.Context(), "/workrooms/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Empty() {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestParseGitStatusBranch(t *testing.T) {
+	tests := map[string]string{
+		"## workroom/foo\n M a.rb":                        "workroom/foo",
+		"## main...origin/main [ahead 1, behind 2]\n":     "main",
+		"## HEAD (no branch)\n":                           "",
+		"## No commits yet on workroom/new\n?? README.md": "workroom/new",
+	}
+	for output, expected := range tests {
+		if got := parseGitStatusBranch(output); got != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, output, got)
+		}
+	}
+}
+
+func TestGitStatus(t *testing.T) {
+	git := &Git{Executor: &funcExecutor{fn: func(name string, args []string) (string, error) {
+		switch args[0] {
+		case "status":
+			return "## workroom/foo\n M app.rb\n?? notes.txt", nil
+		case "log":
+			return "abc123\x1fAdd login form\x1f1700000000", nil
+		case "rev-list":
+			if args[len(args)-1] != "main...HEAD" {
+				t.Fatalf("expected main...HEAD, got %v", args)
+			}
+			return "3\t2", nil
+		}
+		return "", nil
+	}}}
+
+	status, err := git.Status(t.Context(), "/workrooms/foo", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "workroom/foo" {
+		t.Fatalf("expected branch workroom/foo, got %q", status.Branch)
+	}
+	if len(status.DirtyFiles) != 2 {
+		t.Fatalf("expected 2 dirty files, got %v", status.DirtyFiles)
+	}
+	if status.Ahead != 2 || status.Behind != 3 {
+		t.Fatalf("expected ahead 2 behind 3, got ahead %d behind %d", status.Ahead, status.Behind)
+	}
+	if status.CommitSubject != "Add login form" || status.CommitTime.Unix() != 1700000000 {
+		t.Fatalf("unexpected commit %q at %v", status.CommitSubject, status.CommitTime)
+	}
+}
+
+func TestGitStatusDetached(t *testing.T) {
+	git := &Git{Executor: &funcExecutor{fn: func(name string, args []string) (string, error) {
+		switch args[0] {
+		case "status":
+			return "## HEAD (no branch)", nil
+		case "log":
+			return "abc123\x1fRelease v1.0\x1f1700000000", nil
+		}
+		return "", nil
+	}}}
+
+	status, err := git.Status(t.Context(), "/workrooms/foo", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "abc123" {
+		t.Fatalf("expected short hash for detached HEAD, got %q", status.Branch)
+	}
+}
+
+func TestGitWorkspaces(t *testing.T) {
+	mock := &MockExecutor{
+		Output: `worktree /repo.git
+bare
+
+worktree /project
+HEAD cbace1f043eee2836c7b8494797dfe49f6985716
+branch refs/heads/main
+
+worktree /workrooms/project/foo
+HEAD abc123
+branch refs/heads/workroom/foo
+
+worktree /workrooms/project/gone
+HEAD def456
+detached
+prunable gitdir file points to non-existent location
+`,
+	}
+	git := &Git{Executor: mock}
+
+	workspaces, err := git.Workspaces(t.Context(), "/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Workspace{
+		{Path: "/workrooms/project/foo", Branch: "workroom/foo"},
+		{Path: "/workrooms/project/gone", Prunable: true},
+	}
+	if fmt.Sprint(workspaces) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, workspaces)
+	}
+}
+
+func TestGitBranches(t *testing.T) {
+	mock := &MockExecutor{Output: "refs/heads/workroom/foo\nrefs/heads/workroom/bar\n"}
+	git := &Git{Executor: mock}
+
+	branches, err := git.Branches(t.Context(), "/project", "workroom/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(branches) != "[workroom/foo workroom/bar]" {
+		t.Fatalf("unexpected branches %v", branches)
+	}
+	if got := mock.Calls[0][len(mock.Calls[0])-1]; got != "refs/heads/workroom/*" {
+		t.Fatalf("expected branches to be filtered by prefix, got %s", got)
+	}
+}
+
+func TestGitDeleteBranchSkipsMissingBranch(t *testing.T) {
+	mock := &MockExecutor{Err: exec.Command("sh", "-c", "exit 1").Run()}
+	git := &Git{Executor: mock}
+
+	if _, err := git.DeleteBranch(t.Context(), "/project", "workroom/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.Calls) != 1 || mock.Calls[0][1] != "show-ref" {
+		t.Fatalf("expected only the branch lookup, got %v", mock.Calls)
+	}
+}
+
+func TestGitUpstreamGone(t *testing.T) {
+	for _, tt := range []struct {
+		output string
+		want   bool
+	}{
+		{"[gone]\n", true},
+		{"[ahead 1]\n", false},
+		{"\n", false},
+	} {
+		mock := &MockExecutor{Output: tt.output}
+		git := &Git{Executor: mock}
+
+		gone, err := git.UpstreamGone(t.Context(), "/project", "workroom/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gone != tt.want {
+			t.Errorf("output %q: expected gone=%v, got %v", tt.output, tt.want, gone)
+		}
+		if got := mock.Calls[0][len(mock.Calls[0])-1]; got != "refs/heads/workroom/foo" {
+			t.Fatalf("expected the branch ref to be queried, got %s", got)
+		}
+	}
+}
+
+func TestGitLockRelocksLockedWorktree(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 128").Run()
+	var calls []string
+	git := &Git{Executor: &funcExecutor{fn: func(name string, args []string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		if len(calls) == 1 {
+			return "fatal: '/workrooms/foo' is already locked", exitErr
+		}
+		return "", nil
+	}}}
+
+	if _, err := git.Lock(t.Context(), "/project", "/workrooms/foo", "hotfix"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"worktree lock /workrooms/foo --reason hotfix", "worktree unlock /workrooms/foo", "worktree lock /workrooms/foo --reason hotfix"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("expected %v, got %v", want, calls)
+	}
+}
+
+func TestGitUnlockIgnoresUnlockedWorktree(t *testing.T) {
+	mock := &MockExecutor{Output: "fatal: '/workrooms/foo' is not locked", Err: exec.Command("sh", "-c", "exit 128").Run()}
+	git := &Git{Executor: mock}
+
+	if _, err := git.Unlock(t.Context(), "/project", "/workrooms/foo"); err != nil {
+		t.Fatalf("expected an unlocked worktree to be ignored, got %v", err)
+	}
+}
+
+func TestJJForget(t *testing.T) {
+	mock := &MockExecutor{}
+	jj := &JJ{Executor: mock}
+
+	if _, err := jj.Forget(t.Context(), "/project", "workroom/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(mock.Calls) != "[[jj workspace forget workroom/foo]]" {
+		t.Fatalf("unexpected calls %v", mock.Calls)
+	}
+}
@@ -0,0 +1,215 @@
+package workroom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joelmoss/workroom/internal/config"
+	"github.com/joelmoss/workroom/internal/ui"
+	"github.com/joelmoss/workroom/internal/vcs"
+)
+
+// AdoptOptions configures Adopt.
+type AdoptOptions struct {
+	// All adopts every worktree or workspace of the project that is not yet a workroom.
+	All bool
+	// Name is the workroom name to adopt a single worktree or workspace as. Defaults to the name
+	// of its directory for Git, or its workspace name without the branch prefix for JJ.
+	Name string
+	// Move also moves adopted workrooms to the path given by the workrooms_layout.
+	Move bool
+}
+
+// Adopt registers existing Git worktrees or JJ workspaces of the project at dir as workrooms, so
+// that they can be listed and deleted like any other. With opts.All, every one not yet in the
+// config is adopted; otherwise only the one at path.
+func (s *Service) Adopt(dir, path string, opts AdoptOptions) error {
+	if err := s.CheckNotInWorkroom(dir); err != nil {
+		return err
+	}
+	if err := s.detectVCS(dir); err != nil {
+		return err
+	}
+	settings, err := s.Config.Resolve(dir)
+	if err != nil {
+		return err
+	}
+
+	if !opts.All {
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		w, err := s.VCS.WorkspaceAt(s.ctx(), dir, path)
+		if err != nil {
+			return err
+		}
+		if w == nil {
+			if s.VCS.Type() == vcs.TypeJJ {
+				return fmt.Errorf("%w: no %s of this project at %s", ErrJJWorkspaceNotFound, s.VCS.Label(), ui.DisplayPath(path))
+			}
+			return fmt.Errorf("%w: no %s of this project at %s", ErrGitWorktreeNotFound, s.VCS.Label(), ui.DisplayPath(path))
+		}
+		name := opts.Name
+		if name == "" {
+			name = s.adoptName(*w, settings)
+		}
+		return s.adoptOne(dir, name, *w, settings, opts.Move)
+	}
+
+	workspaces, err := s.VCS.Workspaces(s.ctx(), dir)
+	if err != nil {
+		return err
+	}
+	_, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+
+	adopted := 0
+	for _, w := range workspaces {
+		if project != nil && isRecorded(project, w, settings.BranchPrefix) {
+			continue
+		}
+		name := s.adoptName(w, settings)
+		if w.Path == "" {
+			// JJ does not report where a workspace is, so look where the layout would put it.
+			w.Path, err = s.workroomPath(dir, name)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := os.Stat(w.Path); err != nil || w.Prunable {
+			s.sayColor(fmt.Sprintf("Skipping %s: directory %s not found.", adoptLabel(w), ui.DisplayPath(w.Path)), "yellow")
+			continue
+		}
+		if err := s.adoptOne(dir, name, w, settings, opts.Move); err != nil {
+			s.sayColor(fmt.Sprintf("Skipping %s: %v", adoptLabel(w), err), "yellow")
+			continue
+		}
+		adopted++
+	}
+
+	if adopted == 0 {
+		s.say(fmt.Sprintf("No %ss to adopt.", s.VCS.Label()))
+	}
+	return nil
+}
+
+func (s *Service) adoptOne(dir, name string, w vcs.Workspace, settings *config.Resolved, move bool) error {
+	if !validNameRe.MatchString(name) {
+		return fmt.Errorf("%w: %q. Choose another name with --name", ErrInvalidName, name)
+	}
+	_, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+	if project != nil {
+		if _, ok := project.Workrooms[name]; ok {
+			return fmt.Errorf("workroom '%s' already exists. Choose another name with --name", name)
+		}
+		if isRecorded(project, w, settings.BranchPrefix) {
+			return fmt.Errorf("%s is already a workroom", adoptLabel(w))
+		}
+	}
+
+	path := w.Path
+	if move {
+		newPath, err := s.workroomPath(dir, name)
+		if err != nil {
+			return err
+		}
+		if newPath != path {
+			if _, err := os.Stat(newPath); err == nil {
+				return fmt.Errorf("%w: '%s' already exists", ErrDirExists, ui.DisplayPath(newPath))
+			}
+			s.sayStatus("move", fmt.Sprintf("%s -> %s", path, newPath))
+			if !s.Pretend {
+				if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+					return err
+				}
+				if out, err := s.VCS.Move(s.ctx(), dir, workspaceVCSName(w), path, newPath); err != nil {
+					return fmt.Errorf("failed to move %s: %w: %s", ui.DisplayPath(path), err, out)
+				}
+			}
+			path = newPath
+		}
+	}
+
+	s.sayStatus("adopt", fmt.Sprintf("%s as '%s'", path, name))
+	if !s.Pretend {
+		// The branch or workspace was the user's before it was adopted, so it is theirs to delete.
+		entry := config.WorkroomEntry{Path: path, Branch: workspaceVCSName(w), ExternalBranch: true}
+		if err := s.Config.AddWorkroomEntry(dir, string(s.VCS.Type()), name, entry); err != nil {
+			return err
+		}
+	}
+	s.sayColor(fmt.Sprintf("Adopted %s as workroom '%s' at %s.", adoptLabel(w), name, ui.DisplayPath(path)), "green")
+	return nil
+}
+
+// Release removes the named workroom of the project at dir from the config, leaving its worktree
+// or workspace, directory and branch alone. It can be adopted again later. A locked workroom must
+// be unlocked first, so that its Git worktree lock is not left behind.
+func (s *Service) Release(dir, name string) error {
+	projectPath, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+	if project == nil || project.Workrooms[name] == nil {
+		return fmt.Errorf("%w: '%s'", ErrWorkroomNotFound, name)
+	}
+	entry := project.Workrooms[name]
+	if err := lockedError(name, entry.Lock); err != nil {
+		return err
+	}
+
+	s.sayStatus("release", name)
+	if !s.Pretend {
+		if err := s.Config.RemoveWorkroom(projectPath, name); err != nil {
+			return err
+		}
+	}
+	s.sayColor(fmt.Sprintf("Released workroom '%s'. Its files remain at %s.", name, ui.DisplayPath(entry.Path)), "green")
+	return nil
+}
+
+// adoptName returns the default workroom name for w: its directory name for Git, or its
+// workspace name without the branch prefix for JJ.
+func (s *Service) adoptName(w vcs.Workspace, settings *config.Resolved) string {
+	if w.Name != "" {
+		return strings.TrimPrefix(w.Name, settings.BranchPrefix)
+	}
+	return filepath.Base(w.Path)
+}
+
+// isRecorded reports whether w is already a workroom of project. Entries without a recorded
+// branch are assumed to use prefix.
+func isRecorded(project *config.Project, w vcs.Workspace, prefix string) bool {
+	for name, entry := range project.Workrooms {
+		if w.Name != "" && entryVCSName(entry, prefix, name) == w.Name {
+			return true
+		}
+		if w.Path != "" && vcs.SamePath(entry.Path, w.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceVCSName returns the JJ workspace name or Git branch of w.
+func workspaceVCSName(w vcs.Workspace) string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return w.Branch
+}
+
+func adoptLabel(w vcs.Workspace) string {
+	if w.Name != "" {
+		return fmt.Sprintf("workspace '%s'", w.Name)
+	}
+	return "worktree " + ui.DisplayPath(w.Path)
+}
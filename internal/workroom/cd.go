@@ -0,0 +1,138 @@
+package workroom
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joelmoss/workroom/internal/ui"
+)
+
+// Cd changes the calling shell's directory to the named workroom. When name is empty, the user
+// picks one interactively. From inside a project or one of its workrooms, only that project's
+// workrooms are considered; otherwise all workrooms are.
+//
+// Without shell integration, the path is printed instead, so `cd "$(workroom cd NAME)"` works.
+func (s *Service) Cd(dir, name string) error {
+	infos, err := s.workroomCandidates(dir)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		return fmt.Errorf("%w: no workrooms found", ErrWorkroomNotFound)
+	}
+
+	var info WorkroomInfo
+	if name == "" {
+		info, err = s.pickWorkroom(infos)
+		if err != nil {
+			return err
+		}
+		if info.Name == "" {
+			return nil
+		}
+	} else {
+		info, err = findWorkroom(infos, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(info.Path); err != nil {
+		return fmt.Errorf("workroom '%s' directory %s not found", info.Name, ui.DisplayPath(info.Path))
+	}
+
+	if s.CdFile == "" {
+		s.say(info.Path)
+		return nil
+	}
+	return s.changeDir(info)
+}
+
+// workroomCandidates returns the workrooms a command run from dir can refer to by name: those of
+// the current project when dir is a project or one of its workrooms, otherwise all of them.
+func (s *Service) workroomCandidates(dir string) ([]WorkroomInfo, error) {
+	projectPath, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return nil, err
+	}
+	if project != nil {
+		return s.workroomInfos(projectPath, project), nil
+	}
+
+	projects, err := s.Config.ProjectsWithWorkrooms()
+	if err != nil {
+		return nil, err
+	}
+	var infos []WorkroomInfo
+	for _, path := range sortedKeys(projects) {
+		infos = append(infos, s.workroomInfos(path, projects[path])...)
+	}
+	return infos, nil
+}
+
+// findWorkroom returns the workroom in infos with the given name.
+func findWorkroom(infos []WorkroomInfo, name string) (WorkroomInfo, error) {
+	var matches []WorkroomInfo
+	for _, info := range infos {
+		if info.Name == name {
+			matches = append(matches, info)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return WorkroomInfo{}, fmt.Errorf("%w: '%s'", ErrWorkroomNotFound, name)
+	case 1:
+		return matches[0], nil
+	default:
+		return WorkroomInfo{}, fmt.Errorf("workroom '%s' exists in more than one project. Run this command from the project directory", name)
+	}
+}
+
+// pickWorkroom prompts the user to select one of infos. Workrooms are labelled with their project
+// when they come from more than one. A zero WorkroomInfo is returned when nothing is selected.
+func (s *Service) pickWorkroom(infos []WorkroomInfo) (WorkroomInfo, error) {
+	multiProject := false
+	for _, info := range infos {
+		if info.Project != infos[0].Project {
+			multiProject = true
+			break
+		}
+	}
+
+	labels := make([]string, len(infos))
+	byLabel := make(map[string]WorkroomInfo, len(infos))
+	for i, info := range infos {
+		labels[i] = info.Name
+		if multiProject {
+			labels[i] = fmt.Sprintf("%s (%s)", info.Name, ui.DisplayPath(info.Project))
+		}
+		byLabel[labels[i]] = info
+	}
+
+	selected, err := s.SelectFn("Which workroom do you want to go to?", labels)
+	if err != nil {
+		return WorkroomInfo{}, err
+	}
+	return byLabel[selected], nil
+}
+
+// changeDir runs the on_enter hook of the workroom, then asks the shell integration to change to
+// its directory. The directory is not changed if the hook fails. Without shell integration, it
+// explains how to enable it.
+func (s *Service) changeDir(info WorkroomInfo) error {
+	if s.CdFile == "" {
+		s.sayColor("Shell integration is not enabled, so the directory was not changed. See `workroom shell-init --help`.", "yellow")
+		return nil
+	}
+
+	settings, err := s.Config.Resolve(info.Project)
+	if err != nil {
+		return err
+	}
+	if err := s.runHook("on_enter", info.Project, info.Path, info.Name, settings, workroomVars(info.Path, info.Branch, info.VCS)); err != nil {
+		return err
+	}
+
+	s.sayStatus("cd", info.Path)
+	return os.WriteFile(s.CdFile, []byte(info.Path), 0o600)
+}
@@ -0,0 +1,14 @@
+//go:build darwin
+
+package workroom
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst as a copy-on-write clone of src, on APFS. The clone keeps the mode of src.
+func cloneFile(src, dst string, _ fs.FileMode) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package workroom
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// cloneFile is not supported on this platform, so reflinks are always copies.
+func cloneFile(_, _ string, _ fs.FileMode) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,205 @@
+package workroom
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joelmoss/workroom/internal/config"
+	"github.com/joelmoss/workroom/internal/errs"
+	"github.com/joelmoss/workroom/internal/ui"
+)
+
+// ShowConfig prints the global config file as JSON. With resolved, it prints the effective
+// configuration for the project at cwd instead, after merging the built-in defaults, the global
+// config and the project's .workroom.toml or .workroom.json.
+func (s *Service) ShowConfig(cwd string, resolved bool) error {
+	var v any
+	if resolved {
+		projectPath, _, err := s.Config.FindCurrentProject(cwd)
+		if err != nil {
+			return err
+		}
+		if v, err = s.Config.Resolve(projectPath); err != nil {
+			return err
+		}
+	} else {
+		f, err := s.Config.Read()
+		if err != nil {
+			return err
+		}
+		v = f
+	}
+
+	enc := json.NewEncoder(s.output())
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// ConfigGet prints the value of a dotted key in the config file for scope, one line per item for
+// list settings. With an empty scope, it prints the resolved value for the project at cwd.
+func (s *Service) ConfigGet(cwd string, scope config.Scope, key string) error {
+	if _, err := config.LookupSetting(key, scope); err != nil {
+		return err
+	}
+	values, err := s.configValues(cwd, scope)
+	if err != nil {
+		return err
+	}
+	v, ok := values[key]
+	if !ok {
+		return errs.NewExitError(1, "%s is not set", key)
+	}
+	if list, ok := v.([]string); ok {
+		for _, item := range list {
+			s.say(item)
+		}
+		return nil
+	}
+	s.say(v.(string))
+	return nil
+}
+
+// ConfigList prints every setting in the config file for scope as key=value lines. With an empty
+// scope, it prints the resolved settings for the project at cwd.
+func (s *Service) ConfigList(cwd string, scope config.Scope) error {
+	values, err := s.configValues(cwd, scope)
+	if err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(values) {
+		v := values[key]
+		if list, ok := v.([]string); ok {
+			v = strings.Join(list, ",")
+		}
+		s.say(fmt.Sprintf("%s=%s", key, v))
+	}
+	return nil
+}
+
+// ConfigSet sets a dotted key in the config file for scope. List settings take any number of
+// values.
+func (s *Service) ConfigSet(cwd string, scope config.Scope, key string, values []string) error {
+	projectPath, err := s.configProject(cwd)
+	if err != nil {
+		return err
+	}
+	path := s.Config.ScopePath(scope, projectPath)
+	s.sayStatus("set", fmt.Sprintf("%s in %s", key, path))
+	if s.Pretend {
+		_, err := config.LookupSetting(key, scope)
+		return err
+	}
+	return s.Config.SetValue(scope, projectPath, key, values)
+}
+
+// ConfigUnset removes a dotted key from the config file for scope.
+func (s *Service) ConfigUnset(cwd string, scope config.Scope, key string) error {
+	projectPath, err := s.configProject(cwd)
+	if err != nil {
+		return err
+	}
+	path := s.Config.ScopePath(scope, projectPath)
+	s.sayStatus("unset", fmt.Sprintf("%s in %s", key, path))
+	if s.Pretend {
+		_, err := config.LookupSetting(key, scope)
+		return err
+	}
+	return s.Config.UnsetValue(scope, projectPath, key)
+}
+
+// ConfigPath prints the path of the config file for scope.
+func (s *Service) ConfigPath(cwd string, scope config.Scope) error {
+	projectPath, err := s.configProject(cwd)
+	if err != nil {
+		return err
+	}
+	s.say(s.Config.ScopePath(scope, projectPath))
+	return nil
+}
+
+// ConfigEdit opens a copy of the config file for scope in the user's editor. The copy is
+// validated once the editor exits, and only replaces the file when it is valid. When it is not,
+// the user can edit it again or discard their changes.
+func (s *Service) ConfigEdit(cwd string, scope config.Scope) error {
+	projectPath, err := s.configProject(cwd)
+	if err != nil {
+		return err
+	}
+	path := s.Config.ScopePath(scope, projectPath)
+
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if original == nil && scope == config.ScopeGlobal {
+		original = []byte("{\n  \"schema_version\": 1\n}\n")
+	}
+
+	tmp, err := os.CreateTemp("", "workroom-config-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(original)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err := s.EditFn(tmp.Name()); err != nil {
+			return err
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, original) {
+			s.say("No changes made.")
+			return nil
+		}
+
+		if s.Pretend {
+			s.sayStatus("write", path)
+			return nil
+		}
+		err = s.Config.WriteScope(scope, projectPath, edited)
+		if err == nil {
+			s.sayColor(fmt.Sprintf("Saved %s.", ui.DisplayPath(path)), "green")
+			return nil
+		}
+		if !errors.Is(err, errs.ErrInvalidConfig) {
+			return err
+		}
+
+		s.sayColor(err.Error(), "red")
+		again, confirmErr := s.ConfirmFn("Edit again? Your changes are discarded otherwise.")
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !again {
+			return err
+		}
+	}
+}
+
+func (s *Service) configValues(cwd string, scope config.Scope) (map[string]any, error) {
+	projectPath, err := s.configProject(cwd)
+	if err != nil {
+		return nil, err
+	}
+	return s.Config.Values(scope, projectPath)
+}
+
+// configProject returns the path of the project at cwd, which may be one of its workrooms.
+func (s *Service) configProject(cwd string) (string, error) {
+	projectPath, _, err := s.Config.FindCurrentProject(cwd)
+	return projectPath, err
+}
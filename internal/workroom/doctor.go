@@ -0,0 +1,321 @@
+package workroom
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/joelmoss/workroom/internal/config"
+	"github.com/joelmoss/workroom/internal/errs"
+	"github.com/joelmoss/workroom/internal/ui"
+	"github.com/joelmoss/workroom/internal/vcs"
+)
+
+// problem is an inconsistency between the config, the VCS and the disk, found by Doctor.
+type problem struct {
+	subject string // workroom name, branch or path the problem is about
+	desc    string
+	fix     string       // what repair does, or empty when it must be repaired by hand
+	repair  func() error // nil when fix is empty
+}
+
+// Doctor cross-checks the workrooms recorded in the config against the worktrees or workspaces
+// known to each project's VCS, and the directories on disk. It reports stale config entries,
+// orphan worktrees and workspaces, directories without VCS metadata and lingering workroom
+// branches. With fix, it repairs what it safely can; in pretend mode it only shows the planned
+// repairs. It returns an ExitError when problems remain.
+func (s *Service) Doctor(cwd string, fix bool) error {
+	f, err := s.Config.Read()
+	if err != nil {
+		return err
+	}
+	// The current project is checked too, even when it has no workrooms in the config.
+	projects := f.Projects
+	if projectPath, project, err := s.Config.FindCurrentProject(cwd); err == nil && project == nil && s.CheckNotInWorkroom(cwd) == nil {
+		if _, err := s.projectVCS(projectPath); err == nil {
+			projects[projectPath] = &config.Project{Workrooms: map[string]*config.WorkroomEntry{}}
+		}
+	}
+
+	var found, remaining int
+	known := map[string]bool{}
+	for _, project := range projects {
+		for _, entry := range project.Workrooms {
+			known[entry.Path] = true
+		}
+	}
+
+	for _, projectPath := range sortedKeys(projects) {
+		problems := s.diagnoseProject(projectPath, projects[projectPath], known)
+		if len(problems) == 0 {
+			continue
+		}
+
+		s.say(ui.DisplayPath(projectPath))
+		for _, p := range problems {
+			found++
+			line := fmt.Sprintf("  %s: %s", p.subject, p.desc)
+			switch {
+			case p.repair == nil:
+				s.sayColor(line+" (repair by hand)", "red")
+				remaining++
+			case !fix:
+				s.sayColor(fmt.Sprintf("%s (fix: %s)", line, p.fix), "yellow")
+				remaining++
+			case s.Pretend:
+				s.sayColor(fmt.Sprintf("%s (would %s)", line, p.fix), "yellow")
+			default:
+				if err := p.repair(); err != nil {
+					s.sayColor(fmt.Sprintf("%s (failed to %s: %v)", line, p.fix, err), "red")
+					remaining++
+					continue
+				}
+				s.sayColor(fmt.Sprintf("%s (fixed: %s)", line, p.fix), "green")
+			}
+		}
+	}
+
+	if found == 0 {
+		s.sayColor("No problems found.", "green")
+		return nil
+	}
+	if remaining == 0 {
+		return nil
+	}
+	if !fix {
+		s.say("\nRun `workroom doctor --fix` to repair what can be repaired automatically.")
+	}
+	return errs.NewExitError(1, "%d of %d problems remain", remaining, found)
+}
+
+// diagnoseProject returns the problems of a project. known holds the paths of every workroom in
+// the config, and of directories already reported, so that they are not reported again.
+func (s *Service) diagnoseProject(projectPath string, project *config.Project, known map[string]bool) []problem {
+	removeEntry := func(name string) func() error {
+		return func() error { return s.Config.RemoveWorkroom(projectPath, name) }
+	}
+
+	if _, err := os.Stat(projectPath); os.IsNotExist(err) {
+		var problems []problem
+		for _, name := range sortedKeys(project.Workrooms) {
+			problems = append(problems, problem{
+				subject: name,
+				desc:    "project directory not found",
+				fix:     "remove it from the config",
+				repair:  removeEntry(name),
+			})
+		}
+		return problems
+	}
+
+	v, err := s.projectVCS(projectPath)
+	if err != nil {
+		return []problem{{subject: ui.DisplayPath(projectPath), desc: err.Error()}}
+	}
+	workspaces, err := v.Workspaces(s.ctx(), projectPath)
+	if err != nil {
+		return []problem{{subject: ui.DisplayPath(projectPath), desc: fmt.Sprintf("cannot list %ss: %v", v.Label(), err)}}
+	}
+	settings, err := s.Config.Resolve(projectPath)
+	if err != nil {
+		return []problem{{subject: ui.DisplayPath(projectPath), desc: err.Error()}}
+	}
+	vcsType := string(v.Type())
+	label := v.Label()
+
+	var problems []problem
+	matched := make([]bool, len(workspaces))
+	inUse := map[string]bool{}
+
+	for _, name := range sortedKeys(project.Workrooms) {
+		entry := project.Workrooms[name]
+		vcsName := entryVCSName(entry, settings.BranchPrefix, name)
+		inUse[vcsName] = true
+
+		i := slices.IndexFunc(workspaces, func(w vcs.Workspace) bool {
+			if v.Type() == vcs.TypeJJ {
+				return w.Name == vcsName
+			}
+			return vcs.SamePath(w.Path, entry.Path)
+		})
+		if i >= 0 {
+			matched[i] = true
+		}
+		_, statErr := os.Stat(entry.Path)
+		dirExists := statErr == nil
+
+		switch {
+		case !dirExists && i < 0:
+			problems = append(problems, problem{
+				subject: name,
+				desc:    fmt.Sprintf("stale config entry: its directory and %s are gone", label),
+				fix:     "remove it from the config",
+				repair:  removeEntry(name),
+			})
+		case !dirExists:
+			problems = append(problems, problem{
+				subject: name,
+				desc:    fmt.Sprintf("directory %s not found, but its %s is still registered", ui.DisplayPath(entry.Path), label),
+				fix:     fmt.Sprintf("forget the %s and remove it from the config", label),
+				repair: func() error {
+					if out, err := v.Forget(s.ctx(), projectPath, vcsName); err != nil {
+						return fmt.Errorf("%w: %s", err, out)
+					}
+					return s.Config.RemoveWorkroom(projectPath, name)
+				},
+			})
+		case i < 0:
+			problems = append(problems, problem{
+				subject: name,
+				desc:    fmt.Sprintf("directory %s is not a %s", ui.DisplayPath(entry.Path), label),
+				fix:     "remove it from the config, keeping the directory",
+				repair:  removeEntry(name),
+			})
+		}
+		if n := len(problems); n > 0 && problems[n-1].subject == name && entry.Lock != nil {
+			p := &problems[n-1]
+			p.desc += fmt.Sprintf(". It is locked, so unlock it with `workroom unlock %s` to repair it", name)
+			p.fix, p.repair = "", nil
+		}
+	}
+
+	for i, w := range workspaces {
+		if matched[i] {
+			continue
+		}
+		if w.Branch != "" {
+			inUse[w.Branch] = true
+		}
+		if p, ok := s.diagnoseOrphan(projectPath, project, w, v, settings, vcsType); ok {
+			problems = append(problems, p)
+		}
+	}
+
+	problems = append(problems, s.diagnoseStrayDirs(projectPath, known)...)
+
+	if settings.BranchPrefix != "" {
+		branches, _ := v.Branches(s.ctx(), projectPath, settings.BranchPrefix)
+		for _, branch := range branches {
+			if inUse[branch] {
+				continue
+			}
+			merged, err := v.BranchMerged(s.ctx(), projectPath, branch, settings.Trunk)
+			if err != nil || !merged {
+				problems = append(problems, problem{
+					subject: branch,
+					desc:    fmt.Sprintf("branch is left over from a deleted workroom, and is not merged into trunk. Delete it with `git branch -D %s`", branch),
+				})
+				continue
+			}
+			problems = append(problems, problem{
+				subject: branch,
+				desc:    "merged branch is left over from a deleted workroom",
+				fix:     "delete the branch",
+				repair: func() error {
+					if out, err := v.DeleteBranch(s.ctx(), projectPath, branch); err != nil {
+						return fmt.Errorf("%w: %s", err, out)
+					}
+					return nil
+				},
+			})
+		}
+	}
+
+	return problems
+}
+
+// diagnoseOrphan checks a worktree or workspace that has no config entry. Only those that look
+// like workrooms, by their branch or workspace name or their location, are reported.
+func (s *Service) diagnoseOrphan(projectPath string, project *config.Project, w vcs.Workspace, v vcs.VCS, settings *config.Resolved, vcsType string) (problem, bool) {
+	var name, path, vcsName string
+	if v.Type() == vcs.TypeJJ {
+		var ok bool
+		name, ok = strings.CutPrefix(w.Name, settings.BranchPrefix)
+		if !ok || settings.BranchPrefix == "" {
+			return problem{}, false
+		}
+		path, _ = s.workroomPath(projectPath, name)
+		vcsName = w.Name
+	} else {
+		name, path, vcsName = filepath.Base(w.Path), w.Path, w.Branch
+		expected, _ := s.workroomPath(projectPath, name)
+		hasPrefix := settings.BranchPrefix != "" && strings.HasPrefix(w.Branch, settings.BranchPrefix)
+		if !hasPrefix && !vcs.SamePath(expected, w.Path) {
+			return problem{}, false
+		}
+	}
+
+	subject := cmp.Or(vcsName, ui.DisplayPath(path))
+	if _, err := os.Stat(path); err != nil || w.Prunable {
+		return problem{
+			subject: subject,
+			desc:    fmt.Sprintf("orphan %s whose directory is gone", v.Label()),
+			fix:     fmt.Sprintf("forget the %s", v.Label()),
+			repair: func() error {
+				if out, err := v.Forget(s.ctx(), projectPath, vcsName); err != nil {
+					return fmt.Errorf("%w: %s", err, out)
+				}
+				return nil
+			},
+		}, true
+	}
+
+	desc := fmt.Sprintf("orphan %s at %s is not in the config", v.Label(), ui.DisplayPath(path))
+	if _, taken := project.Workrooms[name]; taken || !validNameRe.MatchString(name) {
+		return problem{subject: subject, desc: desc}, true
+	}
+	return problem{
+		subject: subject,
+		desc:    desc,
+		fix:     fmt.Sprintf("register it as workroom '%s'", name),
+		repair: func() error {
+			return s.Config.AddWorkroomEntry(projectPath, vcsType, name, config.WorkroomEntry{
+				Path:           path,
+				Branch:         vcsName,
+				ExternalBranch: vcsName != settings.BranchPrefix+name,
+			})
+		},
+	}, true
+}
+
+// diagnoseStrayDirs reports directories where the project's workrooms are created that are
+// neither in the config nor under version control. It only looks when the workrooms_layout puts
+// each workroom in a directory of its own, named after the workroom.
+func (s *Service) diagnoseStrayDirs(projectPath string, known map[string]bool) []problem {
+	const probe = "workroom-doctor-probe"
+	path, err := s.workroomPath(projectPath, probe)
+	if err != nil || filepath.Base(path) != probe {
+		return nil
+	}
+	parent := filepath.Dir(path)
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return nil
+	}
+
+	var problems []problem
+	for _, e := range entries {
+		dir := filepath.Join(parent, e.Name())
+		if !e.IsDir() || known[dir] || hasVCSMetadata(dir) {
+			continue
+		}
+		known[dir] = true
+		problems = append(problems, problem{
+			subject: ui.DisplayPath(dir),
+			desc:    "directory has no VCS metadata and is not a workroom. Remove it, or move it out of the way",
+		})
+	}
+	return problems
+}
+
+func hasVCSMetadata(dir string) bool {
+	for _, name := range []string{".git", ".jj"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
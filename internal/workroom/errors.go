@@ -12,6 +12,15 @@ var (
 	ErrGitWorktreeExists   = errs.ErrGitWorktreeExists
 	ErrJJWorkspaceNotFound = errs.ErrJJWorkspaceNotFound
 	ErrGitWorktreeNotFound = errs.ErrGitWorktreeNotFound
+	ErrFromAndBase         = errs.ErrFromAndBase
+	ErrBranchNotMerged     = errs.ErrBranchNotMerged
+	ErrUnsavedChanges      = errs.ErrUnsavedChanges
+	ErrWorkroomNotFound    = errs.ErrWorkroomNotFound
+	ErrWorkroomLocked      = errs.ErrWorkroomLocked
+	ErrInvalidConfig       = errs.ErrInvalidConfig
 	ErrSetup               = errs.ErrSetup
 	ErrTeardown            = errs.ErrTeardown
+	ErrHook                = errs.ErrHook
+	ErrHookTimeout         = errs.ErrHookTimeout
+	ErrInterrupted         = errs.ErrInterrupted
 )
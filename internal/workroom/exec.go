@@ -0,0 +1,195 @@
+package workroom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/joelmoss/workroom/internal/errs"
+	"github.com/joelmoss/workroom/internal/script"
+	"github.com/joelmoss/workroom/internal/ui"
+)
+
+// DefaultExecJobs is the number of workrooms Exec runs a command in at once with --all.
+const DefaultExecJobs = 4
+
+// ExecOptions configures an Exec call.
+type ExecOptions struct {
+	All  bool // run in every workroom of the current project
+	Jobs int  // maximum number of workrooms to run in at once when All is set
+}
+
+// execResult is the outcome of running a command in a single workroom.
+type execResult struct {
+	info     WorkroomInfo
+	exitCode int
+	err      error
+	skipped  bool
+}
+
+// Exec runs a command inside the named workroom, or with opts.All, inside every workroom of the
+// current project in parallel. The command gets the same environment as setup and teardown
+// scripts. If the command fails in any workroom, an *errs.ExitError is returned.
+func (s *Service) Exec(dir, name string, command []string, opts ExecOptions) error {
+	if len(command) == 0 {
+		return errors.New("no command given")
+	}
+
+	if !opts.All {
+		infos, err := s.workroomCandidates(dir)
+		if err != nil {
+			return err
+		}
+		info, err := findWorkroom(infos, name)
+		if err != nil {
+			return err
+		}
+		return s.execOne(info, command, s.projectEnv(info.Project))
+	}
+
+	projectPath, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+	if project == nil {
+		return fmt.Errorf("%w: no workrooms found for this project", ErrWorkroomNotFound)
+	}
+	infos := s.workroomInfos(projectPath, project)
+	if len(infos) == 0 {
+		return fmt.Errorf("%w: no workrooms found for this project", ErrWorkroomNotFound)
+	}
+	return s.execAll(infos, command, opts.Jobs, s.projectEnv(projectPath))
+}
+
+func (s *Service) execOne(info WorkroomInfo, command []string, env map[string]string) error {
+	if _, err := os.Stat(info.Path); err != nil {
+		return fmt.Errorf("workroom '%s' directory %s not found", info.Name, ui.DisplayPath(info.Path))
+	}
+
+	s.sayStatus("exec", fmt.Sprintf("%s in %s", strings.Join(command, " "), info.Path))
+	if s.Pretend {
+		return nil
+	}
+
+	// The command is interactive, so it gets Ctrl-C itself, and decides what to do about it.
+	cmd := execCommand(context.Background(), info, command, env)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = s.output()
+	cmd.Stderr = os.Stderr
+	if code, err := runExec(cmd); err != nil {
+		return &errs.ExitError{Code: code, Err: fmt.Errorf("command failed in workroom '%s': %w", info.Name, err)}
+	}
+	return nil
+}
+
+func (s *Service) execAll(infos []WorkroomInfo, command []string, jobs int, env map[string]string) error {
+	if jobs < 1 {
+		jobs = DefaultExecJobs
+	}
+
+	width := 0
+	for _, info := range infos {
+		width = max(width, len(info.Name))
+	}
+
+	results := make([]execResult, len(infos))
+	var mu sync.Mutex
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, info := range infos {
+		results[i].info = info
+		if _, err := os.Stat(info.Path); err != nil {
+			results[i].skipped = true
+			continue
+		}
+
+		s.sayStatus("exec", fmt.Sprintf("%s in %s", strings.Join(command, " "), info.Path))
+		if s.Pretend {
+			continue
+		}
+
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			out := ui.NewPrefixWriter(s.output(), &mu, ui.Blue(fmt.Sprintf("%-*s | ", width, info.Name)))
+			cmd := execCommand(s.ctx(), info, command, env)
+			cmd.Stdout = out
+			cmd.Stderr = out
+			results[i].exitCode, results[i].err = runExec(cmd)
+			out.Flush()
+		})
+	}
+	wg.Wait()
+
+	if s.Pretend {
+		return nil
+	}
+	return s.reportExecResults(results)
+}
+
+// reportExecResults prints the outcome for each workroom, and returns an *errs.ExitError if the
+// command failed in any of them.
+func (s *Service) reportExecResults(results []execResult) error {
+	var rows [][]string
+	failed, succeeded := 0, 0
+	for _, r := range results {
+		var outcome string
+		switch {
+		case r.skipped:
+			outcome = ui.Yellow("skipped (directory not found)")
+		case r.err != nil:
+			failed++
+			outcome = ui.Red(fmt.Sprintf("failed (%v)", r.err))
+		default:
+			succeeded++
+			outcome = ui.Green("ok")
+		}
+		rows = append(rows, []string{ui.Bold(r.info.Name), outcome})
+	}
+
+	s.say("")
+	ui.PrintTable(s.output(), rows, 2)
+	s.say("")
+
+	summary := fmt.Sprintf("Succeeded in %d of %d workrooms.", succeeded, len(results))
+	if failed > 0 {
+		s.sayColor(summary, "red")
+		return errs.NewExitError(1, "command failed in %d of %d workrooms", failed, len(results))
+	}
+	s.sayColor(summary, "green")
+	return nil
+}
+
+func execCommand(ctx context.Context, info WorkroomInfo, command []string, env map[string]string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = info.Path
+	cmd.Env = script.Env(info.Name, info.Project, env)
+	return cmd
+}
+
+// projectEnv returns the env configured for the project at dir.
+func (s *Service) projectEnv(dir string) map[string]string {
+	settings, err := s.Config.Resolve(dir)
+	if err != nil {
+		return nil
+	}
+	return settings.Env
+}
+
+// runExec runs cmd, returning the exit code to propagate when it fails.
+func runExec(cmd *exec.Cmd) (int, error) {
+	err := cmd.Run()
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode(), err
+	}
+	return 1, err
+}
@@ -0,0 +1,204 @@
+package workroom
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joelmoss/workroom/internal/config"
+)
+
+// projectFile is an entry of the project config that brings files from the project into a new
+// workroom.
+type projectFile struct {
+	mode    string
+	pattern string
+	literal bool // pattern is a path, as given to copy and symlink, rather than a glob
+}
+
+// linkProjectFiles brings the files listed in the project config from the project into a new
+// workroom, each in the mode given by its entry. They are typically files that are not checked in,
+// such as .env. Entries of copy and symlink are paths brought in just as include entries with
+// those modes are, and all are applied in order: copy, then symlink, then include. What the
+// workroom already has, because it is checked out or an earlier entry brought it in, is never
+// replaced. Files missing from the project, and patterns that match nothing, are skipped with a
+// warning.
+func (s *Service) linkProjectFiles(dir, wrPath string, settings *config.Resolved) error {
+	var files []projectFile
+	for _, rel := range settings.Copy {
+		files = append(files, projectFile{mode: "copy", pattern: rel, literal: true})
+	}
+	for _, rel := range settings.Symlink {
+		files = append(files, projectFile{mode: "symlink", pattern: rel, literal: true})
+	}
+	for _, entry := range settings.Include {
+		mode, pattern, err := config.ParseInclude(entry)
+		if err != nil {
+			return err
+		}
+		files = append(files, projectFile{mode: mode, pattern: pattern})
+	}
+
+	for _, f := range files {
+		matches, err := f.matches(dir)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			if f.literal {
+				s.sayColor(fmt.Sprintf("Warning: not %sing %s, as it does not exist in the project.", f.mode, f.pattern), "yellow")
+			} else {
+				s.sayColor(fmt.Sprintf("Warning: nothing in the project matches include pattern %q.", f.pattern), "yellow")
+			}
+			continue
+		}
+		for _, m := range matches {
+			// The project's own repo is never brought in, even by a pattern such as ".*".
+			if first, _, _ := strings.Cut(m, "/"); first == ".git" || first == ".jj" {
+				continue
+			}
+			if err := s.includePath(dir, wrPath, filepath.FromSlash(m), f.mode); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matches returns the slash-separated paths in the project at dir that f brings in.
+func (f projectFile) matches(dir string) ([]string, error) {
+	if f.literal {
+		if _, err := os.Lstat(filepath.Join(dir, f.pattern)); err != nil {
+			return nil, nil
+		}
+		return []string{filepath.ToSlash(filepath.Clean(f.pattern))}, nil
+	}
+	matches, err := fs.Glob(os.DirFS(dir), filepath.ToSlash(f.pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %q: %w", f.pattern, err)
+	}
+	return matches, nil
+}
+
+// includePath brings the file or directory at rel in the project at dir into the workroom at
+// wrPath, by copying, symlinking, hard linking or reflinking it, as given by mode. Hard links and
+// reflinks fall back to copies where the filesystem cannot make them. A path the workroom already
+// has is skipped with a warning, except that a directory copied onto one gets the files it lacks.
+func (s *Service) includePath(dir, wrPath, rel, mode string) error {
+	src, dst := filepath.Join(dir, rel), filepath.Join(wrPath, rel)
+	if existing, err := os.Lstat(dst); err == nil {
+		if info, err := os.Lstat(src); err != nil || mode == "symlink" || !existing.IsDir() || !info.IsDir() {
+			s.sayColor(fmt.Sprintf("Warning: not %sing %s, as the workroom already has it.", mode, rel), "yellow")
+			return nil
+		}
+	}
+	s.sayStatus(mode, rel)
+	if s.Pretend {
+		return nil
+	}
+
+	var err error
+	switch mode {
+	case "symlink":
+		err = symlinkPath(src, dst)
+	case "hardlink":
+		err = copyTree(src, dst, linkFile)
+	case "reflink":
+		err = copyTree(src, dst, reflinkFile)
+	default:
+		err = copyTree(src, dst, copyFile)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", mode, rel, err)
+	}
+	return nil
+}
+
+// symlinkPath creates dst as a symlink to src.
+func symlinkPath(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.Symlink(src, dst)
+}
+
+// copyTree copies the file, symlink or directory tree at src to dst, keeping their modes. Files
+// that already exist at dst are left as they are. Regular files are copied by copyFn.
+func copyTree(src, dst string, copyFn func(src, dst string, perm fs.FileMode) error) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if existing, err := os.Lstat(target); err == nil {
+			if !d.IsDir() {
+				return nil
+			}
+			if !existing.IsDir() {
+				return fs.SkipDir
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			return copyFn(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+// linkFile hard links dst to src, or copies it if it cannot, such as across filesystems.
+func linkFile(src, dst string, perm fs.FileMode) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyFile(src, dst, perm)
+}
+
+// reflinkFile makes dst a copy-on-write clone of src, or copies it if the filesystem does not
+// support clones.
+func reflinkFile(src, dst string, perm fs.FileMode) error {
+	if err := cloneFile(src, dst, perm); err == nil {
+		return nil
+	}
+	return copyFile(src, dst, perm)
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,152 @@
+package workroom
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/joelmoss/workroom/internal/config"
+	"github.com/joelmoss/workroom/internal/script"
+	"github.com/joelmoss/workroom/internal/ui"
+)
+
+// runHook runs the named hook point of a workroom in workDir: the command configured in the
+// project config, or else the project's scripts/workroom_<point> script followed by the scripts in
+// its scripts/workroom_<point>.d directory, if they exist. A configured command that names a
+// directory runs the scripts in it instead. vars are added to the hook's environment, along with
+// WORKROOM_HOOK. The hook's output is shown as it runs, and logged for `workroom logs`. A hook that
+// runs past its configured timeout, or is interrupted, is stopped along with everything it started.
+func (s *Service) runHook(point, dir, workDir, name string, settings *config.Resolved, vars map[string]string) error {
+	env := append(script.Env(name, dir, settings.Env), "WORKROOM_HOOK="+point)
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		env = append(env, k+"="+vars[k])
+	}
+
+	interpreter := settings.Hooks.Interpreter[point]
+	var steps []func(ctx context.Context, out io.Writer) (string, error)
+	if command := settings.Hooks.Command(point); command != "" {
+		if scriptDir := hookDir(dir, command); scriptDir != "" {
+			s.sayStatus(point, fmt.Sprintf("Running scripts in %s from %q", scriptDir, workDir))
+			steps = append(steps, func(ctx context.Context, out io.Writer) (string, error) {
+				return script.RunDir(ctx, point, scriptDir, interpreter, workDir, env, out)
+			})
+		} else {
+			s.sayStatus(point, fmt.Sprintf("Running %q from %q", command, workDir))
+			steps = append(steps, func(ctx context.Context, out io.Writer) (string, error) {
+				return script.RunCommand(ctx, point, command, interpreter, workDir, env, out)
+			})
+		}
+	} else {
+		scriptPath := filepath.Join(dir, "scripts", "workroom_"+point)
+		for _, path := range []string{scriptPath, scriptPath + ".d"} {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			s.sayStatus(point, fmt.Sprintf("Running %s from %q", path, workDir))
+			run := script.Run
+			if info.IsDir() {
+				run = script.RunDir
+			}
+			steps = append(steps, func(ctx context.Context, out io.Writer) (string, error) {
+				return run(ctx, point, path, interpreter, workDir, env, out)
+			})
+		}
+	}
+	if s.Pretend || len(steps) == 0 {
+		return nil
+	}
+
+	ctx := s.ctx()
+	if timeout := settings.Hooks.TimeoutOf(point); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", ErrHookTimeout, timeout))
+		defer cancel()
+	}
+
+	out := s.newHookOutput(point, dir, name)
+	defer func() {
+		s.sayStatus(point, fmt.Sprintf("Finished in %s", out.close().Round(time.Millisecond)))
+	}()
+	for _, step := range steps {
+		if _, err := step(ctx, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hookOutput shows the output of a running hook, each line prefixed with the hook's name, and
+// logs it to the workroom's log of the hook.
+type hookOutput struct {
+	io.Writer
+	progress *ui.Progress
+	log      *os.File
+}
+
+// newHookOutput returns the hookOutput of the named hook point of a workroom. Hooks that do not
+// belong to a workroom, such as on_prune, are not logged.
+func (s *Service) newHookOutput(point, dir, name string) *hookOutput {
+	h := &hookOutput{}
+	if name != "" {
+		log, err := s.createHookLog(dir, name, point)
+		if err != nil {
+			s.sayColor(fmt.Sprintf("Warning: the output of the %s hook will not be logged: %v", point, err), "yellow")
+		}
+		h.log = log
+	}
+
+	w := s.output()
+	h.progress = ui.NewProgress(w, ui.Dim(point+" | "), fmt.Sprintf("Running %s hook", point), ui.IsTerminal(w))
+	h.Writer = h.progress
+	if h.log != nil {
+		h.Writer = io.MultiWriter(h.progress, h.log)
+	}
+	return h
+}
+
+// close stops showing the output and closes the log, returning how long the hook ran for.
+func (h *hookOutput) close() time.Duration {
+	elapsed := h.progress.Stop()
+	if h.log != nil {
+		h.log.Close()
+	}
+	return elapsed
+}
+
+// createHookLog creates, or truncates, the log of the named hook point of a workroom.
+func (s *Service) createHookLog(dir, name, point string) (*os.File, error) {
+	logDir := s.Config.LogDir(dir, name)
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(filepath.Join(logDir, point+".log"))
+}
+
+// hookDir returns the directory named by a configured hook command, relative to the project at
+// dir, or "" if the command does not name one.
+func hookDir(dir, command string) string {
+	path := command
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return path
+	}
+	return ""
+}
+
+// workroomVars returns the variables describing a workroom that its hooks get, in addition to
+// WORKROOM_NAME, WORKROOM_PARENT_DIR and WORKROOM_HOOK.
+func workroomVars(wrPath, branch, vcsType string) map[string]string {
+	return map[string]string{
+		"WORKROOM_PATH":   wrPath,
+		"WORKROOM_BRANCH": branch,
+		"WORKROOM_VCS":    vcsType,
+	}
+}
@@ -0,0 +1,99 @@
+package workroom
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joelmoss/workroom/internal/config"
+)
+
+// Lock locks the named workroom of the project at dir, so that it is not deleted, pruned or moved
+// until it is unlocked. For Git, its worktree is locked too, so that `git worktree prune` also
+// leaves it alone. Locking a locked workroom replaces its reason.
+func (s *Service) Lock(dir, name, reason string) error {
+	projectPath, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+	if project == nil || project.Workrooms[name] == nil {
+		return fmt.Errorf("%w: '%s'", ErrWorkroomNotFound, name)
+	}
+	entry := project.Workrooms[name]
+	v, err := s.projectVCS(projectPath)
+	if err != nil {
+		return err
+	}
+
+	s.sayStatus("lock", entry.Path)
+	if !s.Pretend {
+		if out, err := v.Lock(s.ctx(), projectPath, entry.Path, reason); err != nil {
+			return fmt.Errorf("failed to lock %s: %w: %s", v.Label(), err, out)
+		}
+		lock := &config.Lock{Reason: reason, LockedAt: time.Now().UTC().Truncate(time.Second)}
+		if err := s.Config.SetLock(projectPath, name, lock); err != nil {
+			return err
+		}
+	}
+	s.sayColor(fmt.Sprintf("Workroom '%s' locked.", name), "green")
+	return nil
+}
+
+// Unlock unlocks the named workroom of the project at dir, and its Git worktree.
+func (s *Service) Unlock(dir, name string) error {
+	projectPath, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+	if project == nil || project.Workrooms[name] == nil {
+		return fmt.Errorf("%w: '%s'", ErrWorkroomNotFound, name)
+	}
+	entry := project.Workrooms[name]
+	if entry.Lock == nil {
+		s.say(fmt.Sprintf("Workroom '%s' is not locked.", name))
+		return nil
+	}
+	v, err := s.projectVCS(projectPath)
+	if err != nil {
+		return err
+	}
+
+	s.sayStatus("unlock", entry.Path)
+	if !s.Pretend {
+		if out, err := v.Unlock(s.ctx(), projectPath, entry.Path); err != nil {
+			return fmt.Errorf("failed to unlock %s: %w: %s", v.Label(), err, out)
+		}
+		if err := s.Config.SetLock(projectPath, name, nil); err != nil {
+			return err
+		}
+	}
+	s.sayColor(fmt.Sprintf("Workroom '%s' unlocked.", name), "green")
+	return nil
+}
+
+// checkUnlocked returns ErrWorkroomLocked if the named workroom of the project at dir is locked.
+func (s *Service) checkUnlocked(dir, name string) error {
+	_, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil || project == nil || project.Workrooms[name] == nil {
+		return err
+	}
+	return lockedError(name, project.Workrooms[name].Lock)
+}
+
+// lockedError returns ErrWorkroomLocked with its reason, or nil when lock is nil.
+func lockedError(name string, lock *config.Lock) error {
+	if lock == nil {
+		return nil
+	}
+	if lock.Reason != "" {
+		return fmt.Errorf("%w: '%s' (%s). Unlock it with `workroom unlock %s` first", ErrWorkroomLocked, name, lock.Reason, name)
+	}
+	return fmt.Errorf("%w: '%s'. Unlock it with `workroom unlock %s` first", ErrWorkroomLocked, name, name)
+}
+
+// lockMarker returns the marker shown after locked workrooms in lists.
+func lockMarker(reason string) string {
+	if reason == "" {
+		return "[locked]"
+	}
+	return fmt.Sprintf("[locked: %s]", reason)
+}
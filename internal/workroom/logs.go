@@ -0,0 +1,68 @@
+package workroom
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/joelmoss/workroom/internal/config"
+)
+
+// Logs prints the output that the hooks of the named workroom of the project at dir logged the
+// last time they ran: that of the given hook point, or when point is empty, that of every hook
+// with a log, under a heading. Logs are kept after the workroom is deleted, so that its teardown
+// can be looked at.
+func (s *Service) Logs(dir, name, point string) error {
+	if !validNameRe.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	if point != "" && !slices.Contains(config.HookPoints, point) {
+		return fmt.Errorf("unknown hook %q. Must be one of: %s", point, strings.Join(config.HookPoints, ", "))
+	}
+	projectPath, _, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+
+	points := config.HookPoints
+	if point != "" {
+		points = []string{point}
+	}
+	logDir := s.Config.LogDir(projectPath, name)
+	found := false
+	for _, p := range points {
+		path := filepath.Join(logDir, p+".log")
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		s.sayStatus("log", path)
+		if point == "" {
+			if found {
+				s.say("")
+			}
+			s.sayColor(fmt.Sprintf("==> %s (%s) <==", p, humanize.Time(info.ModTime())), "blue")
+		}
+		s.output().Write(data)
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			s.say("")
+		}
+		found = true
+	}
+
+	if !found {
+		if point != "" {
+			return fmt.Errorf("no %s log found for workroom '%s'", point, name)
+		}
+		return fmt.Errorf("no logs found for workroom '%s'", name)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package workroom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joelmoss/workroom/internal/ui"
+	"github.com/joelmoss/workroom/internal/vcs"
+)
+
+// Migrate moves every workroom recorded in the config to the path given by the current
+// workrooms_layout, and updates the config to match.
+func (s *Service) Migrate() error {
+	projects, err := s.Config.ProjectsWithWorkrooms()
+	if err != nil {
+		return err
+	}
+
+	moved := 0
+	for _, projectPath := range sortedKeys(projects) {
+		project := projects[projectPath]
+
+		var v vcs.VCS
+		for _, name := range sortedKeys(project.Workrooms) {
+			oldPath := project.Workrooms[name].Path
+			newPath, err := s.workroomPath(projectPath, name)
+			if err != nil {
+				return err
+			}
+			if oldPath == newPath {
+				continue
+			}
+
+			if project.Workrooms[name].Lock != nil {
+				s.sayColor(fmt.Sprintf("Skipping '%s': it is locked.", name), "yellow")
+				continue
+			}
+
+			if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+				s.sayColor(fmt.Sprintf("Skipping '%s': directory %s not found.", name, ui.DisplayPath(oldPath)), "yellow")
+				continue
+			}
+			if _, err := os.Stat(newPath); err == nil {
+				s.sayColor(fmt.Sprintf("Skipping '%s': %s already exists.", name, ui.DisplayPath(newPath)), "yellow")
+				continue
+			}
+
+			if v == nil {
+				v, err = s.projectVCS(projectPath)
+				if err != nil {
+					s.sayColor(fmt.Sprintf("Skipping %s: %v", ui.DisplayPath(projectPath), err), "yellow")
+					break
+				}
+			}
+
+			s.sayStatus("move", fmt.Sprintf("%s -> %s", oldPath, newPath))
+			if !s.Pretend {
+				if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+					return err
+				}
+				if _, err := v.Move(s.ctx(), projectPath, s.vcsName(projectPath, name), oldPath, newPath); err != nil {
+					return fmt.Errorf("failed to move workroom '%s': %w", name, err)
+				}
+				if err := s.Config.AddWorkroom(projectPath, name, newPath, project.VCS); err != nil {
+					return err
+				}
+			}
+
+			s.sayColor(fmt.Sprintf("Moved '%s' from %s to %s.", name, ui.DisplayPath(oldPath), ui.DisplayPath(newPath)), "green")
+			moved++
+		}
+	}
+
+	if moved == 0 {
+		s.say("All workrooms already match the configured layout.")
+	}
+	return nil
+}
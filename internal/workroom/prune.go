@@ -0,0 +1,222 @@
+package workroom
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/joelmoss/workroom/internal/errs"
+	"github.com/joelmoss/workroom/internal/ui"
+	"github.com/joelmoss/workroom/internal/vcs"
+)
+
+// PruneOptions configures Prune. When none of Merged, OlderThan, Missing and Gone are set,
+// Merged, Missing and Gone are used.
+type PruneOptions struct {
+	Merged    bool     // prune workrooms whose branch is merged into trunk
+	OlderThan int      // prune workrooms without commits for this many days
+	Missing   bool     // prune workrooms whose directory is gone
+	Gone      bool     // prune workrooms whose upstream branch was deleted (Git only)
+	Keep      []string // glob patterns of workroom names never to prune
+	Yes       bool     // skip the confirmation prompt
+
+	Force       bool // prune even workrooms with uncommitted or unpushed work
+	KeepBranch  bool // keep the branches of pruned workrooms
+	ForceBranch bool // delete the branches of pruned workrooms even if not merged into trunk
+}
+
+func (o PruneOptions) withDefaults() PruneOptions {
+	if !o.Merged && o.OlderThan == 0 && !o.Missing && !o.Gone {
+		o.Merged, o.Missing, o.Gone = true, true, true
+	}
+	return o
+}
+
+// pruneCandidate is a workroom matched by Prune, with the reasons it matched.
+type pruneCandidate struct {
+	info    WorkroomInfo
+	reasons []string
+}
+
+// Prune deletes the workrooms of the project at dir that match any of the filters in opts: those
+// whose branch is merged into trunk, that have had no commits for a number of days, whose
+// directory is missing, or whose upstream branch was deleted. The candidates are listed with the
+// reasons they matched, and deleted after confirmation just as `workroom delete` would, teardown
+// script included. Workrooms that cannot be deleted are skipped and reported.
+func (s *Service) Prune(dir string, opts PruneOptions) error {
+	if err := s.CheckNotInWorkroom(dir); err != nil {
+		return err
+	}
+	for _, pattern := range opts.Keep {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --keep pattern %q: %w", pattern, err)
+		}
+	}
+	if opts.OlderThan < 0 {
+		return fmt.Errorf("--older-than must be a positive number of days, got %d", opts.OlderThan)
+	}
+	if err := s.detectVCS(dir); err != nil {
+		return err
+	}
+
+	projectPath, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+	if project == nil || len(project.Workrooms) == 0 {
+		s.say("No workrooms found for this project.")
+		return nil
+	}
+
+	candidates, err := s.pruneCandidates(projectPath, s.workroomInfos(projectPath, project), opts.withDefaults())
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		s.say("No workrooms to prune.")
+		return nil
+	}
+
+	rows := [][]string{{ui.Dim("NAME"), ui.Dim("REASON"), ui.Dim("PATH")}}
+	for _, c := range candidates {
+		rows = append(rows, []string{c.info.Name, strings.Join(c.reasons, ", "), ui.DisplayPath(c.info.Path)})
+	}
+	ui.PrintTable(s.output(), rows, 0)
+	s.say("")
+
+	if !opts.Yes && !s.Pretend {
+		confirmed, err := s.ConfirmFn(fmt.Sprintf("Are you sure you want to prune %d workroom(s)?", len(candidates)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			s.sayColor("Aborting. No workrooms were pruned.", "yellow")
+			return nil
+		}
+	}
+
+	deleteOpts := DeleteOptions{Force: opts.Force, KeepBranch: opts.KeepBranch, ForceBranch: opts.ForceBranch}
+	var pruned []string
+	failed := 0
+	for _, c := range candidates {
+		if !s.Pretend {
+			if err := s.checkDeletable(dir, c.info.Name, deleteOpts); err != nil {
+				s.sayColor(fmt.Sprintf("Skipping '%s': %v", c.info.Name, err), "yellow")
+				failed++
+				continue
+			}
+		}
+		if err := s.deleteByName(dir, c.info.Name, deleteOpts); err != nil {
+			s.sayColor(fmt.Sprintf("Failed to delete workroom '%s': %v", c.info.Name, err), "red")
+			failed++
+			continue
+		}
+		pruned = append(pruned, c.info.Name)
+	}
+
+	if len(pruned) > 0 {
+		settings, err := s.Config.Resolve(projectPath)
+		if err != nil {
+			return err
+		}
+		vars := map[string]string{"WORKROOM_PRUNED": strings.Join(pruned, " "), "WORKROOM_VCS": string(s.VCS.Type())}
+		if err := s.runHook("on_prune", projectPath, projectPath, "", settings, vars); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return errs.NewExitError(1, "%d of %d workrooms were not pruned", failed, len(candidates))
+	}
+	return nil
+}
+
+// pruneCandidates returns the workrooms of infos that match the filters of opts, and are neither
+// locked nor kept by its --keep patterns.
+func (s *Service) pruneCandidates(projectPath string, infos []WorkroomInfo, opts PruneOptions) ([]pruneCandidate, error) {
+	settings, err := s.Config.Resolve(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	trunk := settings.Trunk
+	if trunk == "" {
+		trunk = "trunk"
+	}
+
+	var statuses []workroomStatus
+	if opts.Merged || opts.OlderThan > 0 {
+		statuses = s.workroomStatuses(infos)
+	}
+	git, _ := s.VCS.(*vcs.Git)
+	now := time.Now()
+
+	var candidates []pruneCandidate
+	for i, info := range infos {
+		if info.Locked || keepWorkroom(info.Name, opts.Keep) {
+			continue
+		}
+
+		var reasons []string
+		if _, err := os.Stat(info.Path); os.IsNotExist(err) {
+			if opts.Missing {
+				reasons = append(reasons, "directory not found")
+			}
+		} else if statuses != nil && statuses[i].err == nil {
+			st := statuses[i]
+			// A workroom whose latest commit predates it has no commits of its own yet, so is
+			// not merged, just unused. Its age is counted from when it was created.
+			unused := info.CreatedAt != nil && !st.CommitTime.After(*info.CreatedAt)
+			lastActive := st.CommitTime
+			if unused {
+				lastActive = *info.CreatedAt
+			}
+			if opts.Merged && !unused && !st.CommitTime.IsZero() && s.pruneMerged(projectPath, info.Branch, settings.Trunk, st) {
+				reasons = append(reasons, "merged into "+trunk)
+			}
+			if opts.OlderThan > 0 && !lastActive.IsZero() {
+				if days := int(now.Sub(lastActive).Hours() / 24); days >= opts.OlderThan {
+					reasons = append(reasons, fmt.Sprintf("no commits for %d days", days))
+				}
+			}
+		}
+		if opts.Gone && git != nil {
+			if exists, err := git.HasBranch(s.ctx(), projectPath, info.Branch); err == nil && exists {
+				if gone, err := git.UpstreamGone(s.ctx(), projectPath, info.Branch); err == nil && gone {
+					reasons = append(reasons, "upstream branch deleted")
+				}
+			}
+		}
+
+		if len(reasons) > 0 {
+			candidates = append(candidates, pruneCandidate{info: info, reasons: reasons})
+		}
+	}
+	return candidates, nil
+}
+
+// pruneMerged reports whether the workroom's work is all in trunk. For Git, its branch must exist
+// and be an ancestor of trunk. JJ workspaces have no branch, so their working copy must be clean
+// and have no commits that are not in trunk.
+func (s *Service) pruneMerged(projectPath, vcsName, trunk string, st workroomStatus) bool {
+	git, ok := s.VCS.(*vcs.Git)
+	if !ok {
+		return len(st.DirtyFiles) == 0 && st.Ahead == 0
+	}
+	exists, err := git.HasBranch(s.ctx(), projectPath, vcsName)
+	if err != nil || !exists {
+		return false
+	}
+	merged, err := git.BranchMerged(s.ctx(), projectPath, vcsName, trunk)
+	return err == nil && merged
+}
+
+func keepWorkroom(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
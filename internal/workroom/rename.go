@@ -0,0 +1,157 @@
+package workroom
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joelmoss/workroom/internal/ui"
+)
+
+// Rename renames a workroom of the project at dir: its directory, its Git branch or JJ workspace,
+// and its config entry, then runs the rename hook. The directory is only moved when it is named
+// after the workroom, and the branch only renamed when it is the branch prefix followed by the
+// workroom name. If any step fails, the steps before it are undone.
+func (s *Service) Rename(dir, oldName, newName string) (err error) {
+	if err := s.CheckNotInWorkroom(dir); err != nil {
+		return err
+	}
+	if !validNameRe.MatchString(newName) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, newName)
+	}
+	if err := s.detectVCS(dir); err != nil {
+		return err
+	}
+
+	_, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+	if project == nil || project.Workrooms[oldName] == nil {
+		return fmt.Errorf("%w: '%s'", ErrWorkroomNotFound, oldName)
+	}
+	if _, ok := project.Workrooms[newName]; ok {
+		return fmt.Errorf("workroom '%s' already exists", newName)
+	}
+	if err := lockedError(oldName, project.Workrooms[oldName].Lock); err != nil {
+		return err
+	}
+	settings, err := s.Config.Resolve(dir)
+	if err != nil {
+		return err
+	}
+
+	oldPath := project.Workrooms[oldName].Path
+	newPath, err := s.renamedPath(dir, oldName, newName, oldPath)
+	if err != nil {
+		return err
+	}
+	oldBranch := s.vcsName(dir, oldName)
+	newBranch := oldBranch
+	if rest, ok := strings.CutPrefix(oldBranch, settings.BranchPrefix); ok && rest == oldName && s.ownsBranch(dir, oldName) {
+		newBranch = settings.BranchPrefix + newName
+	}
+
+	if newPath != oldPath {
+		if _, err := os.Stat(newPath); err == nil {
+			return fmt.Errorf("%w: workroom directory '%s' already exists", ErrDirExists, ui.DisplayPath(newPath))
+		}
+	}
+
+	names := map[string]string{"WORKROOM_OLD_NAME": oldName, "WORKROOM_NEW_NAME": newName}
+	vcsType := string(s.VCS.Type())
+	if _, err := os.Stat(oldPath); err == nil {
+		vars := workroomVars(oldPath, oldBranch, vcsType)
+		maps.Copy(vars, names)
+		if err := s.runHook("pre_rename", dir, oldPath, oldName, settings, vars); err != nil {
+			return err
+		}
+	}
+
+	var rb rollback
+	defer func() {
+		if err != nil && !rb.empty() {
+			s.sayColor(fmt.Sprintf("Rolling back rename of workroom '%s'...", oldName), "yellow")
+			rb.run(s)
+		}
+	}()
+
+	if newPath != oldPath {
+		s.sayStatus("move", fmt.Sprintf("%s -> %s", oldPath, newPath))
+		if !s.Pretend {
+			if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+				return err
+			}
+			if out, err := s.VCS.Move(s.ctx(), dir, oldBranch, oldPath, newPath); err != nil {
+				return fmt.Errorf("failed to move workroom: %w: %s", err, out)
+			}
+			rb.add("move", fmt.Sprintf("move %s back to %s", newPath, oldPath), func(ctx context.Context) error {
+				_, err := s.VCS.Move(ctx, dir, oldBranch, newPath, oldPath)
+				return err
+			})
+		}
+	}
+
+	if newBranch != oldBranch {
+		s.sayStatus("rename", fmt.Sprintf("%s -> %s", oldBranch, newBranch))
+		if !s.Pretend {
+			if out, err := s.VCS.Rename(s.ctx(), dir, newPath, oldBranch, newBranch); err != nil {
+				return fmt.Errorf("failed to rename %s: %w: %s", oldBranch, err, out)
+			}
+			rb.add("rename", fmt.Sprintf("rename %s back to %s", newBranch, oldBranch), func(ctx context.Context) error {
+				_, err := s.VCS.Rename(ctx, dir, newPath, newBranch, oldBranch)
+				return err
+			})
+		}
+	}
+
+	if !s.Pretend {
+		if err := s.Config.RenameWorkroom(dir, oldName, newName, newPath, newBranch); err != nil {
+			return err
+		}
+		rb.add("config", fmt.Sprintf("rename workroom '%s' back to '%s' in config", newName, oldName), func(context.Context) error {
+			return s.Config.RenameWorkroom(dir, newName, oldName, oldPath, oldBranch)
+		})
+
+		// The logs follow the workroom, replacing those of any earlier workroom of the new name, but
+		// are not worth failing the rename over.
+		oldLogs, newLogs := s.Config.LogDir(dir, oldName), s.Config.LogDir(dir, newName)
+		if _, err := os.Stat(oldLogs); err == nil {
+			os.RemoveAll(newLogs)
+			if err := os.Rename(oldLogs, newLogs); err == nil {
+				rb.add("logs", fmt.Sprintf("move logs back to %s", oldLogs), func(context.Context) error {
+					return os.Rename(newLogs, oldLogs)
+				})
+			}
+		}
+	}
+
+	vars := workroomVars(newPath, newBranch, vcsType)
+	maps.Copy(vars, names)
+	if err := s.runHook("rename", dir, newPath, newName, settings, vars); err != nil {
+		return err
+	}
+
+	s.sayColor(fmt.Sprintf("Workroom '%s' renamed to '%s' at %s.", oldName, newName, ui.DisplayPath(newPath)), "green")
+	return nil
+}
+
+// renamedPath returns where the workroom at oldPath moves to when it is renamed: the path given
+// by the layout when it is there now, or else a sibling named after the new name when its
+// directory is named after the workroom. Otherwise it stays where it is.
+func (s *Service) renamedPath(dir, oldName, newName, oldPath string) (string, error) {
+	layoutPath, err := s.workroomPath(dir, oldName)
+	if err != nil {
+		return "", err
+	}
+	if filepath.Clean(layoutPath) == filepath.Clean(oldPath) {
+		return s.workroomPath(dir, newName)
+	}
+	if filepath.Base(oldPath) == oldName {
+		return filepath.Join(filepath.Dir(oldPath), newName), nil
+	}
+	return oldPath, nil
+}
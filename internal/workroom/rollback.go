@@ -0,0 +1,43 @@
+package workroom
+
+import (
+	"context"
+	"fmt"
+)
+
+// rollback records the completed steps of a multi-step operation, so that they can be undone in
+// reverse order if a later step fails.
+type rollback struct {
+	steps []rollbackStep
+}
+
+type rollbackStep struct {
+	status string
+	desc   string
+	undo   func(ctx context.Context) error
+}
+
+// add records a completed step along with the function that undoes it.
+func (r *rollback) add(status, desc string, undo func(ctx context.Context) error) {
+	r.steps = append(r.steps, rollbackStep{status: status, desc: desc, undo: undo})
+}
+
+// empty reports whether there is nothing to undo.
+func (r *rollback) empty() bool {
+	return len(r.steps) == 0
+}
+
+// run undoes the recorded steps in reverse order. A failed step is reported as a warning, and
+// the remaining steps still run. The steps run even when the operation was interrupted, as undoing
+// it is the point.
+func (r *rollback) run(s *Service) {
+	ctx := context.WithoutCancel(s.ctx())
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		step := r.steps[i]
+		s.sayStatus(step.status, step.desc)
+		if err := step.undo(ctx); err != nil {
+			s.sayColor(fmt.Sprintf("Warning: failed to %s: %v", step.desc, err), "yellow")
+		}
+	}
+	r.steps = nil
+}
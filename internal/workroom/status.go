@@ -0,0 +1,148 @@
+package workroom
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/joelmoss/workroom/internal/ui"
+	"github.com/joelmoss/workroom/internal/vcs"
+)
+
+// ListColumns are the columns that can be selected for table output of List.
+var ListColumns = []string{"name", "path", "branch", "dirty", "sync", "commit", "age", "modified"}
+
+var defaultListColumns = []string{"name", "path"}
+
+// maxStatusProbes limits how many workrooms are probed for status at once.
+const maxStatusProbes = 8
+
+// maxSubjectLen is the number of characters at which commit subjects are truncated in the commit
+// column.
+const maxSubjectLen = 50
+
+// workroomStatus is the result of probing a single workroom for status columns.
+type workroomStatus struct {
+	vcs.Status
+	LastModified time.Time
+	err          error
+}
+
+func validateListColumns(columns []string) error {
+	for _, c := range columns {
+		if !slices.Contains(ListColumns, c) {
+			return fmt.Errorf("unknown column %q. Valid columns are: %s", c, strings.Join(ListColumns, ", "))
+		}
+	}
+	return nil
+}
+
+// workroomStatuses probes each workroom concurrently, returning results in the same order as
+// infos. Workrooms whose directory or VCS is missing get a zero status.
+func (s *Service) workroomStatuses(infos []WorkroomInfo) []workroomStatus {
+	results := make([]workroomStatus, len(infos))
+
+	vcsByProject := map[string]vcs.VCS{}
+	trunkByProject := map[string]string{}
+	for _, info := range infos {
+		if _, ok := vcsByProject[info.Project]; !ok {
+			v, _ := s.projectVCS(info.Project)
+			vcsByProject[info.Project] = v
+			if settings, err := s.Config.Resolve(info.Project); err == nil {
+				trunkByProject[info.Project] = settings.Trunk
+			}
+		}
+	}
+
+	sem := make(chan struct{}, maxStatusProbes)
+	var wg sync.WaitGroup
+	for i, info := range infos {
+		v := vcsByProject[info.Project]
+		if v == nil {
+			continue
+		}
+		if _, err := os.Stat(info.Path); err != nil {
+			continue
+		}
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = probeStatus(s.ctx(), v, info.Path, trunkByProject[info.Project])
+		})
+	}
+	wg.Wait()
+
+	return results
+}
+
+func probeStatus(ctx context.Context, v vcs.VCS, wrPath, trunk string) workroomStatus {
+	status, err := v.Status(ctx, wrPath, trunk)
+	if err != nil {
+		return workroomStatus{err: err}
+	}
+	return workroomStatus{Status: status, LastModified: lastModified(wrPath, status.DirtyFiles)}
+}
+
+// lastModified returns the most recent modification time of the workroom directory and its dirty
+// files. Walking the whole tree would be too slow for large projects, and files that are not
+// dirty are at least as old as the last commit.
+func lastModified(wrPath string, dirtyFiles []string) time.Time {
+	var latest time.Time
+	for _, p := range append([]string{""}, dirtyFiles...) {
+		info, err := os.Stat(filepath.Join(wrPath, p))
+		if err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// listCell renders a single table cell for the given column.
+func listCell(column string, info WorkroomInfo, statuses []workroomStatus, i int) string {
+	switch column {
+	case "name":
+		return ui.Bold(info.Name)
+	case "path":
+		return ui.Dim(ui.DisplayPath(info.Path))
+	}
+
+	if statuses == nil || statuses[i].err != nil || statuses[i].LastModified.IsZero() {
+		return ui.Dim("-")
+	}
+	st := statuses[i]
+
+	switch column {
+	case "branch":
+		return st.Branch
+	case "dirty":
+		if len(st.DirtyFiles) == 0 {
+			return ui.Dim("clean")
+		}
+		return ui.Yellow(fmt.Sprintf("%d dirty", len(st.DirtyFiles)))
+	case "sync":
+		if st.Ahead == 0 && st.Behind == 0 {
+			return ui.Dim("up to date")
+		}
+		return fmt.Sprintf("+%d -%d", st.Ahead, st.Behind)
+	case "commit":
+		// Truncate by runes, so that multi-byte characters are never cut in half.
+		if subject := []rune(st.CommitSubject); len(subject) > maxSubjectLen {
+			return string(subject[:maxSubjectLen-3]) + "..."
+		}
+		return st.CommitSubject
+	case "age":
+		if st.CommitTime.IsZero() {
+			return ui.Dim("-")
+		}
+		return humanize.Time(st.CommitTime)
+	case "modified":
+		return humanize.Time(st.LastModified)
+	}
+	return ""
+}
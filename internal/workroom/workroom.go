@@ -1,17 +1,20 @@
 package workroom
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/joelmoss/workroom/internal/config"
 	"github.com/joelmoss/workroom/internal/namegen"
-	"github.com/joelmoss/workroom/internal/script"
 	"github.com/joelmoss/workroom/internal/ui"
 	"github.com/joelmoss/workroom/internal/vcs"
 )
@@ -21,6 +24,8 @@ var validNameRe = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?$`
 // PromptFunc abstracts interactive prompts for testability.
 type PromptFunc func(message string, options []string) ([]string, error)
 type ConfirmFunc func(message string) (bool, error)
+type SelectFunc func(message string, options []string) (string, error)
+type EditFunc func(path string) error
 
 // Service orchestrates workroom create/delete/list operations.
 type Service struct {
@@ -31,7 +36,24 @@ type Service struct {
 	Pretend     bool
 	PromptFn    PromptFunc
 	ConfirmFn   ConfirmFunc
+	SelectFn    SelectFunc
+	EditFn      EditFunc
 	NameGenFunc func() string // override for testing
+
+	// CdFile is the file that the shell integration reads the directory to change to from. When
+	// empty, shell integration is not active.
+	CdFile string
+
+	// Context is done when the command is interrupted, which stops the VCS commands and hooks
+	// that are running. Defaults to context.Background().
+	Context context.Context
+}
+
+func (s *Service) ctx() context.Context {
+	if s.Context != nil {
+		return s.Context
+	}
+	return context.Background()
 }
 
 func (s *Service) output() io.Writer {
@@ -89,46 +111,150 @@ func (s *Service) detectVCS(dir string) error {
 	return nil
 }
 
-func (s *Service) vcsName(name string) string {
-	return "workroom/" + name
+// projectVCS returns the VCS for the project at dir. Unlike detectVCS, the result is not stored
+// on the service, so operations spanning several projects detect each one separately.
+func (s *Service) projectVCS(dir string) (vcs.VCS, error) {
+	if s.VCS != nil {
+		return s.VCS, nil
+	}
+	return vcs.Detect(dir)
+}
+
+// vcsName returns the Git branch or JJ workspace name of the named workroom of the project at
+// dir: the one recorded when the workroom was created, or else the configured branch prefix
+// followed by name.
+func (s *Service) vcsName(dir, name string) string {
+	prefix := config.DefaultBranchPrefix
+	if settings, err := s.Config.Resolve(dir); err == nil {
+		prefix = settings.BranchPrefix
+	}
+	if _, project, err := s.Config.FindCurrentProject(dir); err == nil && project != nil {
+		if entry, ok := project.Workrooms[name]; ok {
+			return entryVCSName(entry, prefix, name)
+		}
+	}
+	return prefix + name
+}
+
+// entryVCSName returns the Git branch or JJ workspace name recorded in a workroom's config entry.
+// Entries written by older versions of workroom have none, so prefix followed by name is assumed,
+// but an external branch is taken as it is, as a detached worktree has none.
+func entryVCSName(entry *config.WorkroomEntry, prefix, name string) string {
+	if entry.Branch != "" || entry.ExternalBranch {
+		return entry.Branch
+	}
+	return prefix + name
+}
+
+// ownsBranch reports whether workroom created the branch of the named workroom of the project at
+// dir, and so may rename or delete it. Branches checked out with --from or adopted are the user's.
+func (s *Service) ownsBranch(dir, name string) bool {
+	_, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil || project == nil || project.Workrooms[name] == nil {
+		return true
+	}
+	return !project.Workrooms[name].ExternalBranch
 }
 
-func (s *Service) workroomPath(name string) (string, error) {
-	dir, err := s.Config.WorkroomsDir()
+func (s *Service) workroomPath(dir, name string) (string, error) {
+	return s.Config.WorkroomPath(dir, name)
+}
+
+// recordedPath returns the path recorded in the config for the named workroom of the project at
+// dir, falling back to the path given by the current layout.
+func (s *Service) recordedPath(dir, name string) (string, error) {
+	_, project, err := s.Config.FindCurrentProject(dir)
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(dir, name), nil
+	if project != nil {
+		if entry, ok := project.Workrooms[name]; ok {
+			return entry.Path, nil
+		}
+	}
+	return s.workroomPath(dir, name)
 }
 
-func (s *Service) generateName() string {
+func (s *Service) generateName(style string) (string, error) {
 	if s.NameGenFunc != nil {
-		return s.NameGenFunc()
+		return s.NameGenFunc(), nil
 	}
-	return namegen.Generate()
+	return namegen.GenerateStyle(style)
+}
+
+// CreateOptions configures a Create call.
+type CreateOptions struct {
+	Name string // workroom name; a unique name is generated when empty
+	From string // existing branch, tag, commit or revset to check out
+	Base string // ref to fork the new branch from instead of HEAD
+
+	// Cd changes the calling shell's directory to the new workroom. Requires shell integration.
+	Cd bool
+
+	// KeepOnFailure leaves a partially created workroom in place when a step fails, instead of
+	// rolling it back. Useful for debugging a broken setup script.
+	KeepOnFailure bool
 }
 
-// Create generates a unique name and creates a new workroom.
-func (s *Service) Create(dir string) error {
+// Create creates a new workroom. If any step fails, the steps completed so far are undone in
+// reverse order, unless opts.KeepOnFailure is set.
+func (s *Service) Create(dir string, opts CreateOptions) (err error) {
 	if err := s.CheckNotInWorkroom(dir); err != nil {
 		return err
 	}
+
+	if opts.From != "" && opts.Base != "" {
+		return ErrFromAndBase
+	}
+
+	name := opts.Name
+	if name != "" && !validNameRe.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
 	if err := s.detectVCS(dir); err != nil {
 		return err
 	}
 
-	name, err := s.generateUniqueName(dir)
+	settings, err := s.Config.Resolve(dir)
 	if err != nil {
 		return err
 	}
 
-	wrPath, err := s.workroomPath(name)
+	if name == "" {
+		name, err = s.generateUniqueName(dir, settings)
+		if err != nil {
+			return err
+		}
+	}
+	// Git checks out an existing ref as it is, so that is the workroom's branch, while JJ always
+	// names a new workspace after the workroom.
+	branch := settings.BranchPrefix + name
+	if opts.From != "" && s.VCS.Type() == vcs.TypeGit {
+		branch = opts.From
+	}
+
+	wrPath, err := s.workroomPath(dir, name)
 	if err != nil {
 		return err
 	}
 
+	var rb rollback
+	defer func() {
+		if err == nil || rb.empty() {
+			return
+		}
+		if opts.KeepOnFailure {
+			s.sayColor(fmt.Sprintf("Keeping workroom '%s' at %s for debugging.", name, ui.DisplayPath(wrPath)), "yellow")
+			s.say(fmt.Sprintf("Clean up with `workroom delete %s` when done.", name))
+			return
+		}
+		s.sayColor(fmt.Sprintf("Rolling back workroom '%s'...", name), "yellow")
+		rb.run(s)
+	}()
+
 	if !s.Pretend {
-		exists, err := s.VCS.WorkroomExists(dir, name)
+		exists, err := s.VCS.WorkroomExists(s.ctx(), dir, wrPath, branch)
 		if err != nil {
 			return err
 		}
@@ -144,61 +270,92 @@ func (s *Service) Create(dir string) error {
 		}
 	}
 
+	// The workroom does not exist yet, so the pre_create hook runs in the project.
+	vars := workroomVars(wrPath, branch, string(s.VCS.Type()))
+	if err := s.runHook("pre_create", dir, dir, name, settings, vars); err != nil {
+		return err
+	}
+
 	// Create VCS workspace
+	if opts.From != "" {
+		s.sayStatus("from", opts.From)
+	} else if opts.Base != "" {
+		s.sayStatus("base", opts.Base)
+	}
 	if !s.Pretend {
-		wrDir, err := s.Config.WorkroomsDir()
-		if err != nil {
+		if err := os.MkdirAll(filepath.Dir(wrPath), 0o755); err != nil {
 			return err
 		}
-		if err := os.MkdirAll(wrDir, 0o755); err != nil {
-			return err
-		}
-		if _, err := s.VCS.Create(dir, s.vcsName(name), wrPath); err != nil {
+		// The directory did not exist before, so anything left behind is ours to remove.
+		rb.add("remove", fmt.Sprintf("remove directory %s", wrPath), func(context.Context) error {
+			return os.RemoveAll(wrPath)
+		})
+		if _, err := s.VCS.Create(s.ctx(), dir, branch, wrPath, vcs.CreateOptions{From: opts.From, Base: opts.Base}); err != nil {
 			return fmt.Errorf("failed to create workspace: %w", err)
 		}
+		// Git only creates a branch when not checking out an existing ref, but JJ always creates a
+		// new working-copy commit.
+		deleteOpts := vcs.DeleteOptions{DeleteBranch: opts.From == "" || s.VCS.Type() == vcs.TypeJJ, Force: true}
+		rb.add("delete", fmt.Sprintf("delete %s '%s'", s.VCS.Label(), branch), func(ctx context.Context) error {
+			_, err := s.VCS.Delete(ctx, dir, branch, wrPath, deleteOpts)
+			return err
+		})
 	}
 
 	// Update config
 	if !s.Pretend {
-		if err := s.Config.AddWorkroom(dir, name, wrPath, string(s.VCS.Type())); err != nil {
+		external := opts.From != "" && s.VCS.Type() == vcs.TypeGit
+		entry := config.WorkroomEntry{Path: wrPath, Branch: branch, ExternalBranch: external}
+		if err := s.Config.AddWorkroomEntry(dir, string(s.VCS.Type()), name, entry); err != nil {
 			return err
 		}
+		rb.add("config", fmt.Sprintf("remove workroom '%s' from config", name), func(context.Context) error {
+			return s.Config.RemoveWorkroom(dir, name)
+		})
 	}
 
-	// Run setup script
-	var setupOutput string
-	setupScript := filepath.Join(dir, "scripts", "workroom_setup")
-	if _, err := os.Stat(setupScript); err == nil {
-		s.sayStatus("setup", fmt.Sprintf("Running %s from %q", setupScript, wrPath))
-		if !s.Pretend {
-			setupOutput, err = script.Run("setup", setupScript, wrPath, name, dir)
-			if err != nil {
-				return err
-			}
-		}
+	if err := s.linkProjectFiles(dir, wrPath, settings); err != nil {
+		return err
 	}
 
-	s.sayColor(fmt.Sprintf("Workroom '%s' created successfully at %s.", name, ui.DisplayPath(wrPath)), "green")
+	if err := s.runHook("setup", dir, wrPath, name, settings, vars); err != nil {
+		return err
+	}
 
-	if setupOutput != "" {
-		s.say("")
-		s.sayColor("Setup script output:", "blue")
-		s.say(strings.TrimSpace(setupOutput))
+	// An interrupt between commands undoes the create too.
+	if err := context.Cause(s.ctx()); err != nil {
+		return err
 	}
 
+	// The workroom is complete, so a failing post_create hook does not undo it.
+	rb = rollback{}
+	postErr := s.runHook("post_create", dir, wrPath, name, settings, vars)
+
+	s.sayColor(fmt.Sprintf("Workroom '%s' created successfully at %s.", name, ui.DisplayPath(wrPath)), "green")
+	if postErr != nil {
+		return postErr
+	}
+
+	if opts.Cd && !s.Pretend {
+		return s.changeDir(WorkroomInfo{Project: dir, Name: name, Path: wrPath, VCS: string(s.VCS.Type()), Branch: branch})
+	}
 	return nil
 }
 
-func (s *Service) generateUniqueName(dir string) (string, error) {
+func (s *Service) generateUniqueName(dir string, settings *config.Resolved) (string, error) {
 	var lastName string
 
 	for range 5 {
-		lastName = s.generateName()
-		exists, err := s.workroomExistsFor(dir, lastName)
+		var err error
+		lastName, err = s.generateName(settings.NameStyle)
+		if err != nil {
+			return "", err
+		}
+		exists, err := s.workroomExistsFor(dir, lastName, settings)
 		if err != nil {
 			return "", err
 		}
-		wrPath, err := s.workroomPath(lastName)
+		wrPath, err := s.workroomPath(dir, lastName)
 		if err != nil {
 			return "", err
 		}
@@ -211,11 +368,11 @@ func (s *Service) generateUniqueName(dir string) (string, error) {
 
 	for range 10 {
 		candidate := fmt.Sprintf("%s-%d", lastName, rand.IntN(90)+10)
-		exists, err := s.workroomExistsFor(dir, candidate)
+		exists, err := s.workroomExistsFor(dir, candidate, settings)
 		if err != nil {
 			return "", err
 		}
-		wrPath, err := s.workroomPath(candidate)
+		wrPath, err := s.workroomPath(dir, candidate)
 		if err != nil {
 			return "", err
 		}
@@ -229,34 +386,75 @@ func (s *Service) generateUniqueName(dir string) (string, error) {
 	return "", fmt.Errorf("failed to generate unique workroom name after multiple attempts")
 }
 
-func (s *Service) workroomExistsFor(dir, name string) (bool, error) {
-	return s.VCS.WorkroomExists(dir, name)
+func (s *Service) workroomExistsFor(dir, name string, settings *config.Resolved) (bool, error) {
+	wrPath, err := s.workroomPath(dir, name)
+	if err != nil {
+		return false, err
+	}
+	return s.VCS.WorkroomExists(s.ctx(), dir, wrPath, settings.BranchPrefix+name)
+}
+
+// ListFormat selects how List prints workrooms.
+type ListFormat string
+
+const (
+	ListFormatTable  ListFormat = ""
+	ListFormatJSON   ListFormat = "json"
+	ListFormatNDJSON ListFormat = "ndjson"
+)
+
+// ListOptions configures a List call.
+type ListOptions struct {
+	Format  ListFormat
+	Columns []string // table columns to show; defaults to name and path
+}
+
+// ListSchemaVersion is the version of the WorkroomInfo JSON schema. It is bumped whenever a field
+// is removed or changes meaning. Adding a field does not bump it.
+const ListSchemaVersion = 1
+
+// WorkroomInfo describes a workroom in JSON and NDJSON list output.
+type WorkroomInfo struct {
+	Project    string     `json:"project"`
+	Name       string     `json:"name"`
+	Path       string     `json:"path"`
+	VCS        string     `json:"vcs"`
+	Branch     string     `json:"branch"` // git branch or jj workspace name
+	Warnings   []string   `json:"warnings"`
+	CreatedAt  *time.Time `json:"created_at"` // nil for workrooms created by older versions
+	Locked     bool       `json:"locked"`
+	LockReason string     `json:"lock_reason,omitempty"`
 }
 
 // List shows workrooms for the current project or all projects.
-func (s *Service) List(cwd string) error {
-	projectPath, project, found := s.Config.FindCurrentProject(cwd)
+func (s *Service) List(cwd string, opts ListOptions) error {
+	if opts.Format != ListFormatTable {
+		return s.listJSON(cwd, opts.Format)
+	}
+	if err := validateListColumns(opts.Columns); err != nil {
+		return err
+	}
+
+	projectPath, project, err := s.Config.FindCurrentProject(cwd)
+	if err != nil {
+		return err
+	}
 
 	// Inside a workroom
-	if found && projectPath != cwd {
+	if project != nil && projectPath != cwd {
 		s.sayColor("You are already in a workroom.", "yellow")
 		s.say(fmt.Sprintf("Parent project is at %s", ui.DisplayPath(projectPath)))
 		return nil
 	}
 
 	// Inside a parent project
-	if found && project != nil {
-		workrooms, ok := project["workrooms"].(map[string]any)
-		if !ok || len(workrooms) == 0 {
+	if project != nil {
+		if len(project.Workrooms) == 0 {
 			s.say("No workrooms found for this project.")
 			return nil
 		}
 
-		vcsType := ""
-		if v, ok := project["vcs"].(string); ok {
-			vcsType = v
-		}
-		s.listWorkrooms(workrooms, vcsType, cwd)
+		s.listWorkrooms(s.workroomInfos(cwd, project), opts.Columns)
 		return nil
 	}
 
@@ -271,28 +469,126 @@ func (s *Service) List(cwd string) error {
 		return nil
 	}
 
-	for path, proj := range projects {
+	for _, path := range sortedKeys(projects) {
 		s.say(fmt.Sprintf("%s:", ui.DisplayPath(path)))
-		workrooms, _ := proj["workrooms"].(map[string]any)
-		vcsType, _ := proj["vcs"].(string)
-		s.listWorkrooms(workrooms, vcsType, path)
+		s.listWorkrooms(s.workroomInfos(path, projects[path]), opts.Columns)
 		s.say("")
 	}
 
 	return nil
 }
 
-func (s *Service) listWorkrooms(workrooms map[string]any, vcsType, dir string) {
+// listJSON writes workrooms as a single JSON document, or as one JSON object per line. From
+// inside a project or one of its workrooms, only that project's workrooms are included.
+func (s *Service) listJSON(cwd string, format ListFormat) error {
+	projectPath, project, err := s.Config.FindCurrentProject(cwd)
+	if err != nil {
+		return err
+	}
+	projects := map[string]*config.Project{}
+	if project != nil {
+		projects[projectPath] = project
+	} else {
+		projects, err = s.Config.ProjectsWithWorkrooms()
+		if err != nil {
+			return err
+		}
+	}
+
+	infos := []WorkroomInfo{}
+	for _, path := range sortedKeys(projects) {
+		infos = append(infos, s.workroomInfos(path, projects[path])...)
+	}
+
+	enc := json.NewEncoder(s.output())
+	switch format {
+	case ListFormatJSON:
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			SchemaVersion int            `json:"schema_version"`
+			Workrooms     []WorkroomInfo `json:"workrooms"`
+		}{ListSchemaVersion, infos})
+	case ListFormatNDJSON:
+		for _, info := range infos {
+			line := struct {
+				SchemaVersion int `json:"schema_version"`
+				WorkroomInfo
+			}{ListSchemaVersion, info}
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown list format %q", format)
+	}
+}
+
+// workroomInfos returns the workrooms of a project from the config, sorted by name.
+func (s *Service) workroomInfos(projectPath string, project *config.Project) []WorkroomInfo {
+	prefix := config.DefaultBranchPrefix
+	if settings, err := s.Config.Resolve(projectPath); err == nil {
+		prefix = settings.BranchPrefix
+	}
+
+	var infos []WorkroomInfo
+	for _, name := range sortedKeys(project.Workrooms) {
+		entry := project.Workrooms[name]
+		branch := entryVCSName(entry, prefix, name)
+		info := WorkroomInfo{
+			Project:  projectPath,
+			Name:     name,
+			Path:     entry.Path,
+			VCS:      project.VCS,
+			Branch:   branch,
+			Warnings: s.workroomWarnings(branch, entry.Path, project.VCS, projectPath),
+		}
+		if info.Warnings == nil {
+			info.Warnings = []string{}
+		}
+		if !entry.CreatedAt.IsZero() {
+			createdAt := entry.CreatedAt
+			info.CreatedAt = &createdAt
+		}
+		if entry.Lock != nil {
+			info.Locked, info.LockReason = true, entry.Lock.Reason
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (s *Service) listWorkrooms(infos []WorkroomInfo, columns []string) {
+	if len(columns) == 0 {
+		columns = defaultListColumns
+	}
+
+	var statuses []workroomStatus
+	if slices.ContainsFunc(columns, func(c string) bool { return c != "name" && c != "path" }) {
+		statuses = s.workroomStatuses(infos)
+	}
+
 	var rows [][]string
-	for name, info := range workrooms {
-		infoMap, ok := info.(map[string]any)
-		if !ok {
-			continue
+	if !slices.Equal(columns, defaultListColumns) {
+		header := make([]string, len(columns))
+		for i, c := range columns {
+			header[i] = ui.Dim(strings.ToUpper(c))
 		}
-		wrPath, _ := infoMap["path"].(string)
-		warnings := s.workroomWarnings(name, wrPath, vcsType, dir)
+		rows = append(rows, header)
+	}
 
-		row := []string{ui.Bold(name), ui.Dim(ui.DisplayPath(wrPath))}
+	for i, info := range infos {
+		var row []string
+		for _, c := range columns {
+			row = append(row, listCell(c, info, statuses, i))
+		}
+		if info.Locked {
+			row = append(row, ui.Blue(lockMarker(info.LockReason)))
+		}
+		warnings := info.Warnings
+		if statuses != nil && statuses[i].err != nil {
+			warnings = append(warnings, "status unavailable")
+		}
 		if len(warnings) > 0 {
 			row = append(row, ui.Yellow(fmt.Sprintf("[%s]", strings.Join(warnings, ", "))))
 		}
@@ -301,7 +597,17 @@ func (s *Service) listWorkrooms(workrooms map[string]any, vcsType, dir string) {
 	ui.PrintTable(s.output(), rows, 2)
 }
 
-func (s *Service) workroomWarnings(name, wrPath, vcsType, dir string) []string {
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (s *Service) workroomWarnings(vcsName, wrPath, vcsType, dir string) []string {
 	var warnings []string
 	if _, err := os.Stat(wrPath); os.IsNotExist(err) {
 		warnings = append(warnings, "directory not found")
@@ -309,10 +615,9 @@ func (s *Service) workroomWarnings(name, wrPath, vcsType, dir string) []string {
 
 	// Check VCS workspace existence
 	if s.VCS != nil {
-		vcsName := "workroom/" + name
 		if vcsType == "jj" {
 			if jj, ok := s.VCS.(*vcs.JJ); ok {
-				workspaces, err := jj.ListWorkrooms(dir)
+				workspaces, err := jj.ListWorkrooms(s.ctx(), dir)
 				if err == nil {
 					found := false
 					for _, w := range workspaces {
@@ -328,18 +633,8 @@ func (s *Service) workroomWarnings(name, wrPath, vcsType, dir string) []string {
 			}
 		} else if vcsType == "git" {
 			if git, ok := s.VCS.(*vcs.Git); ok {
-				workrooms, err := git.ListWorkrooms(dir)
-				if err == nil {
-					found := false
-					for _, w := range workrooms {
-						if w == name {
-							found = true
-							break
-						}
-					}
-					if !found {
-						warnings = append(warnings, "git workspace not found")
-					}
+				if found, err := git.WorkroomExists(s.ctx(), dir, wrPath, vcsName); err == nil && !found {
+					warnings = append(warnings, "git workspace not found")
 				}
 			}
 		}
@@ -348,8 +643,16 @@ func (s *Service) workroomWarnings(name, wrPath, vcsType, dir string) []string {
 	return warnings
 }
 
+// DeleteOptions configures a Delete or InteractiveDelete call.
+type DeleteOptions struct {
+	Confirm     string // skip the confirmation prompt if this matches the workroom name
+	Force       bool   // delete even if the workroom has uncommitted or unpushed work
+	KeepBranch  bool   // keep the workroom's branch instead of deleting it
+	ForceBranch bool   // delete the workroom's branch even if it is not merged into trunk
+}
+
 // Delete removes a workroom by name.
-func (s *Service) Delete(dir, name, confirmValue string) error {
+func (s *Service) Delete(dir, name string, opts DeleteOptions) error {
 	if err := s.CheckNotInWorkroom(dir); err != nil {
 		return err
 	}
@@ -361,9 +664,16 @@ func (s *Service) Delete(dir, name, confirmValue string) error {
 	if err := s.detectVCS(dir); err != nil {
 		return err
 	}
+	if err := s.checkUnlocked(dir, name); err != nil {
+		return err
+	}
 
 	if !s.Pretend {
-		exists, err := s.VCS.WorkroomExists(dir, name)
+		wrPath, err := s.recordedPath(dir, name)
+		if err != nil {
+			return err
+		}
+		exists, err := s.VCS.WorkroomExists(s.ctx(), dir, wrPath, s.vcsName(dir, name))
 		if err != nil {
 			return err
 		}
@@ -374,9 +684,13 @@ func (s *Service) Delete(dir, name, confirmValue string) error {
 			return fmt.Errorf("%w: %s '%s' does not exist", ErrGitWorktreeNotFound, s.VCS.Label(), name)
 		}
 
-		if confirmValue != "" {
-			if confirmValue != name {
-				return fmt.Errorf("--confirm value '%s' does not match workroom name '%s'", confirmValue, name)
+		if err := s.checkDeletable(dir, name, opts); err != nil {
+			return err
+		}
+
+		if opts.Confirm != "" {
+			if opts.Confirm != name {
+				return fmt.Errorf("--confirm value '%s' does not match workroom name '%s'", opts.Confirm, name)
 			}
 		} else {
 			confirmed, err := s.ConfirmFn(fmt.Sprintf("Are you sure you want to delete workroom '%s'?", name))
@@ -390,42 +704,67 @@ func (s *Service) Delete(dir, name, confirmValue string) error {
 		}
 	}
 
-	return s.deleteByName(dir, name)
+	return s.deleteByName(dir, name, opts)
 }
 
 // InteractiveDelete shows a multi-select prompt for deleting workrooms.
-func (s *Service) InteractiveDelete(dir string) error {
+func (s *Service) InteractiveDelete(dir string, opts DeleteOptions) error {
 	if err := s.CheckNotInWorkroom(dir); err != nil {
 		return err
 	}
 
-	_, project, found := s.Config.FindCurrentProject(dir)
-	if !found || project == nil {
+	_, project, err := s.Config.FindCurrentProject(dir)
+	if err != nil {
+		return err
+	}
+	if project == nil || len(project.Workrooms) == 0 {
 		s.say("No workrooms found for this project.")
 		return nil
 	}
 
-	workrooms, ok := project["workrooms"].(map[string]any)
-	if !ok || len(workrooms) == 0 {
-		s.say("No workrooms found for this project.")
-		return nil
+	if err := s.detectVCS(dir); err != nil {
+		return err
 	}
 
-	names := make([]string, 0, len(workrooms))
-	for name := range workrooms {
-		names = append(names, name)
+	// Label workrooms that have work which would be lost, and map labels back to names. Locked
+	// workrooms are left out.
+	labels := make([]string, 0, len(project.Workrooms))
+	namesByLabel := map[string]string{}
+	for name, entry := range project.Workrooms {
+		if entry.Lock != nil {
+			continue
+		}
+		label := name
+		if changes, err := s.workroomChanges(entry.Path); err == nil && !changes.Empty() {
+			label = fmt.Sprintf("%s (%s)", name, changes.Summary())
+		}
+		labels = append(labels, label)
+		namesByLabel[label] = name
 	}
 
-	selected, err := s.PromptFn("Select workrooms to delete:", names)
+	if len(labels) == 0 {
+		s.say("All workrooms of this project are locked.")
+		return nil
+	}
+
+	picked, err := s.PromptFn("Select workrooms to delete:", labels)
 	if err != nil {
 		return err
 	}
 
-	if len(selected) == 0 {
+	if len(picked) == 0 {
 		s.sayColor("Aborting. No workrooms were selected.", "yellow")
 		return nil
 	}
 
+	selected := make([]string, len(picked))
+	for i, label := range picked {
+		selected[i] = label
+		if name, ok := namesByLabel[label]; ok {
+			selected[i] = name
+		}
+	}
+
 	quotedNames := make([]string, len(selected))
 	for i, n := range selected {
 		quotedNames[i] = fmt.Sprintf("'%s'", n)
@@ -441,12 +780,16 @@ func (s *Service) InteractiveDelete(dir string) error {
 		return nil
 	}
 
-	if err := s.detectVCS(dir); err != nil {
-		return err
+	if !s.Pretend {
+		for _, name := range selected {
+			if err := s.checkDeletable(dir, name, opts); err != nil {
+				return err
+			}
+		}
 	}
 
 	for _, name := range selected {
-		if err := s.deleteByName(dir, name); err != nil {
+		if err := s.deleteByName(dir, name, opts); err != nil {
 			return err
 		}
 	}
@@ -454,30 +797,132 @@ func (s *Service) InteractiveDelete(dir string) error {
 	return nil
 }
 
-func (s *Service) deleteByName(dir, name string) error {
-	wrPath, err := s.workroomPath(name)
+// checkDeletable returns an error if deleting the named workroom would lose work that opts do
+// not allow to be lost.
+func (s *Service) checkDeletable(dir, name string, opts DeleteOptions) error {
+	if !opts.Force {
+		wrPath, err := s.recordedPath(dir, name)
+		if err != nil {
+			return err
+		}
+		changes, err := s.workroomChanges(wrPath)
+		if err != nil {
+			return fmt.Errorf("failed to inspect workroom '%s': %w. Use --force to delete it anyway", name, err)
+		}
+		if !changes.Empty() {
+			s.reportChanges(name, changes)
+			return fmt.Errorf("%w: '%s' has %s. Use --force to delete it anyway", ErrUnsavedChanges, name, changes.Summary())
+		}
+	}
+
+	if opts.KeepBranch || opts.ForceBranch || !s.ownsBranch(dir, name) {
+		return nil
+	}
+	settings, err := s.Config.Resolve(dir)
+	if err != nil {
+		return err
+	}
+	trunk := settings.Trunk
+	merged, err := s.VCS.BranchMerged(s.ctx(), dir, s.vcsName(dir, name), trunk)
 	if err != nil {
 		return err
 	}
+	if !merged {
+		if trunk == "" {
+			trunk = "trunk"
+		}
+		return fmt.Errorf("%w: '%s' has commits that are not in %s. Use --force-branch to delete it anyway, or --keep-branch to keep it", ErrBranchNotMerged, s.vcsName(dir, name), trunk)
+	}
+	return nil
+}
 
-	// Run teardown script
-	teardownScript := filepath.Join(dir, "scripts", "workroom_teardown")
-	var teardownOutput string
-	if _, err := os.Stat(teardownScript); err == nil {
-		s.sayStatus("teardown", fmt.Sprintf("Running %s from %q", teardownScript, wrPath))
-		if !s.Pretend {
-			var scriptErr error
-			teardownOutput, scriptErr = script.Run("teardown", teardownScript, wrPath, name, dir)
-			if scriptErr != nil {
-				return scriptErr
+// workroomChanges returns the work in the workroom at wrPath that would be lost by deleting it. A
+// missing directory has nothing to lose.
+func (s *Service) workroomChanges(wrPath string) (vcs.Changes, error) {
+	if _, err := os.Stat(wrPath); os.IsNotExist(err) {
+		return vcs.Changes{}, nil
+	}
+	return s.VCS.Changes(s.ctx(), wrPath)
+}
+
+// maxReportedChanges caps how many files or commits reportChanges lists per category.
+const maxReportedChanges = 10
+
+func (s *Service) reportChanges(name string, changes vcs.Changes) {
+	s.sayColor(fmt.Sprintf("Workroom '%s' has work that would be lost:", name), "yellow")
+	for _, group := range []struct {
+		title string
+		items []string
+	}{
+		{"Uncommitted changes", changes.Modified},
+		{"Untracked files", changes.Untracked},
+		{"Unpushed commits", changes.Unpushed},
+	} {
+		if len(group.items) == 0 {
+			continue
+		}
+		s.say(fmt.Sprintf("  %s:", group.title))
+		for i, item := range group.items {
+			if i == maxReportedChanges {
+				s.say(fmt.Sprintf("    ...and %d more", len(group.items)-maxReportedChanges))
+				break
 			}
+			s.say("    " + item)
+		}
+	}
+}
+
+func (s *Service) deleteByName(dir, name string, opts DeleteOptions) error {
+	if err := s.checkUnlocked(dir, name); err != nil {
+		return err
+	}
+	wrPath, err := s.recordedPath(dir, name)
+	if err != nil {
+		return err
+	}
+	branch := s.vcsName(dir, name)
+	// Branches that workroom did not create are left alone.
+	keepBranch := opts.KeepBranch || !s.ownsBranch(dir, name)
+	settings, err := s.Config.Resolve(dir)
+	if err != nil {
+		return err
+	}
+
+	// There is nowhere to run the pre_delete hook and teardown script when the directory is gone.
+	_, statErr := os.Stat(wrPath)
+	missing := os.IsNotExist(statErr)
+	vars := workroomVars(wrPath, branch, string(s.VCS.Type()))
+	if missing {
+		s.sayStatus("skip", fmt.Sprintf("pre_delete and teardown, as %s is not found", wrPath))
+	} else {
+		if err := s.runHook("pre_delete", dir, wrPath, name, settings, vars); err != nil {
+			return err
+		}
+		if err := s.runHook("teardown", dir, wrPath, name, settings, vars); err != nil {
+			return err
 		}
 	}
 
 	// Delete VCS workspace
+	if !keepBranch {
+		s.sayStatus("branch", fmt.Sprintf("Deleting %s", branch))
+	}
 	if !s.Pretend {
-		if _, err := s.VCS.Delete(dir, s.vcsName(name), wrPath); err != nil {
-			return fmt.Errorf("failed to delete workspace: %w", err)
+		if missing && s.VCS.Type() == vcs.TypeGit {
+			// Git refuses to remove a worktree whose directory is gone, so prune it instead.
+			if out, err := s.VCS.Forget(s.ctx(), dir, branch); err != nil {
+				return fmt.Errorf("failed to delete workspace: %w: %s", err, out)
+			}
+			if !keepBranch {
+				if out, err := s.VCS.DeleteBranch(s.ctx(), dir, branch); err != nil {
+					return fmt.Errorf("failed to delete branch: %w: %s", err, out)
+				}
+			}
+		} else {
+			deleteOpts := vcs.DeleteOptions{DeleteBranch: !keepBranch, Force: opts.ForceBranch}
+			if _, err := s.VCS.Delete(s.ctx(), dir, branch, wrPath, deleteOpts); err != nil {
+				return fmt.Errorf("failed to delete workspace: %w", err)
+			}
 		}
 	}
 
@@ -499,19 +944,16 @@ func (s *Service) deleteByName(dir, name string) error {
 		}
 	}
 
-	s.sayColor(fmt.Sprintf("Workroom '%s' deleted successfully.", name), "green")
+	// The workroom is gone, so the post_delete hook runs in the project.
+	postErr := s.runHook("post_delete", dir, dir, name, settings, vars)
 
-	if s.VCS.Type() == vcs.TypeGit {
-		s.say("")
-		s.say(fmt.Sprintf("Note: Git branch '%s' was not deleted.", s.vcsName(name)))
-		s.say(fmt.Sprintf("      Delete manually with `git branch -D %s` if needed.", s.vcsName(name)))
-	}
+	s.sayColor(fmt.Sprintf("Workroom '%s' deleted successfully.", name), "green")
 
-	if teardownOutput != "" {
+	if opts.KeepBranch && s.VCS.Type() == vcs.TypeGit {
 		s.say("")
-		s.sayColor("Teardown script output:", "blue")
-		s.say(strings.TrimSpace(teardownOutput))
+		s.say(fmt.Sprintf("Note: Git branch '%s' was not deleted.", branch))
+		s.say(fmt.Sprintf("      Delete manually with `git branch -D %s` if needed.", branch))
 	}
 
-	return nil
+	return postErr
 }
@@ -2,34 +2,55 @@ package workroom
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/joelmoss/workroom/internal/config"
+	"github.com/joelmoss/workroom/internal/errs"
 	"github.com/joelmoss/workroom/internal/vcs"
 )
 
 // mockExecutor returns canned VCS output for testing.
 type mockExecutor struct {
-	output string
-	err    error
-	calls  [][]string
-	onRun  func(dir, name string, args []string) // optional side effect
+	output  string
+	outputs map[string]string // output for commands starting with the key, overriding output
+	err     error
+	calls   [][]string
+	onRun   func(dir, name string, args []string) // optional side effect
+	mu      sync.Mutex
 }
 
-func (m *mockExecutor) Run(dir string, name string, args ...string) (string, error) {
+func (m *mockExecutor) Run(_ context.Context, dir string, name string, args ...string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	call := append([]string{name}, args...)
 	m.calls = append(m.calls, call)
 	if m.onRun != nil {
 		m.onRun(dir, name, args)
 	}
+	joined := strings.Join(call, " ")
+	for prefix, out := range m.outputs {
+		if strings.HasPrefix(joined, prefix) {
+			return out, m.err
+		}
+	}
 	return m.output, m.err
 }
 
+// cleanWorkroom makes the commands that inspect a workroom for unsaved work report nothing.
+var cleanWorkroom = map[string]string{"git status": "", "git remote": "", "jj diff": "", "jj log": ""}
+
 func newTestConfig(t *testing.T, path string) *config.Config {
 	t.Helper()
 	cfg, err := config.New(path)
@@ -39,7 +60,15 @@ func newTestConfig(t *testing.T, path string) *config.Config {
 	return cfg
 }
 
-func newTestService(t *testing.T, v vcs.VCS) (*Service, *bytes.Buffer, *config.Config) {
+// serviceOption sets up part of the Service made by newTestService.
+type serviceOption func(svc *Service)
+
+// withWorkroomsDir sets the workrooms_dir of the service's config.
+func withWorkroomsDir(dir string) serviceOption {
+	return func(svc *Service) { svc.Config.SetWorkroomsDir(dir) }
+}
+
+func newTestService(t *testing.T, v vcs.VCS, opts ...serviceOption) (*Service, *bytes.Buffer, *config.Config) {
 	t.Helper()
 	dir := t.TempDir()
 	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
@@ -51,9 +80,88 @@ func newTestService(t *testing.T, v vcs.VCS) (*Service, *bytes.Buffer, *config.C
 		ConfirmFn: func(string) (bool, error) { return true, nil },
 		PromptFn:  func(string, []string) ([]string, error) { return nil, nil },
 	}
+	for _, opt := range opts {
+		opt(svc)
+	}
 	return svc, &buf, cfg
 }
 
+// fixture is a Git project named "project" whose Git commands are answered by mock. Worktrees that
+// the commands add or move are applied to disk.
+type fixture struct {
+	cfg     *config.Config
+	mock    *mockExecutor
+	project string
+}
+
+// fixtureOption sets up part of the fixture made by newFixture.
+type fixtureOption func(f *fixture)
+
+// fixtureWorkroom is a workroom at its layout path. Recorded workrooms are on branch
+// workroom/<name>.
+type fixtureWorkroom struct {
+	name      string
+	recorded  bool // has a config entry
+	dir       bool // its directory exists
+	worktree  bool // its directory has a .git file
+	createdAt time.Time
+}
+
+func newFixture(t *testing.T, opts ...fixtureOption) (*Service, *bytes.Buffer, *config.Config, *mockExecutor, string) {
+	t.Helper()
+	project := filepath.Join(t.TempDir(), "project")
+	os.MkdirAll(filepath.Join(project, ".git"), 0o755)
+	mock := &mockExecutor{outputs: cleanWorkroom, onRun: func(_, name string, args []string) {
+		if name != "git" || len(args) < 3 || args[0] != "worktree" {
+			return
+		}
+		switch {
+		case args[1] == "add" && args[2] == "-b":
+			os.MkdirAll(args[4], 0o755)
+		case args[1] == "add":
+			os.MkdirAll(args[2], 0o755)
+		case args[1] == "move":
+			os.Rename(args[2], args[3])
+		}
+	}}
+	svc, buf, cfg := newTestService(t, &vcs.Git{Executor: mock}, withWorkroomsDir(t.TempDir()))
+	f := &fixture{cfg: cfg, mock: mock, project: project}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return svc, buf, cfg, mock, project
+}
+
+// path returns the layout path of the workroom called name.
+func (f *fixture) path(name string) string {
+	path, _ := f.cfg.WorkroomPath(f.project, name)
+	return path
+}
+
+// withWorkrooms sets up workrooms in the fixture.
+func withWorkrooms(workrooms ...fixtureWorkroom) fixtureOption {
+	return func(f *fixture) {
+		for _, wr := range workrooms {
+			if wr.dir {
+				os.MkdirAll(f.path(wr.name), 0o755)
+			}
+			if wr.worktree {
+				os.WriteFile(filepath.Join(f.path(wr.name), ".git"), []byte("gitdir: x"), 0o644)
+			}
+			if wr.recorded {
+				f.cfg.AddWorkroomEntry(f.project, "git", wr.name, config.WorkroomEntry{Path: f.path(wr.name), Branch: "workroom/" + wr.name, CreatedAt: wr.createdAt})
+			}
+		}
+	}
+}
+
+// withHooks writes hooks, a body of TOML, to the [hooks] table of the project config.
+func withHooks(hooks string) fixtureOption {
+	return func(f *fixture) {
+		os.WriteFile(filepath.Join(f.project, ".workroom.toml"), []byte("[hooks]\n"+hooks), 0o644)
+	}
+}
+
 // --- CheckNotInWorkroom ---
 
 func TestCheckNotInWorkroom(t *testing.T) {
@@ -85,7 +193,7 @@ func TestCreateErrorsIfNotJJOrGit(t *testing.T) {
 		Out:    &bytes.Buffer{},
 	}
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if !errors.Is(err, ErrUnsupportedVCS) {
 		t.Fatalf("expected ErrUnsupportedVCS, got %v", err)
 	}
@@ -96,7 +204,7 @@ func TestCreateErrorsIfInWorkroom(t *testing.T) {
 	os.WriteFile(filepath.Join(dir, ".Workroom"), []byte{}, 0o644)
 
 	svc := &Service{Out: &bytes.Buffer{}}
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if !errors.Is(err, ErrInWorkroom) {
 		t.Fatalf("expected ErrInWorkroom, got %v", err)
 	}
@@ -125,7 +233,7 @@ func TestCreateSucceedsJJ(t *testing.T) {
 		return "foo"
 	}
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -140,15 +248,14 @@ func TestCreateSucceedsJJ(t *testing.T) {
 	_ = data // config updated via svc.Config, not cfg
 
 	data2, _ := svc.Config.Read()
-	project := data2[dir].(map[string]any)
-	if project["vcs"] != "jj" {
-		t.Fatalf("expected vcs jj, got %v", project["vcs"])
+	project := data2.Projects[dir]
+	if project.VCS != "jj" {
+		t.Fatalf("expected vcs jj, got %v", project.VCS)
 	}
 
-	workrooms := project["workrooms"].(map[string]any)
-	foo := workrooms["foo"].(map[string]any)
-	if foo["path"] != filepath.Join(workroomsDir, "foo") {
-		t.Fatalf("expected workroom path, got %v", foo["path"])
+	foo := project.Workrooms["foo"]
+	if foo.Path != filepath.Join(workroomsDir, config.ProjectID(dir), "foo") {
+		t.Fatalf("expected workroom path, got %v", foo.Path)
 	}
 }
 
@@ -169,7 +276,7 @@ func TestCreateSucceedsGit(t *testing.T) {
 
 	svc.NameGenFunc = func() string { return "bar" }
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -180,6 +287,229 @@ func TestCreateSucceedsGit(t *testing.T) {
 	}
 }
 
+func TestCreateWithCdWritesPathToCdFile(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+
+	workroomsDir := filepath.Join(dir, "workrooms")
+
+	mock := &mockExecutor{
+		output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n",
+	}
+	git := &vcs.Git{Executor: mock}
+
+	svc, _, _ := newTestService(t, git)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.CdFile = filepath.Join(dir, "cd")
+
+	if err := svc.Create(dir, CreateOptions{Name: "bar", Cd: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := filepath.Join(workroomsDir, config.ProjectID(dir), "bar")
+	got, _ := os.ReadFile(svc.CdFile)
+	if string(got) != want {
+		t.Fatalf("expected %q in cd file, got %q", want, got)
+	}
+}
+
+func TestCreateIncludesGlobs(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, ".env.local"), []byte("LOCAL=1\n"), 0o600)
+	os.MkdirAll(filepath.Join(dir, "config", "keys"), 0o755)
+	os.WriteFile(filepath.Join(dir, "config", "keys", "master.key"), []byte("key"), 0o600)
+	os.MkdirAll(filepath.Join(dir, "node_modules"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".workroom.toml"), []byte(`
+include = [".env*", "hardlink:config/*", "symlink:node_modules", "reflink:*.db"]
+
+[hooks]
+setup = 'cat .env.local config/keys/master.key > hook.txt'
+`), 0o644)
+
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, config.ProjectID(dir), "bar")
+
+	mock := &mockExecutor{output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n"}
+	mock.onRun = func(_, name string, args []string) {
+		if name == "git" && args[0] == "worktree" && args[1] == "add" {
+			os.MkdirAll(wrPath, 0o755)
+		}
+	}
+	svc, buf, _ := newTestService(t, &vcs.Git{Executor: mock})
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Verbose = true
+
+	if err := svc.Create(dir, CreateOptions{Name: "bar"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, name := range []string{".env", ".env.local"} {
+		info, err := os.Lstat(filepath.Join(wrPath, name))
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm() != 0o600 {
+			t.Fatalf("expected %s to be copied with its mode, got %v (%v)", name, info, err)
+		}
+	}
+	src, _ := os.Stat(filepath.Join(dir, "config", "keys", "master.key"))
+	dst, err := os.Stat(filepath.Join(wrPath, "config", "keys", "master.key"))
+	if err != nil || !os.SameFile(src, dst) {
+		t.Fatalf("expected config/keys/master.key to be hard linked, got %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(wrPath, "node_modules")); err != nil || target != filepath.Join(dir, "node_modules") {
+		t.Fatalf("expected node_modules to be symlinked, got %q (%v)", target, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(wrPath, "hook.txt")); string(got) != "LOCAL=1\nkey" {
+		t.Fatalf("expected included files to exist before setup runs, got %q", got)
+	}
+	if _, err := os.Lstat(filepath.Join(wrPath, ".git")); err == nil {
+		t.Fatal("expected .git not to be included")
+	}
+	out := buf.String()
+	for _, want := range []string{"copy", ".env.local", "hardlink", filepath.Join("config", "keys"), `nothing in the project matches include pattern "*.db"`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got %q", want, out)
+		}
+	}
+}
+
+func TestCreateIncludesNothingInPretendMode(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, ".workroom.toml"), []byte(`include = ["symlink:.env"]`), 0o644)
+
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, config.ProjectID(dir), "bar")
+
+	mock := &mockExecutor{output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n"}
+	svc, buf, _ := newTestService(t, &vcs.Git{Executor: mock})
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Pretend = true
+	svc.Verbose = true
+
+	if err := svc.Create(dir, CreateOptions{Name: "bar"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(wrPath, ".env")); err == nil {
+		t.Fatal("expected nothing to be included in pretend mode")
+	}
+	if !strings.Contains(buf.String(), "symlink") || !strings.Contains(buf.String(), ".env") {
+		t.Fatalf("expected include to be reported, got %q", buf.String())
+	}
+}
+
+func TestCreateNeverReplacesCheckedOutFiles(t *testing.T) {
+	svc, buf, _, mock, project := newFixture(t)
+	wrPath, _ := svc.Config.WorkroomPath(project, "bar")
+	os.MkdirAll(filepath.Join(project, "config"), 0o755)
+	os.WriteFile(filepath.Join(project, "config", "app.yml"), []byte("edited"), 0o644)
+	os.WriteFile(filepath.Join(project, "config", "master.key"), []byte("key"), 0o600)
+	os.WriteFile(filepath.Join(project, "README"), []byte("edited"), 0o644)
+	os.WriteFile(filepath.Join(project, ".workroom.toml"), []byte(`
+copy = ["README", "config", ".git"]
+symlink = ["config"]
+include = ["symlink:README"]
+`), 0o644)
+	checkout := mock.onRun
+	mock.onRun = func(dir, name string, args []string) {
+		checkout(dir, name, args)
+		if args[0] == "worktree" && args[1] == "add" {
+			os.MkdirAll(filepath.Join(wrPath, "config"), 0o755)
+			os.WriteFile(filepath.Join(wrPath, "config", "app.yml"), []byte("checked out"), 0o644)
+			os.WriteFile(filepath.Join(wrPath, "README"), []byte("checked out"), 0o644)
+		}
+	}
+
+	if err := svc.Create(project, CreateOptions{Name: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"README", filepath.Join("config", "app.yml")} {
+		if got, _ := os.ReadFile(filepath.Join(wrPath, name)); string(got) != "checked out" {
+			t.Fatalf("expected the checked out %s to be kept, got %q", name, got)
+		}
+	}
+	if info, err := os.Lstat(filepath.Join(wrPath, "config")); err != nil || !info.IsDir() {
+		t.Fatalf("expected config not to be replaced by a symlink, got %v (%v)", info, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(wrPath, "config", "master.key")); string(got) != "key" {
+		t.Fatalf("expected the files config lacks to be copied in, got %q", got)
+	}
+	if _, err := os.Lstat(filepath.Join(wrPath, ".git")); err == nil {
+		t.Fatal("expected .git not to be copied")
+	}
+	for _, want := range []string{"not copying README, as the workroom already has it", "not symlinking config, as the workroom already has it", "not symlinking README"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in output, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestCreateUsesProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1\n"), 0o600)
+	os.MkdirAll(filepath.Join(dir, "node_modules"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".workroom.toml"), []byte(`
+branch_prefix = "feature/"
+copy = [".env", "missing.txt"]
+symlink = ["node_modules"]
+
+[env]
+GREETING = "hello"
+
+[hooks]
+setup = 'echo "$GREETING $WORKROOM_NAME" > hook.txt'
+`), 0o644)
+
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, config.ProjectID(dir), "bar")
+
+	mock := &mockExecutor{output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n"}
+	mock.onRun = func(_, name string, args []string) {
+		if name == "git" && args[0] == "worktree" && args[1] == "add" {
+			os.MkdirAll(wrPath, 0o755)
+		}
+	}
+	svc, buf, _ := newTestService(t, &vcs.Git{Executor: mock})
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+
+	if err := svc.Create(dir, CreateOptions{Name: "bar"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var created bool
+	for _, call := range mock.calls {
+		if strings.Join(call, " ") == "git worktree add -b feature/bar "+wrPath {
+			created = true
+		}
+	}
+	if !created {
+		t.Fatalf("expected branch with configured prefix, got calls %v", mock.calls)
+	}
+	if got, _ := os.ReadFile(filepath.Join(wrPath, ".env")); string(got) != "SECRET=1\n" {
+		t.Fatalf("expected .env to be copied, got %q", got)
+	}
+	if target, err := os.Readlink(filepath.Join(wrPath, "node_modules")); err != nil || target != filepath.Join(dir, "node_modules") {
+		t.Fatalf("expected node_modules to be symlinked, got %q (%v)", target, err)
+	}
+	if !strings.Contains(buf.String(), "not copying missing.txt") {
+		t.Fatalf("expected warning for missing file, got %q", buf.String())
+	}
+	if got, _ := os.ReadFile(filepath.Join(wrPath, "hook.txt")); string(got) != "hello bar\n" {
+		t.Fatalf("expected setup hook to run with env, got %q", got)
+	}
+
+	data, _ := svc.Config.Read()
+	if branch := data.Projects[dir].Workrooms["bar"].Branch; branch != "feature/bar" {
+		t.Fatalf("expected branch to be recorded, got %q", branch)
+	}
+}
+
 func TestCreateRunsSetupScript(t *testing.T) {
 	dir := t.TempDir()
 	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
@@ -207,7 +537,7 @@ func TestCreateRunsSetupScript(t *testing.T) {
 	svc.Config.SetWorkroomsDir(workroomsDir)
 	svc.NameGenFunc = func() string { return "foo" }
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -246,7 +576,7 @@ func TestCreateErrorsOnFailedSetupScript(t *testing.T) {
 	svc.Config.SetWorkroomsDir(workroomsDir)
 	svc.NameGenFunc = func() string { return "foo" }
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -255,6 +585,135 @@ func TestCreateErrorsOnFailedSetupScript(t *testing.T) {
 	}
 }
 
+func TestCreateRollsBackOnFailedSetupScript(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, config.ProjectID(dir), "foo")
+
+	scriptsDir := filepath.Join(dir, "scripts")
+	os.MkdirAll(scriptsDir, 0o755)
+	os.WriteFile(filepath.Join(scriptsDir, "workroom_setup"), []byte("#!/usr/bin/env bash\nexit 1\n"), 0o755)
+
+	mock := &mockExecutor{
+		output: "default: mk 6ec05f05 (no description set)",
+		onRun: func(dir, name string, args []string) {
+			if name == "jj" && len(args) > 1 && args[0] == "workspace" && args[1] == "add" {
+				os.MkdirAll(args[2], 0o755)
+			}
+		},
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, buf, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.NameGenFunc = func() string { return "foo" }
+
+	err := svc.Create(dir, CreateOptions{})
+	if !errors.Is(err, ErrSetup) {
+		t.Fatalf("expected ErrSetup, got %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Rolling back workroom 'foo'") {
+		t.Fatalf("expected rollback message, got %q", buf.String())
+	}
+
+	var calls []string
+	for _, c := range mock.calls {
+		calls = append(calls, strings.Join(c, " "))
+	}
+	joined := strings.Join(calls, "\n")
+	if !strings.Contains(joined, "jj abandon workroom/foo@\njj workspace forget workroom/foo") {
+		t.Fatalf("expected workspace to be abandoned and forgotten, got %v", calls)
+	}
+
+	if _, err := os.Stat(wrPath); !os.IsNotExist(err) {
+		t.Fatal("expected workroom directory to be removed")
+	}
+
+	data, _ := svc.Config.Read()
+	if _, ok := data.Projects[dir]; ok {
+		t.Fatal("expected config entry to be removed")
+	}
+}
+
+func TestCreateRollsBackGitBranch(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+
+	scriptsDir := filepath.Join(dir, "scripts")
+	os.MkdirAll(scriptsDir, 0o755)
+	os.WriteFile(filepath.Join(scriptsDir, "workroom_setup"), []byte("#!/usr/bin/env bash\nexit 1\n"), 0o755)
+
+	mock := &mockExecutor{
+		output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n",
+		onRun: func(dir, name string, args []string) {
+			if name == "git" && len(args) > 1 && args[0] == "worktree" && args[1] == "add" {
+				os.MkdirAll(args[len(args)-1], 0o755)
+			}
+		},
+	}
+	git := &vcs.Git{Executor: mock}
+
+	svc, _, _ := newTestService(t, git)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+
+	err := svc.Create(dir, CreateOptions{Name: "foo"})
+	if !errors.Is(err, ErrSetup) {
+		t.Fatalf("expected ErrSetup, got %v", err)
+	}
+
+	last := strings.Join(mock.calls[len(mock.calls)-1], " ")
+	if last != "git branch -D workroom/foo" {
+		t.Fatalf("expected branch to be deleted, got %q", last)
+	}
+}
+
+func TestCreateKeepOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, config.ProjectID(dir), "foo")
+
+	scriptsDir := filepath.Join(dir, "scripts")
+	os.MkdirAll(scriptsDir, 0o755)
+	os.WriteFile(filepath.Join(scriptsDir, "workroom_setup"), []byte("#!/usr/bin/env bash\nexit 1\n"), 0o755)
+
+	mock := &mockExecutor{
+		output: "default: mk 6ec05f05 (no description set)",
+		onRun: func(dir, name string, args []string) {
+			if name == "jj" && len(args) > 1 && args[0] == "workspace" && args[1] == "add" {
+				os.MkdirAll(args[2], 0o755)
+			}
+		},
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, buf, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.NameGenFunc = func() string { return "foo" }
+
+	err := svc.Create(dir, CreateOptions{KeepOnFailure: true})
+	if !errors.Is(err, ErrSetup) {
+		t.Fatalf("expected ErrSetup, got %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Keeping workroom 'foo'") {
+		t.Fatalf("expected keep message, got %q", buf.String())
+	}
+	if _, err := os.Stat(wrPath); err != nil {
+		t.Fatalf("expected workroom directory to be kept: %v", err)
+	}
+	data, _ := svc.Config.Read()
+	if _, ok := data.Projects[dir]; !ok {
+		t.Fatal("expected config entry to be kept")
+	}
+}
+
 func TestCreateRetriesOnNameCollisionWorkspace(t *testing.T) {
 	dir := t.TempDir()
 	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
@@ -278,7 +737,7 @@ func TestCreateRetriesOnNameCollisionWorkspace(t *testing.T) {
 		return "fresh"
 	}
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -293,7 +752,7 @@ func TestCreateRetriesOnNameCollisionDirectory(t *testing.T) {
 	dir := t.TempDir()
 	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
 	workroomsDir := filepath.Join(dir, "workrooms")
-	os.MkdirAll(filepath.Join(workroomsDir, "taken"), 0o755)
+	os.MkdirAll(filepath.Join(workroomsDir, config.ProjectID(dir), "taken"), 0o755)
 
 	mock := &mockExecutor{
 		output: "default: mk 6ec05f05 (no description set)",
@@ -313,7 +772,7 @@ func TestCreateRetriesOnNameCollisionDirectory(t *testing.T) {
 		return "fresh"
 	}
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -350,10 +809,10 @@ func TestCreateErrorsAfterTooManyNameCollisions(t *testing.T) {
 
 	// Pre-create directories for all possible suffixed names (taken-10 through taken-99)
 	for i := 10; i <= 99; i++ {
-		os.MkdirAll(filepath.Join(workroomsDir, fmt.Sprintf("taken-%d", i)), 0o755)
+		os.MkdirAll(filepath.Join(workroomsDir, config.ProjectID(dir), fmt.Sprintf("taken-%d", i)), 0o755)
 	}
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -362,7 +821,7 @@ func TestCreateErrorsAfterTooManyNameCollisions(t *testing.T) {
 	}
 }
 
-func TestCreateUpdatesConfig(t *testing.T) {
+func TestCreateWithName(t *testing.T) {
 	dir := t.TempDir()
 	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
 	workroomsDir := filepath.Join(dir, "workrooms")
@@ -372,47 +831,195 @@ func TestCreateUpdatesConfig(t *testing.T) {
 	}
 	jj := &vcs.JJ{Executor: mock}
 
-	svc, _, _ := newTestService(t, jj)
+	svc, buf, _ := newTestService(t, jj)
 	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
 	svc.Config.SetWorkroomsDir(workroomsDir)
-	svc.NameGenFunc = func() string { return "foo" }
+	svc.NameGenFunc = func() string {
+		t.Fatal("name generator should not be called when a name is given")
+		return ""
+	}
 
-	err := svc.Create(dir)
+	err := svc.Create(dir, CreateOptions{Name: "fix-login-timeout"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	data, _ := svc.Config.Read()
-	project := data[dir].(map[string]any)
-	if project["vcs"] != "jj" {
-		t.Fatalf("expected vcs jj, got %v", project["vcs"])
+	output := buf.String()
+	if !strings.Contains(output, "Workroom 'fix-login-timeout' created successfully") {
+		t.Fatalf("expected success message, got %q", output)
 	}
-	workrooms := project["workrooms"].(map[string]any)
-	foo := workrooms["foo"].(map[string]any)
-	if foo["path"] != filepath.Join(workroomsDir, "foo") {
-		t.Fatalf("expected workroom path, got %v", foo["path"])
+
+	expected := []string{"jj", "workspace", "add", filepath.Join(workroomsDir, config.ProjectID(dir), "fix-login-timeout"), "--name", "workroom/fix-login-timeout"}
+	last := mock.calls[len(mock.calls)-1]
+	for i, v := range expected {
+		if last[i] != v {
+			t.Fatalf("expected %s at position %d, got %s", v, i, last[i])
+		}
 	}
 }
 
-// --- List ---
-
-func TestListWorkroomsForCurrentProject(t *testing.T) {
+func TestCreateWithInvalidName(t *testing.T) {
 	dir := t.TempDir()
-	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
-	fooDir := filepath.Join(dir, "foo")
-	barDir := filepath.Join(dir, "bar")
-	os.MkdirAll(fooDir, 0o755)
-	os.MkdirAll(barDir, 0o755)
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
 
-	cfg.AddWorkroom(dir, "foo", fooDir, "jj")
-	cfg.AddWorkroom(dir, "bar", barDir, "jj")
+	mock := &mockExecutor{}
+	jj := &vcs.JJ{Executor: mock}
 
-	var buf bytes.Buffer
-	svc := &Service{Config: cfg, Out: &buf}
+	svc, _, _ := newTestService(t, jj)
 
-	err := svc.List(dir)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	err := svc.Create(dir, CreateOptions{Name: "-foo"})
+	if !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if len(mock.calls) != 0 {
+		t.Fatalf("expected no VCS calls, got %v", mock.calls)
+	}
+}
+
+func TestCreateWithExistingName(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+
+	mock := &mockExecutor{
+		output: "default: mk 6ec05f05 (no description set)\nworkroom/taken: qo a41890ed (empty) (no description set)\n",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, _, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+
+	err := svc.Create(dir, CreateOptions{Name: "taken"})
+	if !errors.Is(err, ErrJJWorkspaceExists) {
+		t.Fatalf("expected ErrJJWorkspaceExists, got %v", err)
+	}
+}
+
+func TestCreateWithExistingDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	os.MkdirAll(filepath.Join(workroomsDir, config.ProjectID(dir), "taken"), 0o755)
+
+	mock := &mockExecutor{
+		output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n",
+	}
+	git := &vcs.Git{Executor: mock}
+
+	svc, _, _ := newTestService(t, git)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+
+	err := svc.Create(dir, CreateOptions{Name: "taken"})
+	if !errors.Is(err, ErrDirExists) {
+		t.Fatalf("expected ErrDirExists, got %v", err)
+	}
+}
+
+func TestCreateFromExistingBranch(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+
+	mock := &mockExecutor{
+		output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n",
+	}
+	git := &vcs.Git{Executor: mock}
+
+	svc, _, _ := newTestService(t, git)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+
+	err := svc.Create(dir, CreateOptions{Name: "login", From: "feature/login"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	last := mock.calls[len(mock.calls)-1]
+	expected := []string{"git", "worktree", "add", filepath.Join(workroomsDir, config.ProjectID(dir), "login"), "feature/login"}
+	if strings.Join(last, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected %v, got %v", expected, last)
+	}
+
+	// The branch that was checked out is recorded, rather than one named after the workroom.
+	data, _ := svc.Config.Read()
+	if branch := data.Projects[dir].Workrooms["login"].Branch; branch != "feature/login" {
+		t.Fatalf("expected the checked out branch to be recorded, got %q", branch)
+	}
+	if got := svc.vcsName(dir, "login"); got != "feature/login" {
+		t.Fatalf("expected vcsName feature/login, got %q", got)
+	}
+	if !data.Projects[dir].Workrooms["login"].ExternalBranch {
+		t.Fatal("expected the checked out branch to be marked as external")
+	}
+}
+
+func TestCreateFromAndBaseErrors(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+
+	mock := &mockExecutor{}
+	git := &vcs.Git{Executor: mock}
+
+	svc, _, _ := newTestService(t, git)
+
+	err := svc.Create(dir, CreateOptions{From: "main", Base: "main"})
+	if !errors.Is(err, ErrFromAndBase) {
+		t.Fatalf("expected ErrFromAndBase, got %v", err)
+	}
+}
+
+func TestCreateUpdatesConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+
+	mock := &mockExecutor{
+		output: "default: mk 6ec05f05 (no description set)",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, _, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.NameGenFunc = func() string { return "foo" }
+
+	err := svc.Create(dir, CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := svc.Config.Read()
+	project := data.Projects[dir]
+	if project.VCS != "jj" {
+		t.Fatalf("expected vcs jj, got %v", project.VCS)
+	}
+	foo := project.Workrooms["foo"]
+	if foo.Path != filepath.Join(workroomsDir, config.ProjectID(dir), "foo") {
+		t.Fatalf("expected workroom path, got %v", foo.Path)
+	}
+}
+
+// --- List ---
+
+func TestListWorkroomsForCurrentProject(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	fooDir := filepath.Join(dir, "foo")
+	barDir := filepath.Join(dir, "bar")
+	os.MkdirAll(fooDir, 0o755)
+	os.MkdirAll(barDir, 0o755)
+
+	cfg.AddWorkroom(dir, "foo", fooDir, "jj")
+	cfg.AddWorkroom(dir, "bar", barDir, "jj")
+
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
+
+	err := svc.List(dir, ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
 	output := buf.String()
@@ -432,7 +1039,7 @@ func TestListWarnsWhenDirNotFound(t *testing.T) {
 	var buf bytes.Buffer
 	svc := &Service{Config: cfg, Out: &buf}
 
-	err := svc.List(dir)
+	err := svc.List(dir, ListOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -453,7 +1060,7 @@ func TestListNoWarningWhenDirExists(t *testing.T) {
 	var buf bytes.Buffer
 	svc := &Service{Config: cfg, Out: &buf}
 
-	err := svc.List(dir)
+	err := svc.List(dir, ListOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -482,7 +1089,7 @@ func TestListAllGroupedByParent(t *testing.T) {
 	// cwd is not a known project
 	unknownDir := filepath.Join(dir, "unknown")
 	os.MkdirAll(unknownDir, 0o755)
-	err := svc.List(unknownDir)
+	err := svc.List(unknownDir, ListOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -503,7 +1110,7 @@ func TestListNoWorkroomsAnywhere(t *testing.T) {
 	var buf bytes.Buffer
 	svc := &Service{Config: cfg, Out: &buf}
 
-	err := svc.List(dir)
+	err := svc.List(dir, ListOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -524,7 +1131,7 @@ func TestListInsideWorkroom(t *testing.T) {
 	var buf bytes.Buffer
 	svc := &Service{Config: cfg, Out: &buf}
 
-	err := svc.List(wrDir)
+	err := svc.List(wrDir, ListOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -538,167 +1145,459 @@ func TestListInsideWorkroom(t *testing.T) {
 	}
 }
 
-// --- Delete ---
-
-func TestDeleteInvalidName(t *testing.T) {
+func TestListJSON(t *testing.T) {
 	dir := t.TempDir()
-	mock := &mockExecutor{}
-	jj := &vcs.JJ{Executor: mock}
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	fooDir := filepath.Join(dir, "foo")
+	os.MkdirAll(fooDir, 0o755)
+	cfg.AddWorkroom(dir, "foo", fooDir, "git")
+	cfg.AddWorkroom(dir, "bar", "/nonexistent", "git")
 
-	svc, _, _ := newTestService(t, jj)
-	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
 
-	err := svc.Delete(dir, "fo.o", "")
-	if !errors.Is(err, ErrInvalidName) {
-		t.Fatalf("expected ErrInvalidName, got %v", err)
+	if err := svc.List(dir, ListOptions{Format: ListFormatJSON}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var result struct {
+		SchemaVersion int            `json:"schema_version"`
+		Workrooms     []WorkroomInfo `json:"workrooms"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if result.SchemaVersion != ListSchemaVersion {
+		t.Fatalf("expected schema version %d, got %d", ListSchemaVersion, result.SchemaVersion)
+	}
+	if len(result.Workrooms) != 2 {
+		t.Fatalf("expected 2 workrooms, got %v", result.Workrooms)
+	}
+
+	bar, foo := result.Workrooms[0], result.Workrooms[1]
+	if bar.Name != "bar" || foo.Name != "foo" {
+		t.Fatalf("expected workrooms sorted by name, got %v", result.Workrooms)
+	}
+	if foo.Project != dir || foo.Path != fooDir || foo.VCS != "git" || foo.Branch != "workroom/foo" {
+		t.Fatalf("unexpected workroom info %+v", foo)
+	}
+	if foo.CreatedAt == nil {
+		t.Fatal("expected created_at")
+	}
+	if len(bar.Warnings) != 1 || bar.Warnings[0] != "directory not found" {
+		t.Fatalf("expected directory warning, got %v", bar.Warnings)
 	}
 }
 
-func TestDeleteErrorsIfNotJJOrGit(t *testing.T) {
+func TestListNDJSON(t *testing.T) {
 	dir := t.TempDir()
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	cfg.AddWorkroom("/project1", "foo", filepath.Join(dir, "foo"), "git")
+	cfg.AddWorkroom("/project2", "bar", filepath.Join(dir, "bar"), "jj")
 
-	svc := &Service{
-		Config: newTestConfig(t, filepath.Join(dir, "config.json")),
-		Out:    &bytes.Buffer{},
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
+
+	if err := svc.List(dir, ListOptions{Format: ListFormatNDJSON}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	err := svc.Delete(dir, "foo", "")
-	if !errors.Is(err, ErrUnsupportedVCS) {
-		t.Fatalf("expected ErrUnsupportedVCS, got %v", err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		if entry["schema_version"] != float64(ListSchemaVersion) {
+			t.Fatalf("expected schema_version on each line, got %v", entry)
+		}
+	}
+	if !strings.Contains(lines[0], `"project":"/project1"`) {
+		t.Fatalf("expected projects in sorted order, got %q", lines[0])
 	}
 }
 
-func TestDeleteErrorsIfJJWorkspaceNotFound(t *testing.T) {
+func TestListJSONEmpty(t *testing.T) {
 	dir := t.TempDir()
-	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
-
-	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)",
-	}
-	jj := &vcs.JJ{Executor: mock}
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
 
-	svc, _, _ := newTestService(t, jj)
-	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
 
-	err := svc.Delete(dir, "foo", "foo")
-	if !errors.Is(err, ErrJJWorkspaceNotFound) {
-		t.Fatalf("expected ErrJJWorkspaceNotFound, got %v", err)
+	if err := svc.List(dir, ListOptions{Format: ListFormatJSON}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"workrooms": []`) {
+		t.Fatalf("expected empty workrooms array, got %q", buf.String())
 	}
 }
 
-func TestDeleteErrorsIfGitWorktreeNotFound(t *testing.T) {
+func TestListColumns(t *testing.T) {
 	dir := t.TempDir()
-	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	wrDir := filepath.Join(dir, "foo")
+	os.MkdirAll(wrDir, 0o755)
+	mock := &mockExecutor{outputs: map[string]string{
+		"git status":   "## workroom/foo...origin/workroom/foo\n M a.go\n?? b.go",
+		"git log":      "abc1234\x1fFix the thing\x1f1700000000",
+		"git rev-list": "3\t2",
+	}}
+	svc, buf, cfg := newTestService(t, &vcs.Git{Executor: mock})
+	cfg.AddWorkroom(dir, "foo", wrDir, "git")
+
+	if err := svc.List(dir, ListOptions{Columns: []string{"name", "branch", "dirty", "sync", "commit"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
-	mock := &mockExecutor{
-		output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n",
+	output := buf.String()
+	for _, want := range []string{"BRANCH", "DIRTY", "workroom/foo", "2 dirty", "+2 -3", "Fix the thing"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output, got %q", want, output)
+		}
 	}
-	git := &vcs.Git{Executor: mock}
+}
 
-	svc, _, _ := newTestService(t, git)
-	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+func TestListTruncatesSubjectByRunes(t *testing.T) {
+	st := workroomStatus{Status: vcs.Status{CommitSubject: strings.Repeat("é", maxSubjectLen+10)}, LastModified: time.Now()}
 
-	err := svc.Delete(dir, "foo", "foo")
-	if !errors.Is(err, ErrGitWorktreeNotFound) {
-		t.Fatalf("expected ErrGitWorktreeNotFound, got %v", err)
+	got := listCell("commit", WorkroomInfo{}, []workroomStatus{st}, 0)
+	if !utf8.ValidString(got) || utf8.RuneCountInString(got) != maxSubjectLen || !strings.HasSuffix(got, "...") {
+		t.Fatalf("expected subject truncated to %d characters, got %q", maxSubjectLen, got)
 	}
 }
 
-func TestDeleteErrorsIfInWorkroom(t *testing.T) {
+func TestListUnknownColumn(t *testing.T) {
 	dir := t.TempDir()
-	os.WriteFile(filepath.Join(dir, ".Workroom"), []byte{}, 0o644)
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
 
-	svc := &Service{Out: &bytes.Buffer{}}
-	err := svc.Delete(dir, "foo", "")
-	if !errors.Is(err, ErrInWorkroom) {
-		t.Fatalf("expected ErrInWorkroom, got %v", err)
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
+
+	err := svc.List(dir, ListOptions{Columns: []string{"name", "bogus"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown column "bogus"`) {
+		t.Fatalf("expected unknown column error, got %v", err)
 	}
 }
 
-func TestDeleteSucceeds(t *testing.T) {
-	dir := t.TempDir()
-	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
-	workroomsDir := filepath.Join(dir, "workrooms")
-	wrPath := filepath.Join(workroomsDir, "foo")
-	os.MkdirAll(wrPath, 0o755)
+// --- Cd ---
 
-	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
-	}
-	jj := &vcs.JJ{Executor: mock}
+func TestCdWritesPathToCdFile(t *testing.T) {
+	dir := t.TempDir()
+	wrDir := filepath.Join(dir, "foo")
+	os.MkdirAll(wrDir, 0o755)
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	cfg.AddWorkroom(dir, "foo", wrDir, "git")
 
-	svc, buf, _ := newTestService(t, jj)
-	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
-	svc.Config.SetWorkroomsDir(workroomsDir)
-	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
+	cdFile := filepath.Join(dir, "cd")
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf, CdFile: cdFile}
 
-	err := svc.Delete(dir, "foo", "foo")
-	if err != nil {
+	if err := svc.Cd(dir, "foo"); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-
-	output := buf.String()
-	if !strings.Contains(output, "Workroom 'foo' deleted successfully.") {
-		t.Fatalf("expected success message, got %q", output)
-	}
-
-	// JJ cleanup should remove directory
-	if _, err := os.Stat(wrPath); !os.IsNotExist(err) {
-		t.Fatal("expected directory to be removed")
+	got, _ := os.ReadFile(cdFile)
+	if string(got) != wrDir {
+		t.Fatalf("expected %q in cd file, got %q", wrDir, got)
 	}
 }
 
-func TestDeleteUpdatesConfig(t *testing.T) {
+func TestCdPrintsPathWithoutShellIntegration(t *testing.T) {
 	dir := t.TempDir()
-	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
-	workroomsDir := filepath.Join(dir, "workrooms")
-	wrPath := filepath.Join(workroomsDir, "foo")
-	os.MkdirAll(wrPath, 0o755)
-
-	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
-	}
-	jj := &vcs.JJ{Executor: mock}
+	wrDir := filepath.Join(dir, "foo")
+	os.MkdirAll(wrDir, 0o755)
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	cfg.AddWorkroom(dir, "foo", wrDir, "git")
 
-	svc, _, _ := newTestService(t, jj)
-	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
-	svc.Config.SetWorkroomsDir(workroomsDir)
-	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
 
-	err := svc.Delete(dir, "foo", "foo")
-	if err != nil {
+	if err := svc.Cd(dir, "foo"); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-
-	data, _ := svc.Config.Read()
-	if _, ok := data[dir]; ok {
-		t.Fatal("expected project to be removed from config")
+	if buf.String() != wrDir+"\n" {
+		t.Fatalf("expected path output, got %q", buf.String())
 	}
 }
 
-func TestDeleteConfirmSkipsPrompt(t *testing.T) {
+func TestCdUnknownWorkroom(t *testing.T) {
 	dir := t.TempDir()
-	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
-	workroomsDir := filepath.Join(dir, "workrooms")
-	wrPath := filepath.Join(workroomsDir, "foo")
-	os.MkdirAll(wrPath, 0o755)
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	cfg.AddWorkroom(dir, "foo", filepath.Join(dir, "foo"), "git")
 
-	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+	svc := &Service{Config: cfg, Out: &bytes.Buffer{}}
+
+	err := svc.Cd(dir, "bar")
+	if !errors.Is(err, ErrWorkroomNotFound) {
+		t.Fatalf("expected ErrWorkroomNotFound, got %v", err)
 	}
-	jj := &vcs.JJ{Executor: mock}
+}
 
-	confirmCalled := false
-	svc, _, _ := newTestService(t, jj)
-	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
-	svc.Config.SetWorkroomsDir(workroomsDir)
+func TestCdPicksWorkroomWhenNoName(t *testing.T) {
+	dir := t.TempDir()
+	fooDir := filepath.Join(dir, "foo")
+	barDir := filepath.Join(dir, "bar")
+	os.MkdirAll(fooDir, 0o755)
+	os.MkdirAll(barDir, 0o755)
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	cfg.AddWorkroom(dir, "foo", fooDir, "git")
+	cfg.AddWorkroom(dir, "bar", barDir, "git")
+
+	cdFile := filepath.Join(dir, "cd")
+	var offered []string
+	svc := &Service{
+		Config: cfg,
+		Out:    &bytes.Buffer{},
+		CdFile: cdFile,
+		SelectFn: func(_ string, options []string) (string, error) {
+			offered = options
+			return "bar", nil
+		},
+	}
+
+	if err := svc.Cd(dir, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(offered, []string{"bar", "foo"}) {
+		t.Fatalf("expected [bar foo] to be offered, got %v", offered)
+	}
+	got, _ := os.ReadFile(cdFile)
+	if string(got) != barDir {
+		t.Fatalf("expected %q in cd file, got %q", barDir, got)
+	}
+}
+
+// --- Exec ---
+
+func TestExecRunsInWorkroomWithEnv(t *testing.T) {
+	dir := t.TempDir()
+	wrDir := filepath.Join(dir, "foo")
+	os.MkdirAll(wrDir, 0o755)
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	cfg.AddWorkroom(dir, "foo", wrDir, "git")
+
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
+
+	err := svc.Exec(dir, "foo", []string{"sh", "-c", `echo "$WORKROOM_NAME $WORKROOM_PARENT_DIR $(pwd)"`}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf("foo %s %s\n", dir, wrDir)
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestExecPropagatesExitCode(t *testing.T) {
+	dir := t.TempDir()
+	wrDir := filepath.Join(dir, "foo")
+	os.MkdirAll(wrDir, 0o755)
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	cfg.AddWorkroom(dir, "foo", wrDir, "git")
+
+	svc := &Service{Config: cfg, Out: &bytes.Buffer{}}
+
+	err := svc.Exec(dir, "foo", []string{"sh", "-c", "exit 3"}, ExecOptions{})
+	var exitErr *errs.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+}
+
+func TestExecAllPrefixesOutputAndSummarizes(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	for _, name := range []string{"foo", "bar"} {
+		os.MkdirAll(filepath.Join(dir, name), 0o755)
+		cfg.AddWorkroom(dir, name, filepath.Join(dir, name), "git")
+	}
+	cfg.AddWorkroom(dir, "gone", filepath.Join(dir, "gone"), "git")
+
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
+
+	err := svc.Exec(dir, "", []string{"sh", "-c", `echo "hello $WORKROOM_NAME"; [ "$WORKROOM_NAME" = foo ]`}, ExecOptions{All: true, Jobs: 2})
+	var exitErr *errs.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit error, got %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{"foo  | hello foo", "bar  | hello bar", "failed (exit status 1)", "skipped (directory not found)", "Succeeded in 1 of 3 workrooms."} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output, got %q", want, output)
+		}
+	}
+}
+
+// --- Delete ---
+
+func TestDeleteInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	mock := &mockExecutor{}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, _, _ := newTestService(t, jj)
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+
+	err := svc.Delete(dir, "fo.o", DeleteOptions{})
+	if !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+}
+
+func TestDeleteErrorsIfNotJJOrGit(t *testing.T) {
+	dir := t.TempDir()
+
+	svc := &Service{
+		Config: newTestConfig(t, filepath.Join(dir, "config.json")),
+		Out:    &bytes.Buffer{},
+	}
+
+	err := svc.Delete(dir, "foo", DeleteOptions{})
+	if !errors.Is(err, ErrUnsupportedVCS) {
+		t.Fatalf("expected ErrUnsupportedVCS, got %v", err)
+	}
+}
+
+func TestDeleteErrorsIfJJWorkspaceNotFound(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, _, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
+	if !errors.Is(err, ErrJJWorkspaceNotFound) {
+		t.Fatalf("expected ErrJJWorkspaceNotFound, got %v", err)
+	}
+}
+
+func TestDeleteErrorsIfGitWorktreeNotFound(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n",
+	}
+	git := &vcs.Git{Executor: mock}
+
+	svc, _, _ := newTestService(t, git)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
+	if !errors.Is(err, ErrGitWorktreeNotFound) {
+		t.Fatalf("expected ErrGitWorktreeNotFound, got %v", err)
+	}
+}
+
+func TestDeleteErrorsIfInWorkroom(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".Workroom"), []byte{}, 0o644)
+
+	svc := &Service{Out: &bytes.Buffer{}}
+	err := svc.Delete(dir, "foo", DeleteOptions{})
+	if !errors.Is(err, ErrInWorkroom) {
+		t.Fatalf("expected ErrInWorkroom, got %v", err)
+	}
+}
+
+func TestDeleteSucceeds(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, buf, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
+
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "Workroom 'foo' deleted successfully.") {
+		t.Fatalf("expected success message, got %q", output)
+	}
+
+	// JJ cleanup should remove directory
+	if _, err := os.Stat(wrPath); !os.IsNotExist(err) {
+		t.Fatal("expected directory to be removed")
+	}
+}
+
+func TestDeleteUpdatesConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, _, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
+
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := svc.Config.Read()
+	if _, ok := data.Projects[dir]; ok {
+		t.Fatal("expected project to be removed from config")
+	}
+}
+
+func TestDeleteConfirmSkipsPrompt(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	confirmCalled := false
+	svc, _, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
 	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
 	svc.ConfirmFn = func(string) (bool, error) {
 		confirmCalled = true
 		return true, nil
 	}
 
-	err := svc.Delete(dir, "foo", "foo")
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -715,7 +1614,8 @@ func TestDeleteConfirmMismatchErrors(t *testing.T) {
 	os.MkdirAll(wrPath, 0o755)
 
 	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
 	}
 	jj := &vcs.JJ{Executor: mock}
 
@@ -723,7 +1623,7 @@ func TestDeleteConfirmMismatchErrors(t *testing.T) {
 	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
 	svc.Config.SetWorkroomsDir(workroomsDir)
 
-	err := svc.Delete(dir, "foo", "wrong")
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "wrong"})
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -745,7 +1645,8 @@ func TestDeleteRunsTeardownScript(t *testing.T) {
 	os.WriteFile(scriptPath, []byte("#!/usr/bin/env bash\necho \"I teared down\"\nexit 0\n"), 0o755)
 
 	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
 	}
 	jj := &vcs.JJ{Executor: mock}
 
@@ -754,7 +1655,7 @@ func TestDeleteRunsTeardownScript(t *testing.T) {
 	svc.Config.SetWorkroomsDir(workroomsDir)
 	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
 
-	err := svc.Delete(dir, "foo", "foo")
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -781,7 +1682,8 @@ func TestDeleteErrorsOnFailedTeardownScript(t *testing.T) {
 	os.WriteFile(scriptPath, []byte("#!/usr/bin/env bash\necho \"I failed to tear down\"\nexit 1\n"), 0o755)
 
 	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
 	}
 	jj := &vcs.JJ{Executor: mock}
 
@@ -790,7 +1692,7 @@ func TestDeleteErrorsOnFailedTeardownScript(t *testing.T) {
 	svc.Config.SetWorkroomsDir(workroomsDir)
 	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
 
-	err := svc.Delete(dir, "foo", "foo")
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -807,7 +1709,8 @@ func TestDeleteGitShowsBranchNote(t *testing.T) {
 	os.MkdirAll(wrPath, 0o755)
 
 	mock := &mockExecutor{
-		output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/workroom/foo\n",
+		outputs: cleanWorkroom,
+		output:  "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/workroom/foo\n",
 	}
 	git := &vcs.Git{Executor: mock}
 
@@ -816,7 +1719,7 @@ func TestDeleteGitShowsBranchNote(t *testing.T) {
 	svc.Config.SetWorkroomsDir(workroomsDir)
 	svc.Config.AddWorkroom(dir, "foo", wrPath, "git")
 
-	err := svc.Delete(dir, "foo", "foo")
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo", KeepBranch: true})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -827,88 +1730,244 @@ func TestDeleteGitShowsBranchNote(t *testing.T) {
 	}
 }
 
-// --- Interactive Delete ---
-
-func TestInteractiveDeleteNoWorkrooms(t *testing.T) {
+func TestDeleteKeepsExternalBranch(t *testing.T) {
 	dir := t.TempDir()
-	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
-	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
 
-	var buf bytes.Buffer
-	svc := &Service{Config: cfg, Out: &buf}
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/feature/login\n",
+	}
+	svc, _, cfg := newTestService(t, &vcs.Git{Executor: mock}, withWorkroomsDir(workroomsDir))
+	cfg.AddWorkroomEntry(dir, "git", "foo", config.WorkroomEntry{Path: wrPath, Branch: "feature/login", ExternalBranch: true})
 
-	err := svc.InteractiveDelete(dir)
-	if err != nil {
+	if err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"}); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-
-	output := buf.String()
-	if !strings.Contains(output, "No workrooms found for this project.") {
-		t.Fatalf("expected no workrooms message, got %q", output)
+	for _, call := range mock.calls {
+		if joined := strings.Join(call, " "); strings.Contains(joined, "branch -D") || strings.Contains(joined, "merge-base") {
+			t.Fatalf("expected the external branch to be left alone, got %v", mock.calls)
+		}
 	}
 }
 
-func TestInteractiveDeleteSingle(t *testing.T) {
+func TestDeleteGitDeletesMergedBranch(t *testing.T) {
 	dir := t.TempDir()
-	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
 	workroomsDir := filepath.Join(dir, "workrooms")
 	wrPath := filepath.Join(workroomsDir, "foo")
 	os.MkdirAll(wrPath, 0o755)
 
 	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+		outputs: cleanWorkroom,
+		output:  "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/workroom/foo\n",
 	}
-	jj := &vcs.JJ{Executor: mock}
+	git := &vcs.Git{Executor: mock}
 
-	svc, buf, _ := newTestService(t, jj)
+	svc, buf, _ := newTestService(t, git)
 	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
 	svc.Config.SetWorkroomsDir(workroomsDir)
-	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
-
-	svc.PromptFn = func(msg string, opts []string) ([]string, error) {
-		return []string{"foo"}, nil
-	}
-	svc.ConfirmFn = func(string) (bool, error) { return true, nil }
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "git")
 
-	err := svc.InteractiveDelete(dir)
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	output := buf.String()
-	if !strings.Contains(output, "Workroom 'foo' deleted successfully.") {
-		t.Fatalf("expected success message, got %q", output)
+	last := strings.Join(mock.calls[len(mock.calls)-1], " ")
+	if last != "git branch -D workroom/foo" {
+		t.Fatalf("expected branch to be deleted, got %q", last)
+	}
+	if strings.Contains(buf.String(), "was not deleted") {
+		t.Fatalf("unexpected branch note, got %q", buf.String())
 	}
 }
 
-func TestInteractiveDeleteMultiple(t *testing.T) {
+func TestDeleteUsesRecordedBranch(t *testing.T) {
 	dir := t.TempDir()
-	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".workroom.json"), []byte(`{"branch_prefix": "new/"}`), 0o644)
 	workroomsDir := filepath.Join(dir, "workrooms")
-	fooPath := filepath.Join(workroomsDir, "foo")
-	barPath := filepath.Join(workroomsDir, "bar")
-	os.MkdirAll(fooPath, 0o755)
-	os.MkdirAll(barPath, 0o755)
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
 
 	mock := &mockExecutor{
-		output: "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\nworkroom/bar: xz b12345 (no description set)\n",
+		outputs: cleanWorkroom,
+		output:  "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/old/foo\n",
 	}
-	jj := &vcs.JJ{Executor: mock}
-
-	svc, buf, _ := newTestService(t, jj)
+	svc, _, _ := newTestService(t, &vcs.Git{Executor: mock})
 	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
-	svc.Config.SetWorkroomsDir(workroomsDir)
-	svc.Config.AddWorkroom(dir, "foo", fooPath, "jj")
-	svc.Config.AddWorkroom(dir, "bar", barPath, "jj")
+	svc.Config.AddWorkroomEntry(dir, "git", "foo", config.WorkroomEntry{Path: wrPath, Branch: "old/foo"})
 
-	svc.PromptFn = func(msg string, opts []string) ([]string, error) {
-		return []string{"foo", "bar"}, nil
+	if err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	svc.ConfirmFn = func(string) (bool, error) { return true, nil }
 
-	err := svc.InteractiveDelete(dir)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	last := strings.Join(mock.calls[len(mock.calls)-1], " ")
+	if last != "git branch -D old/foo" {
+		t.Fatalf("expected recorded branch to be deleted, got %q", last)
+	}
+}
+
+func TestDeleteRefusesUnmergedBranch(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	worktrees := "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/workroom/foo\n"
+	notAncestor := exec.Command("sh", "-c", "exit 1").Run()
+	mock := &mockExecutor{output: worktrees, outputs: cleanWorkroom}
+	mock.onRun = func(_, name string, args []string) {
+		mock.err = nil
+		if name == "git" && args[0] == "merge-base" {
+			mock.err = notAncestor
+		}
+	}
+	git := &vcs.Git{Executor: mock}
+
+	svc, _, _ := newTestService(t, git)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "git")
+
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
+	if !errors.Is(err, ErrBranchNotMerged) {
+		t.Fatalf("expected ErrBranchNotMerged, got %v", err)
+	}
+	for _, c := range mock.calls {
+		if c[1] == "worktree" && c[2] == "remove" {
+			t.Fatal("expected worktree not to be removed")
+		}
+	}
+
+	err = svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo", ForceBranch: true})
+	if err != nil {
+		t.Fatalf("unexpected error with ForceBranch: %v", err)
+	}
+	last := strings.Join(mock.calls[len(mock.calls)-1], " ")
+	if last != "git branch -D workroom/foo" {
+		t.Fatalf("expected branch to be deleted, got %q", last)
+	}
+}
+
+func TestDeleteKeepBranch(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, _, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
+
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo", KeepBranch: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range mock.calls {
+		if c[1] == "abandon" {
+			t.Fatalf("expected working-copy commit not to be abandoned, got %v", c)
+		}
+	}
+}
+
+// --- Interactive Delete ---
+
+func TestInteractiveDeleteNoWorkrooms(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	cfg := newTestConfig(t, filepath.Join(dir, "config.json"))
+
+	var buf bytes.Buffer
+	svc := &Service{Config: cfg, Out: &buf}
+
+	err := svc.InteractiveDelete(dir, DeleteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "No workrooms found for this project.") {
+		t.Fatalf("expected no workrooms message, got %q", output)
+	}
+}
+
+func TestInteractiveDeleteSingle(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, buf, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
+
+	svc.PromptFn = func(msg string, opts []string) ([]string, error) {
+		return []string{"foo"}, nil
+	}
+	svc.ConfirmFn = func(string) (bool, error) { return true, nil }
+
+	err := svc.InteractiveDelete(dir, DeleteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "Workroom 'foo' deleted successfully.") {
+		t.Fatalf("expected success message, got %q", output)
+	}
+}
+
+func TestInteractiveDeleteMultiple(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	fooPath := filepath.Join(workroomsDir, "foo")
+	barPath := filepath.Join(workroomsDir, "bar")
+	os.MkdirAll(fooPath, 0o755)
+	os.MkdirAll(barPath, 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\nworkroom/bar: xz b12345 (no description set)\n",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, buf, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", fooPath, "jj")
+	svc.Config.AddWorkroom(dir, "bar", barPath, "jj")
+
+	svc.PromptFn = func(msg string, opts []string) ([]string, error) {
+		return []string{"foo", "bar"}, nil
+	}
+	svc.ConfirmFn = func(string) (bool, error) { return true, nil }
+
+	err := svc.InteractiveDelete(dir, DeleteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
 	output := buf.String()
@@ -941,7 +2000,7 @@ func TestInteractiveDeleteAbortsOnDecline(t *testing.T) {
 		ConfirmFn: func(string) (bool, error) { return false, nil },
 	}
 
-	err := svc.InteractiveDelete(dir)
+	err := svc.InteractiveDelete(dir, DeleteOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -976,7 +2035,7 @@ func TestInteractiveDeleteAbortsOnNoSelection(t *testing.T) {
 		},
 	}
 
-	err := svc.InteractiveDelete(dir)
+	err := svc.InteractiveDelete(dir, DeleteOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -992,8 +2051,1207 @@ func TestInteractiveDeleteErrorsIfInWorkroom(t *testing.T) {
 	os.WriteFile(filepath.Join(dir, ".Workroom"), []byte{}, 0o644)
 
 	svc := &Service{Out: &bytes.Buffer{}}
-	err := svc.InteractiveDelete(dir)
+	err := svc.InteractiveDelete(dir, DeleteOptions{})
 	if !errors.Is(err, ErrInWorkroom) {
 		t.Fatalf("expected ErrInWorkroom, got %v", err)
 	}
 }
+
+// --- Migrate ---
+
+func TestMigrateMovesToLayout(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	oldPath := filepath.Join(workroomsDir, "foo")
+	newPath := filepath.Join(workroomsDir, config.ProjectID(dir), "foo")
+	os.MkdirAll(oldPath, 0o755)
+
+	mock := &mockExecutor{}
+	git := &vcs.Git{Executor: mock}
+
+	svc, buf, _ := newTestService(t, git)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", oldPath, "git")
+
+	if err := svc.Migrate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"git", "worktree", "move", oldPath, newPath}
+	if len(mock.calls) != 1 || strings.Join(mock.calls[0], " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected %v, got %v", expected, mock.calls)
+	}
+
+	data, _ := svc.Config.Read()
+	foo := data.Projects[dir].Workrooms["foo"]
+	if foo.Path != newPath {
+		t.Fatalf("expected config path %s, got %v", newPath, foo.Path)
+	}
+	if !strings.Contains(buf.String(), "Moved 'foo'") {
+		t.Fatalf("expected move message, got %q", buf.String())
+	}
+}
+
+func TestMigratePretendMakesNoChanges(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	oldPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(oldPath, 0o755)
+
+	mock := &mockExecutor{}
+	git := &vcs.Git{Executor: mock}
+
+	svc, _, _ := newTestService(t, git)
+	svc.Pretend = true
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", oldPath, "git")
+
+	if err := svc.Migrate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mock.calls) != 0 {
+		t.Fatalf("expected no VCS calls, got %v", mock.calls)
+	}
+
+	data, _ := svc.Config.Read()
+	foo := data.Projects[dir].Workrooms["foo"]
+	if foo.Path != oldPath {
+		t.Fatalf("expected config path unchanged, got %v", foo.Path)
+	}
+}
+
+func TestMigrateNothingToDo(t *testing.T) {
+	dir := t.TempDir()
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, config.ProjectID(dir), "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{}
+	svc, buf, _ := newTestService(t, &vcs.Git{Executor: mock})
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "git")
+
+	if err := svc.Migrate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "All workrooms already match the configured layout.") {
+		t.Fatalf("expected nothing-to-do message, got %q", buf.String())
+	}
+}
+
+func TestDeleteUsesRecordedPath(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	// Created before per-project layouts, so not at the path the layout gives.
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{
+		outputs: cleanWorkroom,
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, _, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
+
+	if err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(wrPath); !os.IsNotExist(err) {
+		t.Fatal("expected recorded directory to be removed")
+	}
+}
+
+// --- Unsaved work ---
+
+func TestDeleteRefusesDirtyWorkroom(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{
+		output: "worktree " + dir + "\nHEAD cbace1f\nbranch refs/heads/master\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/workroom/foo\n",
+		outputs: map[string]string{
+			"git status": " M app.rb\n?? notes.txt",
+			"git remote": "origin",
+			"git log":    "abc123 Work in progress",
+		},
+	}
+	git := &vcs.Git{Executor: mock}
+
+	svc, buf, _ := newTestService(t, git)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "git")
+
+	err := svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo"})
+	if !errors.Is(err, ErrUnsavedChanges) {
+		t.Fatalf("expected ErrUnsavedChanges, got %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{"app.rb", "notes.txt", "abc123 Work in progress"} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected %q in report, got %q", want, output)
+		}
+	}
+	if _, err := os.Stat(wrPath); err != nil {
+		t.Fatal("expected directory to still exist")
+	}
+
+	err = svc.Delete(dir, "foo", DeleteOptions{Confirm: "foo", Force: true})
+	if err != nil {
+		t.Fatalf("unexpected error with Force: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Workroom 'foo' deleted successfully.") {
+		t.Fatalf("expected success message, got %q", buf.String())
+	}
+}
+
+func TestInteractiveDeleteMarksDirtyWorkrooms(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".jj"), 0o755)
+	workroomsDir := filepath.Join(dir, "workrooms")
+	wrPath := filepath.Join(workroomsDir, "foo")
+	os.MkdirAll(wrPath, 0o755)
+
+	mock := &mockExecutor{
+		output:  "default: mk 6ec05f05 (no description set)\nworkroom/foo: mk 6ec05f05 (no description set)\n",
+		outputs: map[string]string{"jj diff": "M src/main.rs\n", "jj log": ""},
+	}
+	jj := &vcs.JJ{Executor: mock}
+
+	svc, _, _ := newTestService(t, jj)
+	svc.Config = newTestConfig(t, filepath.Join(dir, "config.json"))
+	svc.Config.SetWorkroomsDir(workroomsDir)
+	svc.Config.AddWorkroom(dir, "foo", wrPath, "jj")
+
+	var options []string
+	svc.PromptFn = func(msg string, opts []string) ([]string, error) {
+		options = opts
+		return opts, nil
+	}
+
+	err := svc.InteractiveDelete(dir, DeleteOptions{})
+	if !errors.Is(err, ErrUnsavedChanges) {
+		t.Fatalf("expected ErrUnsavedChanges, got %v", err)
+	}
+	if len(options) != 1 || options[0] != "foo (1 modified)" {
+		t.Fatalf("expected dirty label, got %v", options)
+	}
+
+	err = svc.InteractiveDelete(dir, DeleteOptions{Force: true})
+	if err != nil {
+		t.Fatalf("unexpected error with Force: %v", err)
+	}
+}
+
+// --- Config ---
+
+func TestConfigGetUnsetKeyExitsWithOne(t *testing.T) {
+	svc, _, _ := newTestService(t, nil)
+
+	err := svc.ConfigGet(t.TempDir(), config.ScopeGlobal, "trunk")
+	var exitErr *errs.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit code 1, got %v", err)
+	}
+}
+
+func TestConfigGetListPrintsOneItemPerLine(t *testing.T) {
+	svc, buf, cfg := newTestService(t, nil)
+	project := t.TempDir()
+	cfg.SetValue(config.ScopeProject, project, "copy", []string{".env", "config/master.key"})
+
+	if err := svc.ConfigGet(project, "", "copy"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != ".env\nconfig/master.key\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestConfigEditSavesValidChanges(t *testing.T) {
+	svc, buf, cfg := newTestService(t, nil)
+	svc.EditFn = func(path string) error {
+		return os.WriteFile(path, []byte(`{"schema_version": 1, "trunk": "main"}`), 0o644)
+	}
+
+	if err := svc.ConfigEdit(t.TempDir(), config.ScopeGlobal); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := cfg.Read(); f.Trunk != "main" {
+		t.Fatalf("expected trunk to be saved, got %+v", f)
+	}
+	if !strings.Contains(buf.String(), "Saved") {
+		t.Fatalf("expected saved message, got %q", buf.String())
+	}
+}
+
+func TestConfigEditReopensInvalidChanges(t *testing.T) {
+	svc, buf, _ := newTestService(t, nil)
+	project := t.TempDir()
+	edits := []string{"name_style = \"emoji\"\n", "name_style = \"short\"\n"}
+	var edited int
+	svc.EditFn = func(path string) error {
+		if filepath.Ext(path) != ".toml" {
+			t.Errorf("expected a .toml file to edit, got %s", path)
+		}
+		edited++
+		return os.WriteFile(path, []byte(edits[edited-1]), 0o644)
+	}
+
+	if err := svc.ConfigEdit(project, config.ScopeProject); err != nil {
+		t.Fatal(err)
+	}
+	if edited != 2 {
+		t.Fatalf("expected the editor to be opened twice, got %d", edited)
+	}
+	if !strings.Contains(buf.String(), "name_style: must be one of") {
+		t.Fatalf("expected validation error, got %q", buf.String())
+	}
+	data, _ := os.ReadFile(filepath.Join(project, ".workroom.toml"))
+	if string(data) != edits[1] {
+		t.Fatalf("expected the edited file to be saved as is, got %q", data)
+	}
+}
+
+func TestConfigEditDiscardsInvalidChanges(t *testing.T) {
+	svc, _, cfg := newTestService(t, nil)
+	cfg.SetValue(config.ScopeGlobal, "", "trunk", []string{"main"})
+	svc.ConfirmFn = func(string) (bool, error) { return false, nil }
+	svc.EditFn = func(path string) error {
+		return os.WriteFile(path, []byte(`{"schema_version": 1, "trunk": ["main"]}`), 0o644)
+	}
+
+	err := svc.ConfigEdit(t.TempDir(), config.ScopeGlobal)
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	if f, _ := cfg.Read(); f.Trunk != "main" {
+		t.Fatalf("expected config to be unchanged, got %+v", f)
+	}
+}
+
+// --- Doctor ---
+
+// doctorFixture sets up a Git project whose config, worktrees, directories and branches have drifted
+// apart in every way Doctor knows about.
+var doctorFixture = []fixtureOption{
+	withWorkrooms(
+		fixtureWorkroom{name: "ok", recorded: true, dir: true, worktree: true},
+		fixtureWorkroom{name: "stale", recorded: true},
+		fixtureWorkroom{name: "nodir", recorded: true},
+		fixtureWorkroom{name: "notwt", recorded: true, dir: true},
+		fixtureWorkroom{name: "orphan", dir: true, worktree: true},
+		fixtureWorkroom{name: "junk", dir: true},
+	),
+	func(f *fixture) {
+		f.mock.outputs = map[string]string{
+			"git worktree list": "worktree " + f.project + "\nHEAD aaa\nbranch refs/heads/main\n\n" +
+				"worktree " + f.path("ok") + "\nHEAD bbb\nbranch refs/heads/workroom/ok\n\n" +
+				"worktree " + f.path("nodir") + "\nHEAD ccc\nbranch refs/heads/workroom/nodir\nprunable gitdir file points to non-existent location\n\n" +
+				"worktree " + f.path("orphan") + "\nHEAD ddd\nbranch refs/heads/workroom/orphan\n\n" +
+				"worktree /elsewhere/feature\nHEAD eee\nbranch refs/heads/feature\n",
+			"git for-each-ref": "refs/heads/workroom/ok\nrefs/heads/workroom/orphan\nrefs/heads/workroom/old\n",
+		}
+	},
+}
+
+func TestDoctorReportsProblems(t *testing.T) {
+	svc, buf, cfg, mock, project := newFixture(t, doctorFixture...)
+
+	err := svc.Doctor(project, false)
+	var exitErr *errs.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit code 1, got %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"stale: stale config entry",
+		"nodir: directory",
+		"notwt: directory",
+		"workroom/orphan: orphan Git worktree",
+		"junk: directory has no VCS metadata",
+		"workroom/old: merged branch is left over",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"ok:", "feature"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected no problem for %q, got:\n%s", unwanted, out)
+		}
+	}
+
+	_, p, _ := cfg.FindCurrentProject(project)
+	if len(p.Workrooms) != 4 {
+		t.Fatalf("expected the config to be unchanged, got %v", p.Workrooms)
+	}
+	for _, call := range mock.calls {
+		if slices.Contains(call, "prune") || slices.Contains(call, "-D") {
+			t.Fatalf("expected no repairs, got %v", call)
+		}
+	}
+}
+
+func TestDoctorFix(t *testing.T) {
+	svc, buf, cfg, mock, project := newFixture(t, doctorFixture...)
+
+	err := svc.Doctor(project, true)
+	var exitErr *errs.ExitError
+	if !errors.As(err, &exitErr) || !strings.Contains(err.Error(), "1 of 6 problems remain") {
+		t.Fatalf("expected the stray directory to remain, got %v\n%s", err, buf.String())
+	}
+
+	_, p, _ := cfg.FindCurrentProject(project)
+	if got := sortedKeys(p.Workrooms); !slices.Equal(got, []string{"ok", "orphan"}) {
+		t.Fatalf("expected stale entries removed and the orphan registered, got %v", got)
+	}
+	if p.Workrooms["orphan"].Branch != "workroom/orphan" {
+		t.Fatalf("expected the orphan's branch to be recorded, got %+v", p.Workrooms["orphan"])
+	}
+
+	var pruned, deleted bool
+	for _, call := range mock.calls {
+		joined := strings.Join(call, " ")
+		pruned = pruned || joined == "git worktree prune"
+		deleted = deleted || joined == "git branch -D workroom/old"
+	}
+	if !pruned || !deleted {
+		t.Fatalf("expected worktrees pruned and the old branch deleted, got %v", mock.calls)
+	}
+}
+
+func TestDoctorFixPretend(t *testing.T) {
+	svc, buf, cfg, _, project := newFixture(t, doctorFixture...)
+	svc.Pretend = true
+
+	svc.Doctor(project, true)
+
+	_, p, _ := cfg.FindCurrentProject(project)
+	if len(p.Workrooms) != 4 {
+		t.Fatalf("expected the config to be unchanged, got %v", p.Workrooms)
+	}
+	if !strings.Contains(buf.String(), "(would remove it from the config)") {
+		t.Fatalf("expected planned repairs, got:\n%s", buf.String())
+	}
+}
+
+func TestDoctorMatchesWorktreesThroughSymlink(t *testing.T) {
+	project := t.TempDir()
+	real := t.TempDir()
+	link := filepath.Join(t.TempDir(), "workrooms")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	os.MkdirAll(filepath.Join(real, config.ProjectID(project), "ok"), 0o755)
+	os.WriteFile(filepath.Join(real, config.ProjectID(project), "ok", ".git"), []byte("gitdir: x"), 0o644)
+
+	// Git reports the real path of the worktree, while the config has the one through the symlink.
+	mock := &mockExecutor{outputs: map[string]string{
+		"git worktree list": "worktree " + project + "\nHEAD aaa\nbranch refs/heads/main\n\n" +
+			"worktree " + filepath.Join(real, config.ProjectID(project), "ok") + "\nHEAD bbb\nbranch refs/heads/workroom/ok\n",
+	}}
+	svc, buf, cfg := newTestService(t, &vcs.Git{Executor: mock}, withWorkroomsDir(link))
+	cfg.AddWorkroom(project, "ok", filepath.Join(link, config.ProjectID(project), "ok"), "git")
+
+	if err := svc.Doctor(project, true); err != nil {
+		t.Fatalf("expected no problems, got %v\n%s", err, buf.String())
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	if p.Workrooms["ok"] == nil {
+		t.Fatal("expected the workroom to be kept in the config")
+	}
+}
+
+func TestDoctorNoProblems(t *testing.T) {
+	mock := &mockExecutor{}
+	svc, buf, _ := newTestService(t, &vcs.Git{Executor: mock})
+
+	if err := svc.Doctor(t.TempDir(), false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "No problems found.") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+// --- Adopt / Release ---
+
+func TestAdoptGitWorktree(t *testing.T) {
+	project := t.TempDir()
+	wt := filepath.Join(t.TempDir(), "feature")
+	os.Mkdir(wt, 0o755)
+	mock := &mockExecutor{outputs: map[string]string{
+		"git worktree list": "worktree " + project + "\nHEAD aaa\nbranch refs/heads/main\n\nworktree " + wt + "\nHEAD bbb\nbranch refs/heads/feature\n",
+	}}
+	svc, buf, cfg := newTestService(t, &vcs.Git{Executor: mock})
+
+	if err := svc.Adopt(project, wt, AdoptOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	entry := p.Workrooms["feature"]
+	if entry == nil || entry.Path != wt || entry.Branch != "feature" || p.VCS != "git" {
+		t.Fatalf("expected worktree to be adopted with its branch, got %+v", p)
+	}
+	if !entry.ExternalBranch {
+		t.Fatal("expected the adopted branch to be marked as external")
+	}
+	if entry.CreatedAt.IsZero() {
+		t.Fatal("expected adoption time to be recorded")
+	}
+	if !strings.Contains(buf.String(), "Adopted worktree") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	if err := svc.Adopt(project, wt, AdoptOptions{Name: "again"}); err == nil || !strings.Contains(err.Error(), "already a workroom") {
+		t.Fatalf("expected adopting twice to fail, got %v", err)
+	}
+}
+
+func TestAdoptUnknownPath(t *testing.T) {
+	project := t.TempDir()
+	mock := &mockExecutor{output: "worktree " + project + "\nHEAD aaa\nbranch refs/heads/main\n"}
+	svc, _, _ := newTestService(t, &vcs.Git{Executor: mock})
+
+	err := svc.Adopt(project, t.TempDir(), AdoptOptions{})
+	if !errors.Is(err, ErrGitWorktreeNotFound) {
+		t.Fatalf("expected ErrGitWorktreeNotFound, got %v", err)
+	}
+}
+
+func TestAdoptAllWithMove(t *testing.T) {
+	project := t.TempDir()
+	elsewhere := t.TempDir()
+	for _, name := range []string{"one", "two", "known"} {
+		os.Mkdir(filepath.Join(elsewhere, name), 0o755)
+	}
+	var listing string
+	for _, name := range []string{"one", "two", "known", "gone"} {
+		listing += fmt.Sprintf("worktree %s\nHEAD aaa\nbranch refs/heads/%s\n\n", filepath.Join(elsewhere, name), name)
+	}
+	mock := &mockExecutor{outputs: map[string]string{"git worktree list": "worktree " + project + "\nHEAD aaa\n\n" + listing}}
+	svc, buf, cfg := newTestService(t, &vcs.Git{Executor: mock}, withWorkroomsDir(t.TempDir()))
+	cfg.AddWorkroom(project, "known", filepath.Join(elsewhere, "known"), "git")
+
+	if err := svc.Adopt(project, "", AdoptOptions{All: true, Move: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, p, _ := cfg.FindCurrentProject(project)
+	if got := sortedKeys(p.Workrooms); !slices.Equal(got, []string{"known", "one", "two"}) {
+		t.Fatalf("expected one and two to be adopted, got %v", got)
+	}
+	want, _ := cfg.WorkroomPath(project, "one")
+	if p.Workrooms["one"].Path != want {
+		t.Fatalf("expected adopted workroom to be moved to %s, got %s", want, p.Workrooms["one"].Path)
+	}
+	var moves int
+	for _, call := range mock.calls {
+		if strings.HasPrefix(strings.Join(call, " "), "git worktree move") {
+			moves++
+		}
+	}
+	if moves != 2 {
+		t.Fatalf("expected 2 worktree moves, got %v", mock.calls)
+	}
+	if !strings.Contains(buf.String(), "not found") {
+		t.Fatalf("expected the missing worktree to be skipped, got %q", buf.String())
+	}
+}
+
+func TestAdoptAllSkipsMainWorktreeThroughSymlink(t *testing.T) {
+	real := filepath.Join(t.TempDir(), "repo")
+	os.Mkdir(real, 0o755)
+	project := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(real, project); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	wt := filepath.Join(t.TempDir(), "feature")
+	os.Mkdir(wt, 0o755)
+	mock := &mockExecutor{outputs: map[string]string{
+		"git worktree list": "worktree " + real + "\nHEAD aaa\nbranch refs/heads/main\n\nworktree " + wt + "\nHEAD bbb\nbranch refs/heads/feature\n",
+	}}
+	svc, _, cfg := newTestService(t, &vcs.Git{Executor: mock})
+
+	if err := svc.Adopt(project, "", AdoptOptions{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	if got := sortedKeys(p.Workrooms); !slices.Equal(got, []string{"feature"}) {
+		t.Fatalf("expected only the linked worktree to be adopted, got %v", got)
+	}
+}
+
+func TestAdoptDetachedWorktreeKeepsNoBranch(t *testing.T) {
+	project := t.TempDir()
+	wt := filepath.Join(t.TempDir(), "detached")
+	os.Mkdir(wt, 0o755)
+	mock := &mockExecutor{outputs: map[string]string{
+		"git worktree list": "worktree " + project + "\nHEAD aaa\nbranch refs/heads/main\n\nworktree " + wt + "\nHEAD bbb\ndetached\n",
+	}}
+	svc, _, _ := newTestService(t, &vcs.Git{Executor: mock})
+
+	if err := svc.Adopt(project, wt, AdoptOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// Falling back to the branch prefix would name an unrelated workroom/detached branch.
+	if got := svc.vcsName(project, "detached"); got != "" {
+		t.Fatalf("expected no branch for a detached worktree, got %q", got)
+	}
+}
+
+func TestAdoptAllSkipsRecordedWorktreeThroughSymlink(t *testing.T) {
+	project := t.TempDir()
+	real := filepath.Join(t.TempDir(), "feature")
+	os.Mkdir(real, 0o755)
+	link := filepath.Join(t.TempDir(), "feature")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	mock := &mockExecutor{outputs: map[string]string{
+		"git worktree list": "worktree " + project + "\nHEAD aaa\nbranch refs/heads/main\n\nworktree " + real + "\nHEAD bbb\nbranch refs/heads/feature\n",
+	}}
+	svc, _, cfg := newTestService(t, &vcs.Git{Executor: mock})
+	cfg.AddWorkroom(project, "mine", link, "git")
+
+	if err := svc.Adopt(project, "", AdoptOptions{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	if got := sortedKeys(p.Workrooms); !slices.Equal(got, []string{"mine"}) {
+		t.Fatalf("expected the worktree not to be adopted twice, got %v", got)
+	}
+}
+
+func TestAdoptJJWorkspace(t *testing.T) {
+	project := t.TempDir()
+	ws := t.TempDir()
+	mock := &mockExecutor{outputs: map[string]string{
+		"jj log":            "qpvuntsmwlqt",
+		"jj workspace list": "default: mk 6ec05f05 (no description set)\nworkroom/spike: qpvuntsm 0ab43ea9 (empty) (no description set)",
+	}}
+	svc, _, cfg := newTestService(t, &vcs.JJ{Executor: mock})
+
+	if err := svc.Adopt(project, ws, AdoptOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	if entry := p.Workrooms["spike"]; entry == nil || entry.Branch != "workroom/spike" || entry.Path != ws {
+		t.Fatalf("expected workspace to be adopted as spike, got %+v", p.Workrooms)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	project := t.TempDir()
+	wrPath := t.TempDir()
+	svc, _, cfg := newTestService(t, nil)
+	cfg.AddWorkroom(project, "foo", wrPath, "git")
+
+	if err := svc.Release(project, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, p, _ := cfg.FindCurrentProject(project); p != nil {
+		t.Fatalf("expected workroom to be removed from config, got %+v", p)
+	}
+	if _, err := os.Stat(wrPath); err != nil {
+		t.Fatalf("expected workroom directory to be kept: %v", err)
+	}
+
+	if err := svc.Release(project, "foo"); !errors.Is(err, ErrWorkroomNotFound) {
+		t.Fatalf("expected ErrWorkroomNotFound, got %v", err)
+	}
+}
+
+func TestReleaseRefusesLockedWorkroom(t *testing.T) {
+	project := t.TempDir()
+	svc, _, cfg := newTestService(t, nil)
+	cfg.AddWorkroom(project, "foo", t.TempDir(), "git")
+	cfg.SetLock(project, "foo", &config.Lock{Reason: "on a plane"})
+
+	if err := svc.Release(project, "foo"); !errors.Is(err, ErrWorkroomLocked) {
+		t.Fatalf("expected ErrWorkroomLocked, got %v", err)
+	}
+	if _, p, _ := cfg.FindCurrentProject(project); p == nil || p.Workrooms["foo"] == nil {
+		t.Fatal("expected the locked workroom to stay in the config")
+	}
+}
+
+// --- Rename ---
+
+// renameFixture sets up a Git project with workroom "old" at its layout path.
+var renameFixture = []fixtureOption{withWorkrooms(fixtureWorkroom{name: "old", recorded: true, dir: true})}
+
+func TestRenameGit(t *testing.T) {
+	svc, _, cfg, mock, project := newFixture(t, renameFixture...)
+	oldPath, _ := cfg.WorkroomPath(project, "old")
+	newPath, _ := cfg.WorkroomPath(project, "payments")
+	_, before, _ := cfg.FindCurrentProject(project)
+
+	if err := svc.Rename(project, "old", "payments"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, p, _ := cfg.FindCurrentProject(project)
+	entry := p.Workrooms["payments"]
+	if p.Workrooms["old"] != nil || entry == nil {
+		t.Fatalf("expected entry to be renamed, got %v", sortedKeys(p.Workrooms))
+	}
+	if entry.Path != newPath || entry.Branch != "workroom/payments" {
+		t.Fatalf("expected new path and branch, got %+v", entry)
+	}
+	if !entry.CreatedAt.Equal(before.Workrooms["old"].CreatedAt) {
+		t.Fatal("expected creation time to be kept")
+	}
+	if _, err := os.Stat(newPath); err != nil {
+		t.Fatalf("expected directory to be moved: %v", err)
+	}
+
+	var calls []string
+	for _, call := range mock.calls {
+		calls = append(calls, strings.Join(call, " "))
+	}
+	for _, want := range []string{
+		"git worktree move " + oldPath + " " + newPath,
+		"git branch -m workroom/old workroom/payments",
+	} {
+		if !slices.Contains(calls, want) {
+			t.Errorf("expected call %q, got %v", want, calls)
+		}
+	}
+}
+
+func TestRenameRollsBackWhenHookFails(t *testing.T) {
+	svc, buf, cfg, mock, project := newFixture(t, renameFixture...)
+	os.WriteFile(filepath.Join(project, ".workroom.toml"), []byte("[hooks]\nrename = \"test \\\"$WORKROOM_OLD_NAME\\\" = nope\"\n"), 0o644)
+	oldPath, _ := cfg.WorkroomPath(project, "old")
+
+	err := svc.Rename(project, "old", "payments")
+	if !errors.Is(err, ErrHook) {
+		t.Fatalf("expected ErrHook, got %v", err)
+	}
+
+	_, p, _ := cfg.FindCurrentProject(project)
+	if entry := p.Workrooms["old"]; entry == nil || entry.Path != oldPath || entry.Branch != "workroom/old" {
+		t.Fatalf("expected config to be rolled back, got %v", p.Workrooms)
+	}
+	if _, err := os.Stat(oldPath); err != nil {
+		t.Fatalf("expected directory to be moved back: %v", err)
+	}
+	last := strings.Join(mock.calls[len(mock.calls)-1], " ")
+	if !strings.HasPrefix(last, "git worktree move") {
+		t.Fatalf("expected the move to be undone last, got %q", last)
+	}
+	if !strings.Contains(buf.String(), "Rolling back") {
+		t.Fatalf("expected rollback message, got %q", buf.String())
+	}
+}
+
+func TestRenameRunsHookWithOldName(t *testing.T) {
+	svc, buf, _, _, project := newFixture(t, renameFixture...)
+	os.WriteFile(filepath.Join(project, ".workroom.toml"), []byte("[hooks]\nrename = \"echo renamed $WORKROOM_OLD_NAME to $WORKROOM_NAME\"\n"), 0o644)
+
+	if err := svc.Rename(project, "old", "payments"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "renamed old to payments") {
+		t.Fatalf("expected hook output, got %q", buf.String())
+	}
+}
+
+func TestRenameValidation(t *testing.T) {
+	svc, _, cfg, _, project := newFixture(t, renameFixture...)
+	cfg.AddWorkroom(project, "taken", t.TempDir(), "git")
+
+	if err := svc.Rename(project, "old", "-bad"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName, got %v", err)
+	}
+	if err := svc.Rename(project, "missing", "new"); !errors.Is(err, ErrWorkroomNotFound) {
+		t.Errorf("expected ErrWorkroomNotFound, got %v", err)
+	}
+	if err := svc.Rename(project, "old", "taken"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected an existing name to be rejected, got %v", err)
+	}
+}
+
+func TestRenameJJ(t *testing.T) {
+	project := t.TempDir()
+	mock := &mockExecutor{}
+	svc, _, cfg := newTestService(t, &vcs.JJ{Executor: mock}, withWorkroomsDir(t.TempDir()))
+	oldPath, _ := cfg.WorkroomPath(project, "old")
+	os.MkdirAll(oldPath, 0o755)
+	cfg.AddWorkroomEntry(project, "jj", "old", config.WorkroomEntry{Path: oldPath, Branch: "workroom/old"})
+
+	if err := svc.Rename(project, "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	newPath, _ := cfg.WorkroomPath(project, "new")
+	if _, err := os.Stat(newPath); err != nil {
+		t.Fatalf("expected directory to be moved: %v", err)
+	}
+	if got := strings.Join(mock.calls[len(mock.calls)-1], " "); got != "jj workspace rename workroom/new" {
+		t.Fatalf("expected workspace to be renamed, got %q", got)
+	}
+}
+
+// --- Prune ---
+
+// pruneFixture sets up a Git project with workrooms that each match a different prune filter:
+// "merged", "missing", "gone" (upstream deleted, not merged), "old" (last commit 100 days ago),
+// "fresh" (no commits of its own) and "release-1" (missing).
+func pruneFixture() []fixtureOption {
+	now := time.Now()
+	days := func(n int) time.Time { return now.Add(-time.Duration(n) * 24 * time.Hour).Truncate(time.Second) }
+	commits := map[string]time.Time{"merged": days(10), "gone": days(5), "old": days(100), "fresh": days(50)}
+	notAncestor := exec.Command("sh", "-c", "exit 1").Run()
+
+	return []fixtureOption{
+		withWorkrooms(
+			fixtureWorkroom{name: "merged", recorded: true, dir: true, createdAt: days(20)},
+			fixtureWorkroom{name: "missing", recorded: true, createdAt: days(20)},
+			fixtureWorkroom{name: "gone", recorded: true, dir: true, createdAt: days(20)},
+			fixtureWorkroom{name: "old", recorded: true, dir: true, createdAt: days(200)},
+			fixtureWorkroom{name: "fresh", recorded: true, dir: true, createdAt: now},
+			fixtureWorkroom{name: "release-1", recorded: true, createdAt: days(20)},
+		),
+		func(f *fixture) {
+			f.mock.onRun = func(dir, name string, args []string) {
+				f.mock.output, f.mock.err = "", nil
+				switch {
+				case args[0] == "log":
+					f.mock.output = fmt.Sprintf("abc123\x1fsubject\x1f%d", commits[filepath.Base(dir)].Unix())
+				case args[0] == "merge-base" && (args[2] == "workroom/gone" || args[2] == "workroom/old"):
+					f.mock.err = notAncestor
+				case args[0] == "for-each-ref" && args[2] == "refs/heads/workroom/gone":
+					f.mock.output = "[gone]\n"
+				}
+			}
+		},
+	}
+}
+
+func TestPruneDefaultFilters(t *testing.T) {
+	svc, buf, cfg, mock, project := newFixture(t, pruneFixture()...)
+
+	err := svc.Prune(project, PruneOptions{Keep: []string{"release-*"}})
+	var exitErr *errs.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected ExitError for the unmerged workroom, got %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{"merged into trunk", "directory not found", "upstream branch deleted", "Skipping 'gone'"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output, got %q", want, output)
+		}
+	}
+	for _, unwanted := range []string{"old", "fresh", "release-1"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("expected %q not to be pruned, got %q", unwanted, output)
+		}
+	}
+
+	_, p, _ := cfg.FindCurrentProject(project)
+	if got := sortedKeys(p.Workrooms); !slices.Equal(got, []string{"fresh", "gone", "old", "release-1"}) {
+		t.Fatalf("expected merged and missing workrooms to be pruned, got %v", got)
+	}
+
+	var calls []string
+	for _, call := range mock.calls {
+		calls = append(calls, strings.Join(call, " "))
+	}
+	mergedPath, _ := cfg.WorkroomPath(project, "merged")
+	for _, want := range []string{"git worktree remove " + mergedPath + " --force", "git worktree prune", "git branch -D workroom/missing"} {
+		if !slices.Contains(calls, want) {
+			t.Errorf("expected call %q, got %v", want, calls)
+		}
+	}
+}
+
+func TestPruneOlderThanPretend(t *testing.T) {
+	svc, buf, cfg, _, project := newFixture(t, pruneFixture()...)
+	svc.Pretend = true
+	svc.ConfirmFn = func(string) (bool, error) {
+		t.Fatal("expected no confirmation prompt in pretend mode")
+		return false, nil
+	}
+
+	if err := svc.Prune(project, PruneOptions{OlderThan: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "no commits for 100 days") {
+		t.Fatalf("expected old workroom to match, got %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "fresh") || strings.Contains(buf.String(), "merged") {
+		t.Fatalf("expected only the old workroom to match, got %q", buf.String())
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	if len(p.Workrooms) != 6 {
+		t.Fatalf("expected no workrooms to be deleted, got %v", sortedKeys(p.Workrooms))
+	}
+}
+
+func TestPruneAbortsOnDecline(t *testing.T) {
+	svc, buf, cfg, _, project := newFixture(t, pruneFixture()...)
+	svc.ConfirmFn = func(string) (bool, error) { return false, nil }
+
+	if err := svc.Prune(project, PruneOptions{Missing: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "No workrooms were pruned") {
+		t.Fatalf("expected abort message, got %q", buf.String())
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	if len(p.Workrooms) != 6 {
+		t.Fatalf("expected no workrooms to be deleted, got %v", sortedKeys(p.Workrooms))
+	}
+}
+
+func TestPruneRejectsBadKeepPattern(t *testing.T) {
+	svc, _, _, _, project := newFixture(t, pruneFixture()...)
+	if err := svc.Prune(project, PruneOptions{Keep: []string{"["}}); err == nil {
+		t.Fatal("expected an invalid pattern to be rejected")
+	}
+}
+
+// --- Lock ---
+
+func TestLockGit(t *testing.T) {
+	svc, buf, cfg, mock, project := newFixture(t, renameFixture...)
+	path, _ := cfg.WorkroomPath(project, "old")
+
+	if err := svc.Lock(project, "old", "hotfix"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(mock.calls[len(mock.calls)-1], " "); got != "git worktree lock "+path+" --reason hotfix" {
+		t.Fatalf("expected worktree to be locked, got %q", got)
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	if lock := p.Workrooms["old"].Lock; lock == nil || lock.Reason != "hotfix" || lock.LockedAt.IsZero() {
+		t.Fatalf("expected lock to be recorded, got %+v", lock)
+	}
+
+	buf.Reset()
+	if err := svc.List(project, ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "[locked: hotfix]") {
+		t.Fatalf("expected lock marker in list, got %q", buf.String())
+	}
+
+	if err := svc.Unlock(project, "old"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(mock.calls[len(mock.calls)-1], " "); got != "git worktree unlock "+path {
+		t.Fatalf("expected worktree to be unlocked, got %q", got)
+	}
+	_, p, _ = cfg.FindCurrentProject(project)
+	if p.Workrooms["old"].Lock != nil {
+		t.Fatal("expected lock to be removed")
+	}
+}
+
+func TestLockedWorkroomIsNotDeleted(t *testing.T) {
+	svc, _, cfg, mock, project := newFixture(t, renameFixture...)
+	cfg.SetLock(project, "old", &config.Lock{Reason: "hotfix"})
+
+	err := svc.Delete(project, "old", DeleteOptions{Confirm: "old", Force: true})
+	if !errors.Is(err, ErrWorkroomLocked) || !strings.Contains(err.Error(), "hotfix") {
+		t.Fatalf("expected ErrWorkroomLocked with reason, got %v", err)
+	}
+	if err := svc.Rename(project, "old", "new"); !errors.Is(err, ErrWorkroomLocked) {
+		t.Fatalf("expected rename to be refused, got %v", err)
+	}
+	for _, c := range mock.calls {
+		if c[1] == "worktree" && (c[2] == "remove" || c[2] == "move") {
+			t.Fatalf("expected locked worktree to be left alone, got %v", c)
+		}
+	}
+}
+
+func TestInteractiveDeleteSkipsLockedWorkrooms(t *testing.T) {
+	svc, buf, cfg, _, project := newFixture(t, renameFixture...)
+	cfg.SetLock(project, "old", &config.Lock{})
+	svc.PromptFn = func(string, []string) ([]string, error) {
+		t.Fatal("expected no prompt when every workroom is locked")
+		return nil, nil
+	}
+
+	if err := svc.InteractiveDelete(project, DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "locked") {
+		t.Fatalf("expected locked message, got %q", buf.String())
+	}
+}
+
+func TestPruneSkipsLockedWorkrooms(t *testing.T) {
+	svc, buf, cfg, _, project := newFixture(t, pruneFixture()...)
+	cfg.SetLock(project, "merged", &config.Lock{})
+
+	if err := svc.Prune(project, PruneOptions{Merged: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "No workrooms to prune.") {
+		t.Fatalf("expected locked workroom not to be pruned, got %q", buf.String())
+	}
+}
+
+// --- Lifecycle hooks ---
+
+// logHook is a hook command that appends its hook point and working directory name to hooks.log
+// in the project.
+const logHook = `echo "$WORKROOM_HOOK $(basename "$PWD") $WORKROOM_BRANCH" >> "$WORKROOM_PARENT_DIR/hooks.log"`
+
+func readHookLog(t *testing.T, project string) []string {
+	t.Helper()
+	data, _ := os.ReadFile(filepath.Join(project, "hooks.log"))
+	return strings.FieldsFunc(string(data), func(r rune) bool { return r == '\n' })
+}
+
+func TestCreateRunsLifecycleHooks(t *testing.T) {
+	svc, _, _, _, project := newFixture(t, withHooks(fmt.Sprintf("pre_create = '%s'\npost_create = '%s'\non_enter = '%s'\n", logHook, logHook, logHook)))
+	setupDir := filepath.Join(project, "scripts", "workroom_setup.d")
+	os.MkdirAll(setupDir, 0o755)
+	for _, name := range []string{"20-second", "10-first"} {
+		os.WriteFile(filepath.Join(setupDir, name), []byte("#!/bin/sh\n"+strings.Replace(logHook, "$WORKROOM_HOOK", "$WORKROOM_HOOK-"+name, 1)+"\n"), 0o755)
+	}
+	svc.CdFile = filepath.Join(t.TempDir(), "cd")
+
+	if err := svc.Create(project, CreateOptions{Name: "bar", Cd: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"pre_create project workroom/bar",
+		"setup-10-first bar workroom/bar",
+		"setup-20-second bar workroom/bar",
+		"post_create bar workroom/bar",
+		"on_enter bar workroom/bar",
+	}
+	if got := readHookLog(t, project); !slices.Equal(got, want) {
+		t.Fatalf("expected hooks %v, got %v", want, got)
+	}
+}
+
+func TestPreCreateHookFailureAborts(t *testing.T) {
+	svc, buf, _, mock, project := newFixture(t, withHooks("pre_create = 'echo not today; exit 1'\n"))
+
+	err := svc.Create(project, CreateOptions{Name: "bar"})
+	if !errors.Is(err, ErrHook) {
+		t.Fatalf("expected ErrHook, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "pre_create | not today") {
+		t.Fatalf("expected hook output to be shown, got %q", buf.String())
+	}
+	for _, c := range mock.calls {
+		if c[1] == "worktree" && c[2] == "add" {
+			t.Fatal("expected no worktree to be created")
+		}
+	}
+	if _, p, _ := svc.Config.FindCurrentProject(project); p != nil {
+		t.Fatal("expected no config entry")
+	}
+}
+
+func TestPostCreateHookFailureKeepsWorkroom(t *testing.T) {
+	svc, buf, _, _, project := newFixture(t, withHooks("post_create = 'exit 3'\n"))
+	wrPath, _ := svc.Config.WorkroomPath(project, "bar")
+
+	err := svc.Create(project, CreateOptions{Name: "bar"})
+	if !errors.Is(err, ErrHook) {
+		t.Fatalf("expected ErrHook, got %v", err)
+	}
+	if strings.Contains(buf.String(), "Rolling back") {
+		t.Fatalf("expected no rollback, got %q", buf.String())
+	}
+	if _, err := os.Stat(wrPath); err != nil {
+		t.Fatalf("expected workroom to be kept: %v", err)
+	}
+}
+
+func TestCreateStreamsAndLogsSetupOutput(t *testing.T) {
+	svc, buf, _, _, project := newFixture(t, withHooks("setup = 'echo installing; echo done >&2'\n"))
+
+	if err := svc.Create(project, CreateOptions{Name: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "setup | installing\nsetup | done\n") {
+		t.Fatalf("expected prefixed setup output, got %q", out)
+	}
+	if strings.Index(out, "installing") > strings.Index(out, "created successfully") {
+		t.Fatalf("expected setup output before the success message, got %q", out)
+	}
+
+	buf.Reset()
+	if err := svc.Logs(project, "bar", "setup"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "installing\ndone\n" {
+		t.Fatalf("expected logged setup output, got %q", buf.String())
+	}
+	if err := svc.Logs(project, "bar", "teardown"); err == nil {
+		t.Fatal("expected an error for a hook that has not run")
+	}
+	if err := svc.Logs(project, "bar", "bogus"); err == nil || !strings.Contains(err.Error(), "unknown hook") {
+		t.Fatalf("expected unknown hook error, got %v", err)
+	}
+}
+
+func TestTeardownLogOutlivesWorkroom(t *testing.T) {
+	svc, buf, _, mock, project := newFixture(t, withHooks("teardown = 'echo stopping services'\n"))
+	wrPath, _ := svc.Config.WorkroomPath(project, "bar")
+	os.MkdirAll(wrPath, 0o755)
+	svc.Config.AddWorkroomEntry(project, "git", "bar", config.WorkroomEntry{Path: wrPath, Branch: "workroom/bar"})
+	mock.output = "worktree " + project + "\nHEAD cbace1f\nbranch refs/heads/main\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/workroom/bar\n"
+
+	if err := svc.Delete(project, "bar", DeleteOptions{Confirm: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := svc.Logs(project, "bar", ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "==> teardown") || !strings.Contains(buf.String(), "stopping services") {
+		t.Fatalf("expected the teardown log, got %q", buf.String())
+	}
+}
+
+func TestSetupScriptWithoutExecBit(t *testing.T) {
+	svc, buf, _, _, project := newFixture(t)
+	os.MkdirAll(filepath.Join(project, "scripts"), 0o755)
+	os.WriteFile(filepath.Join(project, "scripts", "workroom_setup"), []byte("#!/bin/sh\necho from shebang\n"), 0o644)
+
+	if err := svc.Create(project, CreateOptions{Name: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "setup | from shebang") {
+		t.Fatalf("expected the setup script to run by its #! interpreter, got %q", buf.String())
+	}
+}
+
+func TestSetupHookTimeoutRollsBackCreate(t *testing.T) {
+	svc, buf, _, _, project := newFixture(t, withHooks("setup = 'sleep 5'\ntimeout = { setup = '200ms' }\n"))
+	wrPath, _ := svc.Config.WorkroomPath(project, "bar")
+
+	start := time.Now()
+	err := svc.Create(project, CreateOptions{Name: "bar"})
+	if !errors.Is(err, ErrSetup) || !errors.Is(err, ErrHookTimeout) {
+		t.Fatalf("expected ErrSetup caused by ErrHookTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected the setup hook to be killed, but create took %s", elapsed)
+	}
+	if !strings.Contains(buf.String(), "Rolling back") {
+		t.Fatalf("expected a rollback, got %q", buf.String())
+	}
+	if _, err := os.Stat(wrPath); !os.IsNotExist(err) {
+		t.Fatal("expected the workroom directory to be removed")
+	}
+}
+
+func TestInterruptDuringCreateRollsBack(t *testing.T) {
+	svc, _, _, mock, project := newFixture(t, withHooks("setup = 'sleep 5'\n"))
+	ctx, cancel := context.WithCancelCause(t.Context())
+	svc.Context = ctx
+	time.AfterFunc(200*time.Millisecond, func() { cancel(ErrInterrupted) })
+
+	err := svc.Create(project, CreateOptions{Name: "bar"})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	removed := false
+	for _, c := range mock.calls {
+		if c[0] == "git" && c[1] == "worktree" && c[2] == "remove" {
+			removed = true
+		}
+	}
+	if !removed {
+		t.Fatalf("expected the worktree to be removed despite the interrupt, got calls %v", mock.calls)
+	}
+	if _, p, _ := svc.Config.FindCurrentProject(project); p != nil && p.Workrooms["bar"] != nil {
+		t.Fatal("expected no config entry")
+	}
+}
+
+func TestDeleteRunsLifecycleHooks(t *testing.T) {
+	svc, _, _, mock, project := newFixture(t, withHooks(fmt.Sprintf("pre_delete = '%s'\nteardown = '%s'\npost_delete = '%s'\n", logHook, logHook, logHook)))
+	wrPath, _ := svc.Config.WorkroomPath(project, "bar")
+	os.MkdirAll(wrPath, 0o755)
+	svc.Config.AddWorkroomEntry(project, "git", "bar", config.WorkroomEntry{Path: wrPath, Branch: "workroom/bar"})
+	mock.output = "worktree " + project + "\nHEAD cbace1f\nbranch refs/heads/main\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/workroom/bar\n"
+
+	if err := svc.Delete(project, "bar", DeleteOptions{Confirm: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"pre_delete bar workroom/bar", "teardown bar workroom/bar", "post_delete project workroom/bar"}
+	if got := readHookLog(t, project); !slices.Equal(got, want) {
+		t.Fatalf("expected hooks %v, got %v", want, got)
+	}
+}
+
+func TestPreDeleteHookFailureAborts(t *testing.T) {
+	svc, _, _, mock, project := newFixture(t, withHooks("pre_delete = 'exit 1'\n"))
+	wrPath, _ := svc.Config.WorkroomPath(project, "bar")
+	os.MkdirAll(wrPath, 0o755)
+	svc.Config.AddWorkroomEntry(project, "git", "bar", config.WorkroomEntry{Path: wrPath, Branch: "workroom/bar"})
+	mock.output = "worktree " + project + "\nHEAD cbace1f\nbranch refs/heads/main\n\nworktree " + wrPath + "\nHEAD abc123\nbranch refs/heads/workroom/bar\n"
+
+	if err := svc.Delete(project, "bar", DeleteOptions{Confirm: "bar"}); !errors.Is(err, ErrHook) {
+		t.Fatalf("expected ErrHook, got %v", err)
+	}
+	for _, c := range mock.calls {
+		if c[1] == "worktree" && c[2] == "remove" {
+			t.Fatal("expected worktree not to be removed")
+		}
+	}
+}
+
+func TestPreRenameHookFromDirectory(t *testing.T) {
+	svc, _, cfg, _, project := newFixture(t, renameFixture...)
+	hookDir := filepath.Join(project, "hooks", "pre_rename")
+	os.MkdirAll(hookDir, 0o755)
+	os.WriteFile(filepath.Join(hookDir, "check"), []byte("#!/bin/sh\necho \"$WORKROOM_NAME -> $WORKROOM_NEW_NAME\" > \"$WORKROOM_PARENT_DIR/hooks.log\"\nexit 1\n"), 0o755)
+	os.WriteFile(filepath.Join(project, ".workroom.toml"), []byte("[hooks]\npre_rename = \"hooks/pre_rename\"\n"), 0o644)
+
+	if err := svc.Rename(project, "old", "new"); !errors.Is(err, ErrHook) {
+		t.Fatalf("expected ErrHook, got %v", err)
+	}
+	if got := readHookLog(t, project); !slices.Equal(got, []string{"old -> new"}) {
+		t.Fatalf("expected pre_rename scripts to run, got %v", got)
+	}
+	_, p, _ := cfg.FindCurrentProject(project)
+	if p.Workrooms["old"] == nil {
+		t.Fatal("expected rename to be aborted")
+	}
+}
+
+func TestPruneRunsOnPruneHook(t *testing.T) {
+	svc, _, _, _, project := newFixture(t, pruneFixture()...)
+	os.WriteFile(filepath.Join(project, ".workroom.toml"), []byte("[hooks]\non_prune = 'echo \"$WORKROOM_PRUNED\" > hooks.log'\n"), 0o644)
+
+	if err := svc.Prune(project, PruneOptions{Missing: true, Keep: []string{"release-*"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHookLog(t, project); !slices.Equal(got, []string{"missing"}) {
+		t.Fatalf("expected on_prune to get the pruned workrooms, got %v", got)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/joelmoss/workroom/cmd"
+	"github.com/joelmoss/workroom/internal/errs"
 )
 
 // version is set via -ldflags at build time.
@@ -12,6 +14,10 @@ var version = "dev"
 func main() {
 	cmd.SetVersion(version)
 	if err := cmd.Execute(); err != nil {
+		var exitErr *errs.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }